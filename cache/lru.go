@@ -0,0 +1,184 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cache contains generic in-memory caches.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// LRU is a fixed-size cache that evicts the least recently used entry when it's full.
+//
+// Both Get and Put mark the entry as recently used. All methods are safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	entries  map[K]*list.Element
+	// order contains the entries from the most recently used to the least recently used.
+	order *list.List
+	lock  sync.Mutex
+}
+
+// NewLRU creates a new LRU cache that can hold the given number of entries.
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return NewLRUWithTTL[K, V](capacity, 0)
+}
+
+// NewLRUWithTTL creates a new LRU cache where entries also expire after the given duration since they were added.
+//
+// Expired entries are removed lazily when they're accessed. Call StartEviction to also remove them in the background,
+// so that they don't stay in memory until the cache is full.
+func NewLRUWithTTL[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	if capacity <= 0 {
+		panic("cache: LRU capacity must be positive")
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (lru *LRU[K, V]) isExpired(entry *lruEntry[K, V], now time.Time) bool {
+	return lru.ttl > 0 && now.After(entry.expires)
+}
+
+func (lru *LRU[K, V]) remove(elem *list.Element) *lruEntry[K, V] {
+	entry := lru.order.Remove(elem).(*lruEntry[K, V])
+	delete(lru.entries, entry.key)
+	return entry
+}
+
+func (lru *LRU[K, V]) removeExpired() {
+	if lru.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	for elem := lru.order.Front(); elem != nil; {
+		next := elem.Next()
+		if lru.isExpired(elem.Value.(*lruEntry[K, V]), now) {
+			lru.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Get returns the value for the given key and marks it as recently used.
+func (lru *LRU[K, V]) Get(key K) (value V, ok bool) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	elem, ok := lru.entries[key]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if lru.isExpired(entry, time.Now()) {
+		lru.remove(elem)
+		return value, false
+	}
+	lru.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put adds or replaces the value for the given key and marks it as recently used.
+// If the cache is full, the least recently used entry is evicted.
+func (lru *LRU[K, V]) Put(key K, value V) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	var expires time.Time
+	if lru.ttl > 0 {
+		expires = time.Now().Add(lru.ttl)
+	}
+	if elem, ok := lru.entries[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expires = expires
+		lru.order.MoveToFront(elem)
+		return
+	}
+	if lru.order.Len() >= lru.capacity {
+		lru.remove(lru.order.Back())
+	}
+	lru.entries[key] = lru.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+}
+
+// Delete removes the given key from the cache. The return value is true if the key was in the cache.
+func (lru *LRU[K, V]) Delete(key K) bool {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	elem, ok := lru.entries[key]
+	if ok {
+		lru.remove(elem)
+	}
+	return ok
+}
+
+// Len returns the number of entries in the cache, not including expired entries.
+func (lru *LRU[K, V]) Len() int {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	lru.removeExpired()
+	return lru.order.Len()
+}
+
+// Keys returns the keys in the cache from the most recently used to the least recently used.
+// This doesn't change the order of the entries.
+func (lru *LRU[K, V]) Keys() []K {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	lru.removeExpired()
+	keys := make([]K, 0, lru.order.Len())
+	for elem := lru.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*lruEntry[K, V]).key)
+	}
+	return keys
+}
+
+// Evict removes the least recently used entry from the cache and returns it.
+// If the cache is empty, ok is false.
+func (lru *LRU[K, V]) Evict() (key K, value V, ok bool) {
+	lru.lock.Lock()
+	defer lru.lock.Unlock()
+	lru.removeExpired()
+	if elem := lru.order.Back(); elem != nil {
+		entry := lru.remove(elem)
+		return entry.key, entry.value, true
+	}
+	return
+}
+
+// StartEviction starts a goroutine that periodically removes expired entries from the cache.
+// The goroutine stops when the context is canceled. If the cache doesn't have a TTL, this does nothing.
+func (lru *LRU[K, V]) StartEviction(ctx context.Context) {
+	if lru.ttl <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(max(lru.ttl/2, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				lru.lock.Lock()
+				lru.removeExpired()
+				lru.lock.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/cache"
+)
+
+func TestLRU(t *testing.T) {
+	lru := cache.NewLRU[string, int](3)
+	lru.Put("a", 1)
+	lru.Put("b", 2)
+	lru.Put("c", 3)
+	assert.Equal(t, []string{"c", "b", "a"}, lru.Keys())
+
+	val, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	assert.Equal(t, []string{"a", "c", "b"}, lru.Keys())
+
+	lru.Put("d", 4)
+	assert.Equal(t, 3, lru.Len())
+	_, ok = lru.Get("b")
+	assert.False(t, ok, "least recently used entry should have been evicted")
+	assert.Equal(t, []string{"d", "a", "c"}, lru.Keys())
+
+	lru.Put("c", 30)
+	val, _ = lru.Get("c")
+	assert.Equal(t, 30, val)
+	assert.Equal(t, 3, lru.Len())
+
+	assert.True(t, lru.Delete("a"))
+	assert.False(t, lru.Delete("a"))
+	assert.Equal(t, []string{"c", "d"}, lru.Keys())
+}
+
+func TestLRU_Evict(t *testing.T) {
+	lru := cache.NewLRU[int, string](2)
+	_, _, ok := lru.Evict()
+	assert.False(t, ok)
+	lru.Put(1, "one")
+	lru.Put(2, "two")
+	lru.Get(1)
+	key, val, ok := lru.Evict()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	assert.Equal(t, "two", val)
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestLRU_InvalidCapacity(t *testing.T) {
+	assert.Panics(t, func() {
+		cache.NewLRU[int, int](0)
+	})
+}
+
+func TestLRUWithTTL(t *testing.T) {
+	lru := cache.NewLRUWithTTL[string, int](10, 20*time.Millisecond)
+	lru.Put("a", 1)
+	val, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	time.Sleep(30 * time.Millisecond)
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+
+	lru.Put("b", 2)
+	time.Sleep(30 * time.Millisecond)
+	lru.Put("c", 3)
+	assert.Equal(t, []string{"c"}, lru.Keys())
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestLRUWithTTL_StartEviction(t *testing.T) {
+	lru := cache.NewLRUWithTTL[string, int](10, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lru.StartEviction(ctx)
+	lru.Put("a", 1)
+	assert.Eventually(t, func() bool {
+		return lru.Len() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestLRU_Concurrent(t *testing.T) {
+	lru := cache.NewLRU[int, string](50)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := (i * j) % 100
+				lru.Put(key, strconv.Itoa(key))
+				if val, ok := lru.Get(key); ok {
+					assert.Equal(t, strconv.Itoa(key), val)
+				}
+				lru.Delete(key + 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, lru.Len(), 50)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections
+
+import (
+	"cmp"
+	"container/heap"
+)
+
+// heapSlice implements heap.Interface for PriorityQueue.
+type heapSlice[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+var _ heap.Interface = (*heapSlice[int])(nil)
+
+func (h *heapSlice[T]) Len() int           { return len(h.items) }
+func (h *heapSlice[T]) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *heapSlice[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *heapSlice[T]) Push(x any)         { h.items = append(h.items, x.(T)) }
+
+func (h *heapSlice[T]) Pop() any {
+	var zero T
+	last := h.items[len(h.items)-1]
+	h.items[len(h.items)-1] = zero
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// PriorityQueue is a binary heap that always returns the smallest item first according to the given comparator.
+type PriorityQueue[T any] struct {
+	heap heapSlice[T]
+}
+
+// NewPriorityQueue creates a new priority queue. The less function must return true if a should be popped before b.
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{heap: heapSlice[T]{less: less}}
+}
+
+// NewMinPQ creates a new priority queue that returns the smallest item first.
+func NewMinPQ[T cmp.Ordered]() *PriorityQueue[T] {
+	return NewPriorityQueue(cmp.Less[T])
+}
+
+// Push adds an item to the queue.
+func (pq *PriorityQueue[T]) Push(item T) {
+	heap.Push(&pq.heap, item)
+}
+
+// Pop removes and returns the first item in the queue. If the queue is empty, ok is false.
+func (pq *PriorityQueue[T]) Pop() (item T, ok bool) {
+	if len(pq.heap.items) == 0 {
+		return
+	}
+	return heap.Pop(&pq.heap).(T), true
+}
+
+// Peek returns the first item in the queue without removing it. If the queue is empty, ok is false.
+func (pq *PriorityQueue[T]) Peek() (item T, ok bool) {
+	if len(pq.heap.items) == 0 {
+		return
+	}
+	return pq.heap.items[0], true
+}
+
+// Len returns the number of items in the queue.
+func (pq *PriorityQueue[T]) Len() int {
+	return len(pq.heap.items)
+}
+
+// Remove removes and returns the item at the given index in the underlying heap.
+//
+// Index 0 is always the first item, but other indexes don't follow the priority order.
+// This panics if the index is out of range.
+func (pq *PriorityQueue[T]) Remove(index int) T {
+	return heap.Remove(&pq.heap, index).(T)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/collections"
+)
+
+func popAll[T any](pq *collections.PriorityQueue[T]) (out []T) {
+	for {
+		item, ok := pq.Pop()
+		if !ok {
+			return
+		}
+		out = append(out, item)
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	pq := collections.NewMinPQ[int]()
+	_, ok := pq.Pop()
+	assert.False(t, ok)
+	_, ok = pq.Peek()
+	assert.False(t, ok)
+
+	items := rand.Perm(100)
+	for _, item := range items {
+		pq.Push(item)
+	}
+	assert.Equal(t, 100, pq.Len())
+	first, ok := pq.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 0, first)
+	slices.Sort(items)
+	assert.Equal(t, items, popAll(pq))
+	assert.Equal(t, 0, pq.Len())
+}
+
+func TestPriorityQueue_Comparator(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+	pq := collections.NewPriorityQueue(func(a, b task) bool {
+		return a.priority > b.priority
+	})
+	pq.Push(task{"low", 1})
+	pq.Push(task{"high", 10})
+	pq.Push(task{"medium", 5})
+	var names []string
+	for _, item := range popAll(pq) {
+		names = append(names, item.name)
+	}
+	assert.Equal(t, []string{"high", "medium", "low"}, names)
+}
+
+func TestPriorityQueue_Remove(t *testing.T) {
+	pq := collections.NewMinPQ[string]()
+	for _, item := range []string{"d", "b", "a", "c"} {
+		pq.Push(item)
+	}
+	assert.Equal(t, "a", pq.Remove(0))
+	removed := pq.Remove(pq.Len() - 1)
+	remaining := popAll(pq)
+	assert.Len(t, remaining, 2)
+	assert.True(t, slices.IsSorted(remaining))
+	assert.NotContains(t, remaining, removed)
+	assert.Panics(t, func() {
+		pq.Remove(0)
+	})
+}
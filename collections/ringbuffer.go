@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections
+
+// RingBuffer is a fixed-capacity circular buffer. When the buffer is full, pushing a new item overwrites the oldest one.
+//
+// All the memory is allocated when the buffer is created, so pushing and popping never allocate.
+// See exsync.RingBuffer for a synchronized key-value variant.
+type RingBuffer[T any] struct {
+	data []T
+	// head is the index of the oldest item.
+	head int
+	size int
+}
+
+// NewRingBuffer creates a new ring buffer that can hold the given number of items.
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	if capacity <= 0 {
+		panic("collections: RingBuffer capacity must be positive")
+	}
+	return &RingBuffer[T]{data: make([]T, capacity)}
+}
+
+// Push adds an item to the end of the buffer, overwriting the oldest item if the buffer is full.
+func (rb *RingBuffer[T]) Push(item T) {
+	rb.data[(rb.head+rb.size)%len(rb.data)] = item
+	if rb.size == len(rb.data) {
+		rb.head = (rb.head + 1) % len(rb.data)
+	} else {
+		rb.size++
+	}
+}
+
+// PushMany adds all the given items to the end of the buffer. If there are more items than fit in the buffer,
+// only the last items are kept.
+func (rb *RingBuffer[T]) PushMany(items []T) {
+	if len(items) >= len(rb.data) {
+		copy(rb.data, items[len(items)-len(rb.data):])
+		rb.head = 0
+		rb.size = len(rb.data)
+		return
+	}
+	tail := (rb.head + rb.size) % len(rb.data)
+	n := copy(rb.data[tail:], items)
+	copy(rb.data, items[n:])
+	if overflow := rb.size + len(items) - len(rb.data); overflow > 0 {
+		rb.head = (rb.head + overflow) % len(rb.data)
+		rb.size = len(rb.data)
+	} else {
+		rb.size += len(items)
+	}
+}
+
+// Pop removes and returns the oldest item in the buffer. If the buffer is empty, ok is false.
+func (rb *RingBuffer[T]) Pop() (item T, ok bool) {
+	if rb.size == 0 {
+		return
+	}
+	var zero T
+	item = rb.data[rb.head]
+	rb.data[rb.head] = zero
+	rb.head = (rb.head + 1) % len(rb.data)
+	rb.size--
+	return item, true
+}
+
+// Peek returns the oldest item in the buffer without removing it. If the buffer is empty, ok is false.
+func (rb *RingBuffer[T]) Peek() (item T, ok bool) {
+	if rb.size == 0 {
+		return
+	}
+	return rb.data[rb.head], true
+}
+
+// Len returns the number of items in the buffer.
+func (rb *RingBuffer[T]) Len() int {
+	return rb.size
+}
+
+// Cap returns the maximum number of items the buffer can hold.
+func (rb *RingBuffer[T]) Cap() int {
+	return len(rb.data)
+}
+
+// IsFull returns true if the buffer is full, i.e. the next push will overwrite the oldest item.
+func (rb *RingBuffer[T]) IsFull() bool {
+	return rb.size == len(rb.data)
+}
+
+// Slice returns a copy of the items in the buffer from the oldest to the newest.
+func (rb *RingBuffer[T]) Slice() []T {
+	out := make([]T, rb.size)
+	n := copy(out, rb.data[rb.head:min(rb.head+rb.size, len(rb.data))])
+	copy(out[n:], rb.data[:rb.size-n])
+	return out
+}
+
+// Reset removes all items from the buffer.
+func (rb *RingBuffer[T]) Reset() {
+	clear(rb.data)
+	rb.head = 0
+	rb.size = 0
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/collections"
+)
+
+func TestRingBuffer(t *testing.T) {
+	rb := collections.NewRingBuffer[int](3)
+	assert.Equal(t, 3, rb.Cap())
+	assert.Equal(t, 0, rb.Len())
+	_, ok := rb.Peek()
+	assert.False(t, ok)
+	_, ok = rb.Pop()
+	assert.False(t, ok)
+	assert.Empty(t, rb.Slice())
+
+	rb.Push(1)
+	rb.Push(2)
+	assert.False(t, rb.IsFull())
+	rb.Push(3)
+	assert.True(t, rb.IsFull())
+	assert.Equal(t, []int{1, 2, 3}, rb.Slice())
+
+	rb.Push(4)
+	assert.Equal(t, 3, rb.Len())
+	assert.Equal(t, []int{2, 3, 4}, rb.Slice())
+	val, ok := rb.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	val, ok = rb.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+	assert.Equal(t, []int{3, 4}, rb.Slice())
+	rb.Push(5)
+	rb.Push(6)
+	assert.Equal(t, []int{4, 5, 6}, rb.Slice())
+
+	rb.Reset()
+	assert.Equal(t, 0, rb.Len())
+	assert.Empty(t, rb.Slice())
+	rb.Push(7)
+	assert.Equal(t, []int{7}, rb.Slice())
+}
+
+func TestRingBuffer_SliceIsCopy(t *testing.T) {
+	rb := collections.NewRingBuffer[int](2)
+	rb.Push(1)
+	rb.Push(2)
+	slice := rb.Slice()
+	slice[0] = 100
+	assert.Equal(t, []int{1, 2}, rb.Slice())
+}
+
+func TestRingBuffer_PushMany(t *testing.T) {
+	rb := collections.NewRingBuffer[int](4)
+	rb.PushMany([]int{1, 2})
+	assert.Equal(t, []int{1, 2}, rb.Slice())
+	rb.PushMany([]int{3, 4, 5})
+	assert.Equal(t, []int{2, 3, 4, 5}, rb.Slice())
+	rb.Pop()
+	rb.PushMany([]int{6})
+	assert.Equal(t, []int{3, 4, 5, 6}, rb.Slice())
+	rb.PushMany([]int{7, 8, 9, 10, 11, 12})
+	assert.Equal(t, []int{9, 10, 11, 12}, rb.Slice())
+	rb.PushMany(nil)
+	assert.Equal(t, []int{9, 10, 11, 12}, rb.Slice())
+
+	// Compare with pushing one by one
+	for n := 0; n < 10; n++ {
+		a := collections.NewRingBuffer[int](5)
+		b := collections.NewRingBuffer[int](5)
+		for i := 0; i < 3; i++ {
+			a.Push(i)
+			b.Push(i)
+		}
+		a.Pop()
+		b.Pop()
+		items := make([]int, n)
+		for i := range items {
+			items[i] = 100 + i
+			a.Push(items[i])
+		}
+		b.PushMany(items)
+		assert.Equal(t, a.Slice(), b.Slice(), "PushMany with %d items", n)
+	}
+}
+
+func TestRingBuffer_NoAllocs(t *testing.T) {
+	rb := collections.NewRingBuffer[int](16)
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		rb.Push(1)
+		rb.Pop()
+		rb.Push(2)
+	}))
+}
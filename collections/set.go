@@ -0,0 +1,314 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package collections contains generic data structures that aren't in the standard library.
+//
+// The types in this package are not safe for concurrent use. See the exsync package for synchronized alternatives.
+package collections
+
+import (
+	"cmp"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Set is a set of unique values backed by a map[T]struct{}.
+//
+// Like normal maps, sets have reference semantics and the zero value is a nil set, which can be read from,
+// but must be initialized with NewSet, SetFrom or make before adding items.
+type Set[T comparable] map[T]struct{}
+
+// NewSet creates a set containing the given items.
+func NewSet[T comparable](items ...T) Set[T] {
+	return SetFrom(items)
+}
+
+// SetFrom creates a set containing the items in the given slice. The slice is not modified or retained.
+func SetFrom[T comparable](items []T) Set[T] {
+	s := make(Set[T], len(items))
+	for _, item := range items {
+		s[item] = struct{}{}
+	}
+	return s
+}
+
+// Add adds an item to the set. The return value is true if the item was added, or false if it was already in the set.
+func (s Set[T]) Add(item T) bool {
+	if _, exists := s[item]; exists {
+		return false
+	}
+	s[item] = struct{}{}
+	return true
+}
+
+// Remove removes an item from the set. The return value is true if the item was in the set.
+func (s Set[T]) Remove(item T) bool {
+	if _, exists := s[item]; !exists {
+		return false
+	}
+	delete(s, item)
+	return true
+}
+
+// Contains checks if the given item is in the set.
+func (s Set[T]) Contains(item T) bool {
+	_, exists := s[item]
+	return exists
+}
+
+// Len returns the number of items in the set.
+func (s Set[T]) Len() int {
+	return len(s)
+}
+
+// Slice returns the items in the set as a slice.
+//
+// If the underlying type of T is ordered (i.e. an integer, float or string type that satisfies cmp.Ordered),
+// the items are sorted, so the order is stable between calls. Named types like `type ID string` are sorted using
+// reflection, which makes them slower than the built-in types.
+//
+// Other element kinds (e.g. structs, pointers and bools) are returned in map iteration order, which is not stable
+// between calls. Use OrderedSet if the items should be returned in insertion order.
+func (s Set[T]) Slice() []T {
+	items := slices.AppendSeq(make([]T, 0, len(s)), maps.Keys(s))
+	sortIfOrdered(items)
+	return items
+}
+
+// Sorted returns the items in the given set as a sorted slice.
+//
+// This is equivalent to Slice, but it's faster, as the type is known to be ordered at compile time.
+func Sorted[T cmp.Ordered](s Set[T]) []T {
+	return slices.Sorted(maps.Keys(s))
+}
+
+// sortIfOrdered sorts the given slice in the same order as slices.Sort if the underlying type of T satisfies
+// cmp.Ordered. Generic code can't use the constraint for a comparable type parameter, so built-in types are
+// matched with a type switch and named types are sorted using reflection.
+func sortIfOrdered[T any](items []T) {
+	if len(items) < 2 {
+		return
+	}
+	switch typedItems := any(items).(type) {
+	case []string:
+		slices.Sort(typedItems)
+	case []int:
+		slices.Sort(typedItems)
+	case []int8:
+		slices.Sort(typedItems)
+	case []int16:
+		slices.Sort(typedItems)
+	case []int32:
+		slices.Sort(typedItems)
+	case []int64:
+		slices.Sort(typedItems)
+	case []uint:
+		slices.Sort(typedItems)
+	case []uint8:
+		slices.Sort(typedItems)
+	case []uint16:
+		slices.Sort(typedItems)
+	case []uint32:
+		slices.Sort(typedItems)
+	case []uint64:
+		slices.Sort(typedItems)
+	case []uintptr:
+		slices.Sort(typedItems)
+	case []float32:
+		slices.Sort(typedItems)
+	case []float64:
+		slices.Sort(typedItems)
+	default:
+		sortNamedOrdered(items)
+	}
+}
+
+// sortNamedOrdered is the slow path of sortIfOrdered for named types, which can't be matched in a type switch.
+func sortNamedOrdered[T any](items []T) {
+	val := reflect.ValueOf(items)
+	var less func(i, j int) bool
+	switch val.Type().Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(i, j int) bool { return val.Index(i).Int() < val.Index(j).Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(i, j int) bool { return val.Index(i).Uint() < val.Index(j).Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(i, j int) bool { return cmp.Less(val.Index(i).Float(), val.Index(j).Float()) }
+	case reflect.String:
+		less = func(i, j int) bool { return val.Index(i).String() < val.Index(j).String() }
+	default:
+		return
+	}
+	sort.Slice(items, less)
+}
+
+// Union returns a new set containing all items that are in either set.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	union := make(Set[T], max(len(s), len(other)))
+	maps.Copy(union, s)
+	maps.Copy(union, other)
+	return union
+}
+
+// Intersection returns a new set containing the items that are in both sets.
+func (s Set[T]) Intersection(other Set[T]) Set[T] {
+	if len(other) < len(s) {
+		s, other = other, s
+	}
+	intersection := make(Set[T])
+	for item := range s {
+		if other.Contains(item) {
+			intersection[item] = struct{}{}
+		}
+	}
+	return intersection
+}
+
+// Difference returns a new set containing the items that are in this set, but not in the other set.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	difference := make(Set[T])
+	for item := range s {
+		if !other.Contains(item) {
+			difference[item] = struct{}{}
+		}
+	}
+	return difference
+}
+
+// IsSubset checks if all items in this set are also in the other set.
+func (s Set[T]) IsSubset(other Set[T]) bool {
+	if len(s) > len(other) {
+		return false
+	}
+	for item := range s {
+		if !other.Contains(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal checks if both sets contain exactly the same items.
+func (s Set[T]) Equal(other Set[T]) bool {
+	return len(s) == len(other) && s.IsSubset(other)
+}
+
+// OrderedSet is a set of unique values that remembers the order in which the items were added.
+// It has the same methods as Set, but Slice and the set operations preserve the insertion order.
+//
+// The zero value is an empty set ready to use. Removing items is O(n), as the order must be preserved.
+type OrderedSet[T comparable] struct {
+	items []T
+	index map[T]int
+}
+
+// NewOrderedSet creates an ordered set containing the given items. Duplicates are ignored.
+func NewOrderedSet[T comparable](items ...T) *OrderedSet[T] {
+	s := &OrderedSet[T]{
+		items: make([]T, 0, len(items)),
+		index: make(map[T]int, len(items)),
+	}
+	for _, item := range items {
+		s.Add(item)
+	}
+	return s
+}
+
+// Add adds an item to the end of the set. The return value is true if the item was added,
+// or false if it was already in the set, in which case its position is not changed.
+func (s *OrderedSet[T]) Add(item T) bool {
+	if _, exists := s.index[item]; exists {
+		return false
+	} else if s.index == nil {
+		s.index = make(map[T]int)
+	}
+	s.index[item] = len(s.items)
+	s.items = append(s.items, item)
+	return true
+}
+
+// Remove removes an item from the set. The return value is true if the item was in the set.
+func (s *OrderedSet[T]) Remove(item T) bool {
+	idx, exists := s.index[item]
+	if !exists {
+		return false
+	}
+	delete(s.index, item)
+	s.items = slices.Delete(s.items, idx, idx+1)
+	for i := idx; i < len(s.items); i++ {
+		s.index[s.items[i]] = i
+	}
+	return true
+}
+
+// Contains checks if the given item is in the set.
+func (s *OrderedSet[T]) Contains(item T) bool {
+	_, exists := s.index[item]
+	return exists
+}
+
+// Len returns the number of items in the set.
+func (s *OrderedSet[T]) Len() int {
+	return len(s.items)
+}
+
+// Slice returns a copy of the items in the set in the order they were added.
+func (s *OrderedSet[T]) Slice() []T {
+	return slices.Clone(s.items)
+}
+
+// Union returns a new set containing all items that are in either set. The items of this set come first,
+// followed by the items that are only in the other set.
+func (s *OrderedSet[T]) Union(other *OrderedSet[T]) *OrderedSet[T] {
+	union := NewOrderedSet(s.items...)
+	for _, item := range other.items {
+		union.Add(item)
+	}
+	return union
+}
+
+// Intersection returns a new set containing the items that are in both sets, in the order of this set.
+func (s *OrderedSet[T]) Intersection(other *OrderedSet[T]) *OrderedSet[T] {
+	intersection := NewOrderedSet[T]()
+	for _, item := range s.items {
+		if other.Contains(item) {
+			intersection.Add(item)
+		}
+	}
+	return intersection
+}
+
+// Difference returns a new set containing the items that are in this set, but not in the other set,
+// in the order of this set.
+func (s *OrderedSet[T]) Difference(other *OrderedSet[T]) *OrderedSet[T] {
+	difference := NewOrderedSet[T]()
+	for _, item := range s.items {
+		if !other.Contains(item) {
+			difference.Add(item)
+		}
+	}
+	return difference
+}
+
+// IsSubset checks if all items in this set are also in the other set.
+func (s *OrderedSet[T]) IsSubset(other *OrderedSet[T]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	for _, item := range s.items {
+		if !other.Contains(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal checks if both sets contain exactly the same items. The order of the items is ignored.
+func (s *OrderedSet[T]) Equal(other *OrderedSet[T]) bool {
+	return s.Len() == other.Len() && s.IsSubset(other)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/collections"
+)
+
+func TestSet(t *testing.T) {
+	s := collections.NewSet(1, 2, 2, 3)
+	assert.Equal(t, 3, s.Len())
+	assert.True(t, s.Contains(2))
+	assert.False(t, s.Contains(4))
+	assert.True(t, s.Add(4))
+	assert.False(t, s.Add(4))
+	assert.True(t, s.Remove(1))
+	assert.False(t, s.Remove(1))
+	assert.Equal(t, []int{2, 3, 4}, s.Slice())
+	assert.Equal(t, []int{2, 3, 4}, collections.Sorted(s))
+
+	var nilSet collections.Set[int]
+	assert.False(t, nilSet.Contains(1))
+	assert.Equal(t, 0, nilSet.Len())
+	assert.Empty(t, nilSet.Slice())
+}
+
+func TestSetFrom(t *testing.T) {
+	items := []string{"b", "a", "b"}
+	s := collections.SetFrom(items)
+	assert.Equal(t, []string{"a", "b"}, collections.Sorted(s))
+	assert.Equal(t, []string{"b", "a", "b"}, items)
+}
+
+func TestSet_Algebra(t *testing.T) {
+	a := collections.NewSet(1, 2, 3)
+	b := collections.NewSet(2, 3, 4)
+	assert.Equal(t, collections.NewSet(1, 2, 3, 4), a.Union(b))
+	assert.Equal(t, collections.NewSet(2, 3), a.Intersection(b))
+	assert.Equal(t, collections.NewSet(1), a.Difference(b))
+	assert.Equal(t, collections.NewSet(4), b.Difference(a))
+	assert.Equal(t, collections.NewSet(1, 2, 3), a, "operations must not modify the original set")
+
+	assert.True(t, collections.NewSet(2, 3).IsSubset(a))
+	assert.False(t, a.IsSubset(b))
+	assert.True(t, collections.NewSet[int]().IsSubset(a))
+	assert.True(t, a.Equal(collections.NewSet(3, 2, 1)))
+	assert.False(t, a.Equal(b))
+	assert.False(t, a.Equal(collections.NewSet(1, 2)))
+}
+
+type setTestID string
+
+type setTestItem struct {
+	ID int
+}
+
+func TestSet_SliceOrder(t *testing.T) {
+	assert.Equal(t, []setTestID{"a", "b", "c", "d"}, collections.NewSet[setTestID]("d", "b", "a", "c").Slice())
+	assert.Equal(t, []uint8{1, 2, 200}, collections.NewSet[uint8](200, 1, 2).Slice())
+	assert.Equal(t, []float64{-1.5, 0, 2.25}, collections.NewSet(2.25, -1.5, 0).Slice())
+	ints := collections.NewSet(5, -3, 100, 0, 42, -7, 8)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, []int{-7, -3, 0, 5, 8, 42, 100}, ints.Slice())
+	}
+	// Types that aren't ordered are returned in an unspecified order
+	assert.ElementsMatch(t, []setTestItem{{1}, {2}}, collections.NewSet(setTestItem{2}, setTestItem{1}).Slice())
+}
+
+func TestOrderedSet(t *testing.T) {
+	s := collections.NewOrderedSet("c", "a", "c", "b")
+	assert.Equal(t, 3, s.Len())
+	assert.Equal(t, []string{"c", "a", "b"}, s.Slice())
+	assert.False(t, s.Add("c"))
+	assert.True(t, s.Add("d"))
+	assert.True(t, s.Remove("a"))
+	assert.False(t, s.Remove("a"))
+	assert.False(t, s.Contains("a"))
+	assert.True(t, s.Contains("b"))
+	assert.Equal(t, []string{"c", "b", "d"}, s.Slice())
+	assert.True(t, s.Remove("c"))
+	assert.True(t, s.Add("c"))
+	assert.Equal(t, []string{"b", "d", "c"}, s.Slice())
+
+	var zero collections.OrderedSet[int]
+	assert.True(t, zero.Add(1))
+	assert.Equal(t, []int{1}, zero.Slice())
+}
+
+func TestOrderedSet_Algebra(t *testing.T) {
+	a := collections.NewOrderedSet(3, 1, 2)
+	b := collections.NewOrderedSet(4, 2, 3)
+	assert.Equal(t, []int{3, 1, 2, 4}, a.Union(b).Slice())
+	assert.Equal(t, []int{4, 2, 3, 1}, b.Union(a).Slice())
+	assert.Equal(t, []int{3, 2}, a.Intersection(b).Slice())
+	assert.Equal(t, []int{2, 3}, b.Intersection(a).Slice())
+	assert.Equal(t, []int{1}, a.Difference(b).Slice())
+	assert.Equal(t, []int{4}, b.Difference(a).Slice())
+	assert.Equal(t, []int{3, 1, 2}, a.Slice(), "operations must not modify the original set")
+
+	assert.True(t, collections.NewOrderedSet(2, 3).IsSubset(a))
+	assert.False(t, a.IsSubset(b))
+	assert.True(t, collections.NewOrderedSet[int]().IsSubset(a))
+	assert.True(t, a.Equal(collections.NewOrderedSet(1, 2, 3)))
+	assert.False(t, a.Equal(b))
+	assert.False(t, a.Equal(collections.NewOrderedSet(1, 2)))
+
+	var zero collections.OrderedSet[int]
+	assert.Equal(t, []int{3, 1, 2}, zero.Union(a).Slice())
+	assert.Equal(t, 0, a.Intersection(&zero).Len())
+	assert.True(t, zero.IsSubset(a))
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+// SortedSlice is a slice that keeps its items sorted according to the given comparator.
+//
+// Lookups use binary search and are O(log n), while inserts and removals are O(n) as they need to shift items.
+// Duplicate items are allowed and are kept in insertion order.
+type SortedSlice[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+// NewSortedSlice creates a new sorted slice. The less function must return true if a should be sorted before b.
+func NewSortedSlice[T any](less func(a, b T) bool) *SortedSlice[T] {
+	return &SortedSlice[T]{less: less}
+}
+
+// NewSortedSliceOrdered creates a new sorted slice that sorts items in ascending order.
+func NewSortedSliceOrdered[T cmp.Ordered]() *SortedSlice[T] {
+	return NewSortedSlice(cmp.Less[T])
+}
+
+// lowerBound returns the index of the first item that is not less than the given item.
+func (ss *SortedSlice[T]) lowerBound(item T) int {
+	return sort.Search(len(ss.items), func(i int) bool {
+		return !ss.less(ss.items[i], item)
+	})
+}
+
+// upperBound returns the index of the first item that is greater than the given item.
+func (ss *SortedSlice[T]) upperBound(item T) int {
+	return sort.Search(len(ss.items), func(i int) bool {
+		return ss.less(item, ss.items[i])
+	})
+}
+
+// Insert adds an item to the slice. If there are equal items already, the new item is inserted after them.
+func (ss *SortedSlice[T]) Insert(item T) {
+	ss.items = slices.Insert(ss.items, ss.upperBound(item), item)
+}
+
+// Remove removes the first item equal to the given item. The return value is true if an item was removed.
+func (ss *SortedSlice[T]) Remove(item T) bool {
+	idx := ss.IndexOf(item)
+	if idx < 0 {
+		return false
+	}
+	ss.items = slices.Delete(ss.items, idx, idx+1)
+	return true
+}
+
+// Contains checks if the slice has an item equal to the given item.
+func (ss *SortedSlice[T]) Contains(item T) bool {
+	return ss.IndexOf(item) >= 0
+}
+
+// IndexOf returns the index of the first item equal to the given item, or -1 if there's no such item.
+//
+// Items are considered equal if neither is less than the other.
+func (ss *SortedSlice[T]) IndexOf(item T) int {
+	idx := ss.lowerBound(item)
+	if idx < len(ss.items) && !ss.less(item, ss.items[idx]) {
+		return idx
+	}
+	return -1
+}
+
+// RangeQuery returns the items between lo and hi, both inclusive. The returned slice is a copy.
+func (ss *SortedSlice[T]) RangeQuery(lo, hi T) []T {
+	start := ss.lowerBound(lo)
+	end := ss.upperBound(hi)
+	if start >= end {
+		return nil
+	}
+	return slices.Clone(ss.items[start:end])
+}
+
+// Slice returns a copy of the items in sorted order.
+func (ss *SortedSlice[T]) Slice() []T {
+	return slices.Clone(ss.items)
+}
+
+// Len returns the number of items in the slice.
+func (ss *SortedSlice[T]) Len() int {
+	return len(ss.items)
+}
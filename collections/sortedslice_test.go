@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package collections_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/collections"
+)
+
+func TestSortedSlice(t *testing.T) {
+	ss := collections.NewSortedSliceOrdered[int]()
+	assert.Equal(t, 0, ss.Len())
+	assert.False(t, ss.Contains(1))
+	assert.Equal(t, -1, ss.IndexOf(1))
+	assert.False(t, ss.Remove(1))
+
+	items := rand.Perm(100)
+	for _, item := range items {
+		ss.Insert(item * 2)
+	}
+	assert.Equal(t, 100, ss.Len())
+	assert.True(t, slices.IsSorted(ss.Slice()))
+	assert.True(t, ss.Contains(42))
+	assert.False(t, ss.Contains(43))
+	assert.Equal(t, 21, ss.IndexOf(42))
+	assert.Equal(t, -1, ss.IndexOf(43))
+	assert.Equal(t, -1, ss.IndexOf(1000))
+
+	assert.True(t, ss.Remove(42))
+	assert.False(t, ss.Remove(42))
+	assert.False(t, ss.Contains(42))
+	assert.Equal(t, 99, ss.Len())
+	assert.Equal(t, 21, ss.IndexOf(44))
+}
+
+func TestSortedSlice_Duplicates(t *testing.T) {
+	type item struct {
+		key int
+		val string
+	}
+	ss := collections.NewSortedSlice(func(a, b item) bool { return a.key < b.key })
+	ss.Insert(item{2, "a"})
+	ss.Insert(item{1, "b"})
+	ss.Insert(item{2, "c"})
+	ss.Insert(item{3, "d"})
+	assert.Equal(t, []item{{1, "b"}, {2, "a"}, {2, "c"}, {3, "d"}}, ss.Slice())
+	assert.Equal(t, 1, ss.IndexOf(item{key: 2}))
+	assert.True(t, ss.Remove(item{key: 2}))
+	assert.Equal(t, []item{{1, "b"}, {2, "c"}, {3, "d"}}, ss.Slice())
+}
+
+func TestSortedSlice_RangeQuery(t *testing.T) {
+	ss := collections.NewSortedSliceOrdered[int]()
+	for _, item := range []int{5, 1, 9, 3, 7, 3} {
+		ss.Insert(item)
+	}
+	assert.Equal(t, []int{3, 3, 5, 7}, ss.RangeQuery(3, 7))
+	assert.Equal(t, []int{3, 3, 5}, ss.RangeQuery(2, 6))
+	assert.Equal(t, []int{1, 3, 3, 5, 7, 9}, ss.RangeQuery(0, 100))
+	assert.Nil(t, ss.RangeQuery(10, 20))
+	assert.Nil(t, ss.RangeQuery(6, 6))
+	assert.Nil(t, ss.RangeQuery(7, 3))
+
+	result := ss.RangeQuery(1, 1)
+	result[0] = 100
+	assert.Equal(t, []int{1, 3, 3, 5, 7, 9}, ss.Slice())
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ArrayColumnElement is the set of types that can be stored in an ArrayColumn.
+type ArrayColumnElement interface {
+	~string | ~int64 | ~float64
+}
+
+var ErrInvalidArrayLiteral = errors.New("invalid array literal")
+
+// ArrayColumn is a wrapper for storing lists in database columns.
+//
+// On Postgres, the list is stored as a native array (e.g. `text[]` or `bigint[]`). On other dialects,
+// it's stored as a JSON array in a TEXT (or JSON on MySQL) column. The dialect must be set before using
+// the value in a query, e.g. using NewArrayColumn. Scanning detects the format automatically, so it works with the zero value.
+//
+// Nil slices are stored as NULL and NULL is scanned into a nil slice, while empty slices are stored as empty arrays.
+type ArrayColumn[T ArrayColumnElement] struct {
+	Dialect Dialect
+	Data    []T
+}
+
+var (
+	_ sql.Scanner   = (*ArrayColumn[string])(nil)
+	_ driver.Valuer = ArrayColumn[string]{}
+)
+
+// NewArrayColumn creates an ArrayColumn for the given dialect, e.g. `dbutil.NewArrayColumn(db.Dialect, tags)`.
+func NewArrayColumn[T ArrayColumnElement](dialect Dialect, data []T) ArrayColumn[T] {
+	return ArrayColumn[T]{Dialect: dialect, Data: data}
+}
+
+func (ac ArrayColumn[T]) Value() (driver.Value, error) {
+	if ac.Data == nil {
+		return nil, nil
+	}
+	switch ac.Dialect {
+	case Postgres:
+		return formatPostgresArray(ac.Data), nil
+	case SQLite, MySQL:
+		data, err := json.Marshal(ac.Data)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return nil, fmt.Errorf("dbutil.ArrayColumn: unsupported dialect %q", ac.Dialect)
+	}
+}
+
+func (ac *ArrayColumn[T]) Scan(i any) error {
+	var value string
+	switch typedValue := i.(type) {
+	case nil:
+		ac.Data = nil
+		return nil
+	case string:
+		value = typedValue
+	case []byte:
+		value = string(typedValue)
+	default:
+		return fmt.Errorf("invalid type %T for dbutil.ArrayColumn.Scan", i)
+	}
+	var err error
+	if strings.HasPrefix(value, "[") {
+		var data []T
+		err = json.Unmarshal([]byte(value), &data)
+		if err == nil && data == nil {
+			data = []T{}
+		}
+		ac.Data = data
+	} else {
+		ac.Data, err = parsePostgresArray[T](value)
+	}
+	if err != nil {
+		ac.Data = nil
+		return fmt.Errorf("failed to parse array column: %w", err)
+	}
+	return nil
+}
+
+func formatPostgresArray[T ArrayColumnElement](data []T) string {
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, item := range data {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		val := reflect.ValueOf(item)
+		switch val.Kind() {
+		case reflect.String:
+			// Strings are always quoted to avoid having to special-case empty strings, NULL and whitespace
+			buf.WriteByte('"')
+			str := val.String()
+			for j := 0; j < len(str); j++ {
+				if str[j] == '"' || str[j] == '\\' {
+					buf.WriteByte('\\')
+				}
+				buf.WriteByte(str[j])
+			}
+			buf.WriteByte('"')
+		case reflect.Int64:
+			buf.WriteString(strconv.FormatInt(val.Int(), 10))
+		case reflect.Float64:
+			buf.WriteString(formatPostgresFloat(val.Float()))
+		}
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+func formatPostgresFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func parsePostgresArrayElement[T ArrayColumnElement](raw string) (item T, err error) {
+	val := reflect.ValueOf(&item).Elem()
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(raw)
+	case reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(raw, 10, 64)
+		val.SetInt(i)
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(raw, 64)
+		val.SetFloat(f)
+	}
+	return
+}
+
+// parsePostgresArray parses a one-dimensional Postgres array literal like `{foo,"bar baz"}`.
+func parsePostgresArray[T ArrayColumnElement](value string) ([]T, error) {
+	if len(value) < 2 || value[0] != '{' || value[len(value)-1] != '}' {
+		return nil, fmt.Errorf("%w: missing braces", ErrInvalidArrayLiteral)
+	}
+	value = value[1 : len(value)-1]
+	data := []T{}
+	if len(value) == 0 {
+		return data, nil
+	}
+	var elem strings.Builder
+	for i := 0; ; i++ {
+		elem.Reset()
+		quoted := false
+		if i < len(value) && value[i] == '"' {
+			quoted = true
+			for i++; ; i++ {
+				if i >= len(value) {
+					return nil, fmt.Errorf("%w: unterminated quoted element", ErrInvalidArrayLiteral)
+				} else if value[i] == '\\' && i+1 < len(value) {
+					i++
+				} else if value[i] == '"' {
+					i++
+					break
+				}
+				elem.WriteByte(value[i])
+			}
+		} else {
+			for ; i < len(value) && value[i] != ','; i++ {
+				if value[i] == '{' {
+					return nil, fmt.Errorf("%w: multidimensional arrays are not supported", ErrInvalidArrayLiteral)
+				} else if value[i] == '"' {
+					return nil, fmt.Errorf("%w: unexpected quote in unquoted element", ErrInvalidArrayLiteral)
+				}
+				elem.WriteByte(value[i])
+			}
+		}
+		raw := elem.String()
+		if !quoted {
+			raw = strings.TrimSpace(raw)
+			if strings.EqualFold(raw, "NULL") {
+				return nil, fmt.Errorf("%w: NULL elements are not supported", ErrInvalidArrayLiteral)
+			}
+		}
+		item, err := parsePostgresArrayElement[T](raw)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, item)
+		if i >= len(value) {
+			return data, nil
+		} else if value[i] != ',' {
+			return nil, fmt.Errorf("%w: unexpected %q after element", ErrInvalidArrayLiteral, value[i])
+		}
+	}
+}
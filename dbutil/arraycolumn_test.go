@@ -0,0 +1,146 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+type arrayTestID string
+
+func TestArrayColumn_PostgresText(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []string
+		literal string
+	}{
+		{"Nil", nil, ""},
+		{"Empty", []string{}, "{}"},
+		{"Simple", []string{"foo", "bar"}, `{"foo","bar"}`},
+		{"EmptyString", []string{""}, `{""}`},
+		{"Commas", []string{"a,b", ","}, `{"a,b",","}`},
+		{"Quotes", []string{`say "hi"`, `"`}, `{"say \"hi\"","\""}`},
+		{"Braces", []string{"{x}", "}{", "{"}, `{"{x}","}{","{"}`},
+		{"Backslashes", []string{`C:\dir\`, `\"`}, `{"C:\\dir\\","\\\""}`},
+		{"Whitespace", []string{" padded ", "tab\there"}, "{\" padded \",\"tab\there\"}"},
+		{"NullString", []string{"NULL", "null"}, `{"NULL","null"}`},
+		{"Unicode", []string{"\U0001f408", "\u00e4"}, "{\"\U0001f408\",\"\u00e4\"}"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := dbutil.NewArrayColumn(dbutil.Postgres, tc.data).Value()
+			require.NoError(t, err)
+			if tc.data == nil {
+				assert.Nil(t, value)
+				return
+			}
+			assert.Equal(t, tc.literal, value)
+			var parsed dbutil.ArrayColumn[string]
+			require.NoError(t, parsed.Scan([]byte(tc.literal)))
+			assert.Equal(t, tc.data, parsed.Data)
+		})
+	}
+}
+
+func TestArrayColumn_PostgresParse(t *testing.T) {
+	// Postgres only quotes elements when necessary in its output
+	for literal, expected := range map[string][]string{
+		`{foo,bar}`:                 {"foo", "bar"},
+		`{ foo , bar baz }`:         {"foo", "bar baz"},
+		`{"a,b",c,"{d}"}`:           {"a,b", "c", "{d}"},
+		`{"with \"quotes\"",plain}`: {`with "quotes"`, "plain"},
+		`{"back\\slash"}`:           {`back\slash`},
+		`{""}`:                      {""},
+	} {
+		var parsed dbutil.ArrayColumn[arrayTestID]
+		require.NoError(t, parsed.Scan(literal), literal)
+		assert.Len(t, parsed.Data, len(expected))
+		for i, item := range expected {
+			assert.Equal(t, arrayTestID(item), parsed.Data[i], literal)
+		}
+	}
+
+	for _, invalid := range []string{`foo`, `{`, `{"unterminated}`, `{a,NULL}`, `{{1,2},{3,4}}`, `{"a"b}`} {
+		parsed := dbutil.ArrayColumn[string]{Data: []string{"old"}}
+		assert.ErrorIs(t, parsed.Scan(invalid), dbutil.ErrInvalidArrayLiteral, invalid)
+		assert.Nil(t, parsed.Data)
+	}
+}
+
+func TestArrayColumn_PostgresNumbers(t *testing.T) {
+	value, err := dbutil.NewArrayColumn(dbutil.Postgres, []int64{1, -2, math.MaxInt64}).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "{1,-2,9223372036854775807}", value)
+	var ints dbutil.ArrayColumn[int64]
+	require.NoError(t, ints.Scan(value))
+	assert.Equal(t, []int64{1, -2, math.MaxInt64}, ints.Data)
+	assert.Error(t, ints.Scan("{1,abc}"))
+
+	value, err = dbutil.NewArrayColumn(dbutil.Postgres, []float64{1.5, -0.25, 1e100, math.Inf(1), math.Inf(-1)}).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "{1.5,-0.25,1e+100,Infinity,-Infinity}", value)
+	var floats dbutil.ArrayColumn[float64]
+	require.NoError(t, floats.Scan(value))
+	assert.Equal(t, []float64{1.5, -0.25, 1e100, math.Inf(1), math.Inf(-1)}, floats.Data)
+	require.NoError(t, floats.Scan("{NaN}"))
+	assert.True(t, math.IsNaN(floats.Data[0]))
+}
+
+func TestArrayColumn_SQLite(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, "CREATE TABLE arrays (id INTEGER PRIMARY KEY, tags TEXT, nums TEXT)")
+	require.NoError(t, err)
+	tags := []string{"a,b", `"quoted"`, "{braces}"}
+	_, err = db.Exec(ctx, "INSERT INTO arrays (id, tags, nums) VALUES (1, $1, $2), (2, $3, $4)",
+		dbutil.NewArrayColumn(db.Dialect, tags), dbutil.NewArrayColumn(db.Dialect, []int64{1, 2}),
+		dbutil.NewArrayColumn(db.Dialect, []string{}), dbutil.NewArrayColumn[int64](db.Dialect, nil))
+	require.NoError(t, err)
+
+	var rawTags string
+	err = db.QueryRow(ctx, "SELECT tags FROM arrays WHERE id=1").Scan(&rawTags)
+	require.NoError(t, err)
+	assert.Equal(t, `["a,b","\"quoted\"","{braces}"]`, rawTags)
+
+	var scannedTags dbutil.ArrayColumn[string]
+	var scannedNums dbutil.ArrayColumn[int64]
+	err = db.QueryRow(ctx, "SELECT tags, nums FROM arrays WHERE id=1").Scan(&scannedTags, &scannedNums)
+	require.NoError(t, err)
+	assert.Equal(t, tags, scannedTags.Data)
+	assert.Equal(t, []int64{1, 2}, scannedNums.Data)
+
+	err = db.QueryRow(ctx, "SELECT tags, nums FROM arrays WHERE id=2").Scan(&scannedTags, &scannedNums)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, scannedTags.Data)
+	assert.Nil(t, scannedNums.Data)
+
+	_, err = dbutil.NewArrayColumn(dbutil.DialectUnknown, tags).Value()
+	assert.Error(t, err)
+}
+
+func TestArrayColumn_MySQL(t *testing.T) {
+	value, err := dbutil.NewArrayColumn(dbutil.MySQL, []string{"a,b", `"quoted"`}).Value()
+	require.NoError(t, err)
+	assert.Equal(t, `["a,b","\"quoted\""]`, value)
+	var parsed dbutil.ArrayColumn[string]
+	require.NoError(t, parsed.Scan([]byte(value.(string))))
+	assert.Equal(t, []string{"a,b", `"quoted"`}, parsed.Data)
+
+	value, err = dbutil.NewArrayColumn(dbutil.MySQL, []int64{}).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "[]", value)
+	value, err = dbutil.NewArrayColumn[int64](dbutil.MySQL, nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
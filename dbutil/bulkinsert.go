@@ -0,0 +1,224 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBulkInsertNoColumns   = errors.New("bulk insert: no columns specified")
+	ErrBulkInsertRowLength   = errors.New("bulk insert: row has wrong number of values")
+	ErrBulkInsertCopyInvalid = errors.New("bulk insert: COPY is only supported on Postgres without conflict clauses")
+)
+
+// Parameter limits used by BulkInsert if BulkInsertOptions.MaxParams isn't set.
+const (
+	// SQLiteMaxParams is the parameter limit in SQLite versions older than 3.32.
+	// Newer versions allow 32766 by default, but the lower limit is used to be safe.
+	SQLiteMaxParams = 999
+	// PostgresMaxParams is the parameter limit of the Postgres wire protocol.
+	PostgresMaxParams = 65535
+	// MySQLMaxParams is the maximum number of placeholders in a MySQL prepared statement.
+	MySQLMaxParams = 65535
+)
+
+// BulkInsertConflict describes an `ON CONFLICT` clause for BulkInsert.
+type BulkInsertConflict struct {
+	// Columns is the conflict target. It may only be empty if Update is empty.
+	Columns []string
+	// Update contains the columns to overwrite with the new values when there's a conflict.
+	// If empty, conflicting rows are skipped (`DO NOTHING`).
+	Update []string
+}
+
+// BulkInsertOptions contains optional parameters for BulkInsert.
+type BulkInsertOptions struct {
+	// OnConflict is used to add an `ON CONFLICT` clause to the insert queries.
+	OnConflict *BulkInsertConflict
+	// MaxParams overrides the maximum number of parameters in a single query.
+	// By default, it's SQLiteMaxParams, PostgresMaxParams or MySQLMaxParams depending on the dialect.
+	MaxParams int
+	// UseCopy makes BulkInsert use `COPY FROM STDIN` instead of insert queries. This is only supported on Postgres
+	// with the lib/pq driver, and it can't be combined with OnConflict.
+	UseCopy bool
+}
+
+func (db *Database) bulkInsertMaxParams(opts *BulkInsertOptions) int {
+	if opts != nil && opts.MaxParams > 0 {
+		return opts.MaxParams
+	} else if db.Dialect == Postgres {
+		return PostgresMaxParams
+	} else if db.Dialect == MySQL {
+		return MySQLMaxParams
+	}
+	return SQLiteMaxParams
+}
+
+func (conflict *BulkInsertConflict) appendTo(query *strings.Builder) {
+	query.WriteString(" ON CONFLICT")
+	if len(conflict.Columns) > 0 {
+		query.WriteString(" (")
+		query.WriteString(strings.Join(conflict.Columns, ", "))
+		query.WriteByte(')')
+	}
+	if len(conflict.Update) == 0 {
+		query.WriteString(" DO NOTHING")
+		return
+	}
+	query.WriteString(" DO UPDATE SET ")
+	for i, col := range conflict.Update {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(col)
+		query.WriteString("=excluded.")
+		query.WriteString(col)
+	}
+}
+
+func buildBulkInsertQuery(table string, columns []string, rowCount int, conflict *BulkInsertConflict) string {
+	var query strings.Builder
+	query.WriteString("INSERT INTO ")
+	query.WriteString(table)
+	query.WriteString(" (")
+	query.WriteString(strings.Join(columns, ", "))
+	query.WriteString(") VALUES ")
+	param := 1
+	for i := 0; i < rowCount; i++ {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteByte('(')
+		for j := range columns {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('$')
+			query.WriteString(strconv.Itoa(param))
+			param++
+		}
+		query.WriteByte(')')
+	}
+	if conflict != nil {
+		conflict.appendTo(&query)
+	}
+	return query.String()
+}
+
+// BulkInsert inserts all the given rows into the given table and returns the total number of affected rows.
+//
+// Each row must contain one value for each column. The rows are split into as few queries as possible while
+// staying under the parameter limit of the database. The table and column names are inserted into the query as-is,
+// so they must not come from user input.
+//
+// The queries are executed inside a transaction: if the context already has one (i.e. this is called inside DoTxn),
+// a savepoint in that transaction is used, so either all rows are inserted or none are.
+func (db *Database) BulkInsert(ctx context.Context, table string, columns []string, rows iter.Seq[[]any], opts *BulkInsertOptions) (affected int64, err error) {
+	if len(columns) == 0 {
+		return 0, ErrBulkInsertNoColumns
+	} else if opts != nil && opts.UseCopy {
+		if db.Dialect != Postgres || opts.OnConflict != nil {
+			return 0, ErrBulkInsertCopyInvalid
+		}
+		err = db.DoTxn(ctx, nil, func(ctx context.Context) error {
+			affected, err = db.bulkCopy(ctx, table, columns, rows)
+			return err
+		})
+		return
+	}
+	var conflict *BulkInsertConflict
+	if opts != nil {
+		conflict = opts.OnConflict
+	}
+	if conflict != nil && db.Dialect == MySQL {
+		return 0, fmt.Errorf("%w: MySQL doesn't support ON CONFLICT clauses", ErrUnsupportedDialect)
+	}
+	rowsPerQuery := max(db.bulkInsertMaxParams(opts)/len(columns), 1)
+	err = db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		params := make([]any, 0, min(rowsPerQuery, 256)*len(columns))
+		var fullQuery string
+		flush := func() error {
+			rowCount := len(params) / len(columns)
+			if rowCount == 0 {
+				return nil
+			}
+			query := fullQuery
+			if rowCount < rowsPerQuery || fullQuery == "" {
+				query = buildBulkInsertQuery(table, columns, rowCount, conflict)
+				if rowCount == rowsPerQuery {
+					fullQuery = query
+				}
+			}
+			res, err := db.Exec(ctx, query, params...)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			affected += n
+			params = params[:0]
+			return nil
+		}
+		i := 0
+		for row := range rows {
+			if len(row) != len(columns) {
+				return fmt.Errorf("%w: row %d has %d values, expected %d", ErrBulkInsertRowLength, i, len(row), len(columns))
+			}
+			params = append(params, row...)
+			if len(params) == rowsPerQuery*len(columns) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			i++
+		}
+		return flush()
+	})
+	if err != nil {
+		affected = 0
+	}
+	return
+}
+
+func (db *Database) bulkCopy(ctx context.Context, table string, columns []string, rows iter.Seq[[]any]) (int64, error) {
+	txn, ok := db.Conn(ctx).(*LoggingTxn)
+	if !ok {
+		return 0, fmt.Errorf("bulk insert: unexpected transaction type %T", db.Conn(ctx))
+	}
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", "))
+	start := time.Now()
+	stmt, err := txn.UnderlyingTx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	i := 0
+	for row := range rows {
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("%w: row %d has %d values, expected %d", ErrBulkInsertRowLength, i, len(row), len(columns))
+		} else if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			return 0, err
+		}
+		i++
+	}
+	// Executing the statement without parameters finishes the copy
+	res, err := stmt.ExecContext(ctx)
+	db.Log.QueryTiming(ctx, "Copy", query, nil, i, time.Since(start), err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
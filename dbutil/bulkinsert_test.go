@@ -0,0 +1,145 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+type insertCountingLogger struct {
+	dbutil.DatabaseLogger
+	inserts int
+}
+
+func (icl *insertCountingLogger) QueryTiming(_ context.Context, method, query string, _ []any, _ int, _ time.Duration, _ error) {
+	if method == "Exec" && strings.HasPrefix(query, "INSERT") {
+		icl.inserts++
+	}
+}
+
+func newBulkInsertTestDB(t *testing.T) (*dbutil.Database, *insertCountingLogger) {
+	db := newTestSQLite(t)
+	_, err := db.Exec(context.Background(), "CREATE TABLE bar (id INTEGER PRIMARY KEY, name TEXT NOT NULL, value INTEGER)")
+	require.NoError(t, err)
+	logger := &insertCountingLogger{DatabaseLogger: dbutil.NoopLogger}
+	db.Log = logger
+	return db, logger
+}
+
+func bulkInsertTestRows(from, to int) iter.Seq[[]any] {
+	return func(yield func([]any) bool) {
+		for i := from; i < to; i++ {
+			if !yield([]any{i, fmt.Sprintf("row %d", i), i * 2}) {
+				return
+			}
+		}
+	}
+}
+
+func countBar(ctx context.Context, t *testing.T, db *dbutil.Database, query string) (count int) {
+	require.NoError(t, db.QueryRow(ctx, query).Scan(&count))
+	return
+}
+
+func TestDatabase_BulkInsert(t *testing.T) {
+	db, logger := newBulkInsertTestDB(t)
+	ctx := context.Background()
+	affected, err := db.BulkInsert(ctx, "bar", []string{"id", "name", "value"}, bulkInsertTestRows(0, 10000), nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10000, affected)
+	// 999 parameters / 3 columns = 333 rows per query
+	assert.Equal(t, 31, logger.inserts)
+	assert.Equal(t, 10000, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+	assert.Equal(t, 10000, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar WHERE value=id*2 AND name='row '||id"))
+}
+
+func TestDatabase_BulkInsert_ExactChunks(t *testing.T) {
+	db, logger := newBulkInsertTestDB(t)
+	opts := &dbutil.BulkInsertOptions{MaxParams: 30}
+	affected, err := db.BulkInsert(context.Background(), "bar", []string{"id", "name", "value"}, bulkInsertTestRows(0, 100), opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, affected)
+	assert.Equal(t, 10, logger.inserts)
+
+	affected, err = db.BulkInsert(context.Background(), "bar", []string{"id", "name", "value"}, bulkInsertTestRows(0, 0), opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+	assert.Equal(t, 10, logger.inserts)
+}
+
+func TestDatabase_BulkInsert_OnConflict(t *testing.T) {
+	db, _ := newBulkInsertTestDB(t)
+	ctx := context.Background()
+	columns := []string{"id", "name", "value"}
+	_, err := db.BulkInsert(ctx, "bar", columns, bulkInsertTestRows(0, 10), nil)
+	require.NoError(t, err)
+
+	_, err = db.BulkInsert(ctx, "bar", columns, bulkInsertTestRows(5, 15), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 10, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+
+	affected, err := db.BulkInsert(ctx, "bar", columns, bulkInsertTestRows(5, 15), &dbutil.BulkInsertOptions{
+		OnConflict: &dbutil.BulkInsertConflict{},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, affected)
+	assert.Equal(t, 15, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+
+	rows := slices.Values([][]any{{1, "updated", 100}, {20, "new", 200}})
+	affected, err = db.BulkInsert(ctx, "bar", columns, rows, &dbutil.BulkInsertOptions{
+		OnConflict: &dbutil.BulkInsertConflict{Columns: []string{"id"}, Update: []string{"name"}},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+	var name string
+	var value int
+	require.NoError(t, db.QueryRow(ctx, "SELECT name, value FROM bar WHERE id=1").Scan(&name, &value))
+	assert.Equal(t, "updated", name)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 16, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+}
+
+func TestDatabase_BulkInsert_CallerTransaction(t *testing.T) {
+	db, _ := newBulkInsertTestDB(t)
+	ctx := context.Background()
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		_, err := db.BulkInsert(ctx, "bar", []string{"id", "name", "value"}, bulkInsertTestRows(0, 1000), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+		return errInner
+	})
+	assert.ErrorIs(t, err, errInner)
+	assert.Equal(t, 0, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"))
+}
+
+func TestDatabase_BulkInsert_Invalid(t *testing.T) {
+	db, logger := newBulkInsertTestDB(t)
+	ctx := context.Background()
+	_, err := db.BulkInsert(ctx, "bar", nil, bulkInsertTestRows(0, 1), nil)
+	assert.ErrorIs(t, err, dbutil.ErrBulkInsertNoColumns)
+
+	rows := slices.Values([][]any{{1, "a", 1}, {2, "b"}})
+	affected, err := db.BulkInsert(ctx, "bar", []string{"id", "name", "value"}, rows, &dbutil.BulkInsertOptions{MaxParams: 3})
+	assert.ErrorIs(t, err, dbutil.ErrBulkInsertRowLength)
+	assert.EqualValues(t, 0, affected)
+	assert.Equal(t, 1, logger.inserts)
+	assert.Equal(t, 0, countBar(ctx, t, db, "SELECT COUNT(*) FROM bar"), "partial inserts must be rolled back")
+
+	_, err = db.BulkInsert(ctx, "bar", []string{"id"}, bulkInsertTestRows(0, 1), &dbutil.BulkInsertOptions{UseCopy: true})
+	assert.ErrorIs(t, err, dbutil.ErrBulkInsertCopyInvalid)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+func makeChecksumTestTable(secondUpgrade string) (ut dbutil.UpgradeTable) {
+	ut.RegisterFS(fstest.MapFS{
+		"01-users.sql": {Data: []byte("-- v1: Create users\nCREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"02-rooms.sql": {Data: []byte("-- v2: Create rooms\n" + secondUpgrade)},
+	})
+	ut.Register(2, 3, 0, "Go upgrade", true, execUpgrade("CREATE TABLE go_upgrade (id INTEGER)"))
+	ut.SetFingerprint(2, "go-upgrade-1")
+	return
+}
+
+func getChecksums(t *testing.T, db *dbutil.Database) sql.NullString {
+	var checksums sql.NullString
+	err := db.QueryRow(context.Background(), "SELECT checksums FROM version").Scan(&checksums)
+	require.NoError(t, err)
+	return checksums
+}
+
+func TestDatabase_Upgrade_Checksums(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	db.UpgradeTable = makeChecksumTestTable("CREATE TABLE rooms (id INTEGER PRIMARY KEY);")
+	require.NoError(t, db.Upgrade(ctx))
+	checksums := getChecksums(t, db)
+	assert.True(t, checksums.Valid)
+	assert.Contains(t, checksums.String, `"2":"go-upgrade-1"`)
+
+	// Upgrading again with the same table is a no-op
+	require.NoError(t, db.Upgrade(ctx))
+	assert.Equal(t, checksums, getChecksums(t, db))
+
+	db.UpgradeTable = makeChecksumTestTable("CREATE TABLE rooms (id INTEGER PRIMARY KEY, name TEXT);")
+	// Mismatches are only logged by default
+	require.NoError(t, db.Upgrade(ctx))
+	db.StrictUpgradeChecksums = true
+	err := db.Upgrade(ctx)
+	assert.ErrorIs(t, err, dbutil.ErrUpgradeChecksumMismatch)
+	assert.ErrorContains(t, err, "v1->v2 (Create rooms)")
+
+	db.UpgradeTable = makeChecksumTestTable("CREATE TABLE rooms (id INTEGER PRIMARY KEY);")
+	db.UpgradeTable.SetFingerprint(2, "go-upgrade-2")
+	err = db.Upgrade(ctx)
+	assert.ErrorIs(t, err, dbutil.ErrUpgradeChecksumMismatch)
+	assert.ErrorContains(t, err, "v2->v3 (Go upgrade)")
+}
+
+func TestDatabase_Upgrade_LegacyVersionTable(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	db.StrictUpgradeChecksums = true
+	_, err := db.Exec(ctx, "CREATE TABLE version (version INTEGER, compat INTEGER)")
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE rooms (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, "INSERT INTO version (version, compat) VALUES (2, 2)")
+	require.NoError(t, err)
+
+	db.UpgradeTable = makeChecksumTestTable("CREATE TABLE rooms (id INTEGER PRIMARY KEY);")
+	require.NoError(t, db.Upgrade(ctx))
+	exists, err := db.TableExists(ctx, "go_upgrade")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, sql.NullString{String: `{"2":"go-upgrade-1"}`, Valid: true}, getChecksums(t, db))
+
+	// Upgrades applied before checksums were stored aren't verified
+	db.UpgradeTable = makeChecksumTestTable("CREATE TABLE rooms (id INTEGER PRIMARY KEY, name TEXT);")
+	require.NoError(t, db.Upgrade(ctx))
+}
+
+func TestDatabase_DowngradeTo_RemovesChecksums(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	db.UpgradeTable.RegisterWithRevert(0, 1, 0, "Create users", true,
+		execUpgrade("CREATE TABLE users (id INTEGER PRIMARY KEY)"),
+		execUpgrade("DROP TABLE users"))
+	db.UpgradeTable.SetFingerprint(0, "users")
+	require.NoError(t, db.Upgrade(ctx))
+	assert.Equal(t, sql.NullString{String: `{"0":"users"}`, Valid: true}, getChecksums(t, db))
+	require.NoError(t, db.DowngradeTo(ctx, 0))
+	assert.False(t, getChecksums(t, db).Valid)
+}
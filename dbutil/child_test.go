@@ -0,0 +1,72 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+func makeChildUpgradeTable(prefix string, count int) (ut dbutil.UpgradeTable) {
+	for i := 0; i < count; i++ {
+		ut.Register(i, i+1, 0, fmt.Sprintf("Create %s_%d", prefix, i), true,
+			execUpgrade(fmt.Sprintf("CREATE TABLE %s_%d (id INTEGER PRIMARY KEY)", prefix, i)))
+	}
+	return
+}
+
+func TestDatabase_Child_ConcurrentUpgrade(t *testing.T) {
+	// Disable the busy timeout, so concurrent write transactions fail immediately instead of waiting for each other
+	db, err := dbutil.NewWithDialect(filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=0", "sqlite3")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	ctx := context.Background()
+	children := []*dbutil.Database{
+		db.Child("alpha_version", makeChildUpgradeTable("alpha", 20), nil),
+		db.Child("beta_version", makeChildUpgradeTable("beta", 30), nil),
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(children))
+	for i, child := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = child.Upgrade(ctx)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	for table, expectedVersion := range map[string]int{"alpha_version": 20, "beta_version": 30} {
+		var version, compat int
+		err := db.QueryRow(ctx, fmt.Sprintf("SELECT version, compat FROM %s", table)).Scan(&version, &compat)
+		require.NoError(t, err)
+		assert.Equal(t, expectedVersion, version, table)
+		assert.Equal(t, expectedVersion, compat, table)
+	}
+	var exists bool
+	for _, table := range []string{"alpha_19", "beta_29"} {
+		exists, err = db.TableExists(ctx, table)
+		require.NoError(t, err)
+		assert.True(t, exists, table)
+	}
+	exists, err = db.TableExists(ctx, "version")
+	require.NoError(t, err)
+	assert.False(t, exists, "parent version table shouldn't be created by children")
+}
@@ -65,19 +65,34 @@ func addErrorLine(query string, err error) error {
 
 func (le *LoggingExecable) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	start := time.Now()
-	query = le.db.mutateQuery(query)
-	res, err := le.UnderlyingExecable.ExecContext(ctx, query, args...)
+	query, args = le.db.mutateQuery(query, args)
+	res, err := le.execWithCache(ctx, query, args)
 	err = addErrorLine(query, err)
-	le.db.Log.QueryTiming(ctx, "Exec", query, args, -1, time.Since(start), err)
+	duration := time.Since(start)
+	le.db.Log.QueryTiming(ctx, "Exec", query, args, -1, duration, err)
+	if le.db.SlowQueryThreshold > 0 && duration >= le.db.SlowQueryThreshold {
+		nrows := int64(-1)
+		if err == nil {
+			if affected, rowsErr := res.RowsAffected(); rowsErr == nil {
+				nrows = affected
+			}
+		}
+		le.db.logSlowQuery(ctx, "Exec", query, nrows, duration, err)
+	}
 	return res, err
 }
 
 func (le *LoggingExecable) QueryContext(ctx context.Context, query string, args ...any) (Rows, error) {
 	start := time.Now()
-	query = le.db.mutateQuery(query)
-	rows, err := le.UnderlyingExecable.QueryContext(ctx, query, args...)
+	query, args = le.db.mutateQuery(query, args)
+	rows, err := le.queryWithCache(ctx, query, args)
 	err = addErrorLine(query, err)
-	le.db.Log.QueryTiming(ctx, "Query", query, args, -1, time.Since(start), err)
+	duration := time.Since(start)
+	le.db.Log.QueryTiming(ctx, "Query", query, args, -1, duration, err)
+	// Successful queries are checked when the rows are closed, so the time spent reading rows is included
+	if err != nil && le.db.SlowQueryThreshold > 0 && duration >= le.db.SlowQueryThreshold {
+		le.db.logSlowQuery(ctx, "Query", query, -1, duration, err)
+	}
 	return &LoggingRows{
 		ctx:   ctx,
 		db:    le.db,
@@ -90,9 +105,13 @@ func (le *LoggingExecable) QueryContext(ctx context.Context, query string, args
 
 func (le *LoggingExecable) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
 	start := time.Now()
-	query = le.db.mutateQuery(query)
-	row := le.UnderlyingExecable.QueryRowContext(ctx, query, args...)
-	le.db.Log.QueryTiming(ctx, "QueryRow", query, args, -1, time.Since(start), nil)
+	query, args = le.db.mutateQuery(query, args)
+	row := le.queryRowWithCache(ctx, query, args)
+	duration := time.Since(start)
+	le.db.Log.QueryTiming(ctx, "QueryRow", query, args, -1, duration, nil)
+	if le.db.SlowQueryThreshold > 0 && duration >= le.db.SlowQueryThreshold {
+		le.db.logSlowQuery(ctx, "QueryRow", query, -1, duration, row.Err())
+	}
 	return row
 }
 
@@ -120,6 +139,7 @@ func (ld *loggingDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Logging
 		UnderlyingTx:    tx,
 		ctx:             ctx,
 		StartTime:       start,
+		ReadOnly:        opts != nil && opts.ReadOnly,
 	}, nil
 }
 
@@ -131,6 +151,17 @@ type LoggingTxn struct {
 	StartTime  time.Time
 	EndTime    time.Time
 	noTotalLog bool
+
+	// ReadOnly is true if the transaction was started with the ReadOnly option.
+	// Exec calls on read-only transactions fail with ErrTxnReadOnly without sending the query to the database.
+	ReadOnly bool
+}
+
+func (lt *LoggingTxn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if lt.ReadOnly {
+		return nil, ErrTxnReadOnly
+	}
+	return lt.LoggingExecable.ExecContext(ctx, query, args...)
 }
 
 func (lt *LoggingTxn) Commit() error {
@@ -167,7 +198,11 @@ type LoggingRows struct {
 
 func (lrs *LoggingRows) stopTiming() {
 	if !lrs.start.IsZero() {
-		lrs.db.Log.QueryTiming(lrs.ctx, "EndRows", lrs.query, lrs.args, lrs.nrows, time.Since(lrs.start), lrs.rs.Err())
+		duration := time.Since(lrs.start)
+		lrs.db.Log.QueryTiming(lrs.ctx, "EndRows", lrs.query, lrs.args, lrs.nrows, duration, lrs.rs.Err())
+		if lrs.db.SlowQueryThreshold > 0 && duration >= lrs.db.SlowQueryThreshold {
+			lrs.db.logSlowQuery(lrs.ctx, "Query", lrs.query, int64(lrs.nrows), duration, lrs.rs.Err())
+		}
 		lrs.start = time.Time{}
 	}
 }
@@ -11,8 +11,8 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,6 +22,7 @@ const (
 	DialectUnknown Dialect = iota
 	Postgres
 	SQLite
+	MySQL
 )
 
 func (dialect Dialect) String() string {
@@ -30,6 +31,8 @@ func (dialect Dialect) String() string {
 		return "postgres"
 	case SQLite:
 		return "sqlite3"
+	case MySQL:
+		return "mysql"
 	default:
 		return ""
 	}
@@ -42,6 +45,8 @@ func ParseDialect(engine string) (Dialect, error) {
 		return Postgres, nil
 	} else if strings.HasPrefix(engine, "sqlite") || strings.HasPrefix(engine, "litestream") {
 		return SQLite, nil
+	} else if strings.HasPrefix(engine, "mysql") || strings.HasPrefix(engine, "mariadb") {
+		return MySQL, nil
 	} else {
 		return DialectUnknown, fmt.Errorf("unknown dialect '%s'", engine)
 	}
@@ -105,22 +110,57 @@ type Database struct {
 	UpgradeTable UpgradeTable
 
 	txnCtxKey contextKey
+	// upgradeLock is shared between a database and its children to prevent concurrent upgrades.
+	upgradeLock *sync.Mutex
 
 	IgnoreForeignTables       bool
 	IgnoreUnsupportedDatabase bool
+	// StrictUpgradeChecksums makes Upgrade return an error instead of logging a warning if an upgrade that has
+	// already been applied has been modified since (i.e. its checksum doesn't match the one in the version table).
+	StrictUpgradeChecksums bool
+
+	// SlowQueryThreshold enables logging queries that take longer than the given duration along with the location
+	// in the code where the query was made. The log is written using the logger in the context (zerolog.Ctx).
+	// Zero disables the slow query log.
+	//
+	// For Query, the time spent reading rows is included. QueryRow only measures the time until the database driver
+	// returns, which may not include computing the row (e.g. on SQLite).
+	SlowQueryThreshold time.Duration
+	// SlowQueryMaxLength is the maximum length of queries in the slow query log. Longer queries are truncated.
+	// Defaults to 1000 if zero, negative values disable truncation.
+	SlowQueryMaxLength int
+
+	// TxnWatchdogThreshold makes DoTxn log a warning if a transaction has been open for longer than the given
+	// duration, repeating at the same interval until the transaction ends. The warning includes the stack trace
+	// of where the transaction was started, which is only captured when this is set. If zero, a warning without
+	// a stack trace is logged every 5 seconds.
+	TxnWatchdogThreshold time.Duration
+	// TxnDeadline makes DoTxn cancel the context of transactions that have been open for longer than the given
+	// duration, which rolls back the transaction. The error returned by DoTxn will match ErrTxnDeadline.
+	// Zero disables the deadline.
+	TxnDeadline time.Duration
+
+	// PoolStatsHook receives connection pool statistics from the stats logger started with StartStatsLogger.
+	PoolStatsHook PoolStatsHook
+	// NotificationWaiterFunc is used by Listen to wait for notifications on the raw driver connection.
+	// If nil, the driver connection must implement NotificationWaiter itself.
+	NotificationWaiterFunc NotificationWaiterFunc
+
+	// health is the status updated by StartHealthCheck. It's shared between a database and its children.
+	health *healthStatus
+	// stmtCache is the prepared statement cache enabled with SetStatementCacheSize.
+	// It's shared between a database and its children.
+	stmtCache *stmtCache
+	// mungeCache contains queries with placeholders converted for the dialect. It's shared between a database
+	// and its children, as they always have the same dialect.
+	mungeCache *mungeCache
 }
 
-var positionalParamPattern = regexp.MustCompile(`\$(\d+)`)
-
-func (db *Database) mutateQuery(query string) string {
-	switch db.Dialect {
-	case SQLite:
-		return positionalParamPattern.ReplaceAllString(query, "?$1")
-	default:
-		return query
-	}
-}
-
+// Child creates a new Database that shares the connection pool with this one, but uses a different version table
+// and upgrade table. This allows multiple independent components to have their own schema versioning in one database.
+//
+// Upgrades of a database and all its children are serialized, so they can be safely called concurrently
+// (e.g. from separate components starting up in parallel) even on SQLite.
 func (db *Database) Child(versionTable string, upgradeTable UpgradeTable, log DatabaseLogger) *Database {
 	if log == nil {
 		log = db.Log
@@ -134,10 +174,21 @@ func (db *Database) Child(versionTable string, upgradeTable UpgradeTable, log Da
 		Log:          log,
 		Dialect:      db.Dialect,
 
-		txnCtxKey: db.txnCtxKey,
+		txnCtxKey:   db.txnCtxKey,
+		upgradeLock: db.upgradeLock,
+		health:      db.health,
+		stmtCache:   db.stmtCache,
+		mungeCache:  db.mungeCache,
 
 		IgnoreForeignTables:       true,
 		IgnoreUnsupportedDatabase: db.IgnoreUnsupportedDatabase,
+		StrictUpgradeChecksums:    db.StrictUpgradeChecksums,
+		SlowQueryThreshold:        db.SlowQueryThreshold,
+		SlowQueryMaxLength:        db.SlowQueryMaxLength,
+		TxnWatchdogThreshold:      db.TxnWatchdogThreshold,
+		TxnDeadline:               db.TxnDeadline,
+		PoolStatsHook:             db.PoolStatsHook,
+		NotificationWaiterFunc:    db.NotificationWaiterFunc,
 	}
 }
 
@@ -154,7 +205,10 @@ func NewWithDB(db *sql.DB, rawDialect string) (*Database, error) {
 		IgnoreForeignTables: true,
 		VersionTable:        "version",
 
-		txnCtxKey: contextKey(nextContextKeyDatabaseTransaction.Add(1)),
+		txnCtxKey:   contextKey(nextContextKeyDatabaseTransaction.Add(1)),
+		upgradeLock: &sync.Mutex{},
+		health:      &healthStatus{},
+		mungeCache:  &mungeCache{},
 	}
 	wrappedDB.LoggingDB.UnderlyingExecable = db
 	wrappedDB.LoggingDB.db = wrappedDB
@@ -187,6 +241,7 @@ type Config struct {
 }
 
 func (db *Database) Close() error {
+	db.stmtCache.clear()
 	err := db.RawDB.Close()
 	if db.ReadOnlyDB != nil {
 		if err2 := db.ReadOnlyDB.Close(); err2 != nil {
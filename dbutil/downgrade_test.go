@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+func execUpgrade(query string) func(context.Context, *dbutil.Database) error {
+	return func(ctx context.Context, db *dbutil.Database) error {
+		_, err := db.Exec(ctx, query)
+		return err
+	}
+}
+
+func makeRevertableUpgradeTable(withMissingRevert bool) (ut dbutil.UpgradeTable) {
+	ut.RegisterWithRevert(0, 1, 0, "Create users", true,
+		execUpgrade("CREATE TABLE users (id INTEGER PRIMARY KEY)"),
+		execUpgrade("DROP TABLE users"))
+	ut.RegisterWithRevert(1, 2, 0, "Add name column", true,
+		execUpgrade("ALTER TABLE users ADD COLUMN name TEXT"),
+		execUpgrade("ALTER TABLE users DROP COLUMN name"))
+	if withMissingRevert {
+		ut.Register(2, 3, 1, "Create rooms", true, execUpgrade("CREATE TABLE rooms (id INTEGER PRIMARY KEY)"))
+	} else {
+		ut.RegisterWithRevert(2, 3, 1, "Create rooms", true,
+			execUpgrade("CREATE TABLE rooms (id INTEGER PRIMARY KEY)"),
+			execUpgrade("DROP TABLE rooms"))
+	}
+	ut.RegisterWithRevert(3, 4, 3, "Add topic column", true,
+		execUpgrade("ALTER TABLE rooms ADD COLUMN topic TEXT"),
+		execUpgrade("ALTER TABLE rooms DROP COLUMN topic"))
+	return
+}
+
+func newDowngradeTestDB(t *testing.T, withMissingRevert bool) *dbutil.Database {
+	db := newTestSQLite(t)
+	db.UpgradeTable = makeRevertableUpgradeTable(withMissingRevert)
+	require.NoError(t, db.Upgrade(context.Background()))
+	return db
+}
+
+func assertVersion(t *testing.T, db *dbutil.Database, version, compat int) {
+	var actualVersion, actualCompat int
+	err := db.QueryRow(context.Background(), "SELECT version, compat FROM version").Scan(&actualVersion, &actualCompat)
+	require.NoError(t, err)
+	assert.Equal(t, version, actualVersion, "version")
+	assert.Equal(t, compat, actualCompat, "compat")
+}
+
+func assertSchema(t *testing.T, db *dbutil.Database, table, column string, exists bool) {
+	var actual bool
+	var err error
+	if column == "" {
+		actual, err = db.TableExists(context.Background(), table)
+	} else {
+		actual, err = db.ColumnExists(context.Background(), table, column)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, exists, actual, "existence of %s.%s", table, column)
+}
+
+func TestDatabase_DowngradeTo(t *testing.T) {
+	db := newDowngradeTestDB(t, false)
+	ctx := context.Background()
+	assertVersion(t, db, 4, 3)
+	assertSchema(t, db, "rooms", "topic", true)
+
+	require.NoError(t, db.DowngradeTo(ctx, 2))
+	assertVersion(t, db, 2, 2)
+	assertSchema(t, db, "rooms", "", false)
+	assertSchema(t, db, "users", "name", true)
+
+	require.NoError(t, db.Upgrade(ctx))
+	assertVersion(t, db, 4, 3)
+	assertSchema(t, db, "rooms", "topic", true)
+
+	require.NoError(t, db.DowngradeTo(ctx, 4))
+	assertVersion(t, db, 4, 3)
+
+	require.NoError(t, db.DowngradeTo(ctx, 0))
+	assertVersion(t, db, 0, 0)
+	assertSchema(t, db, "users", "", false)
+}
+
+func TestDatabase_DowngradeTo_MissingRevert(t *testing.T) {
+	db := newDowngradeTestDB(t, true)
+	ctx := context.Background()
+	require.NoError(t, db.DowngradeTo(ctx, 3))
+	assertVersion(t, db, 3, 1)
+	assertSchema(t, db, "rooms", "topic", false)
+
+	require.NoError(t, db.Upgrade(ctx))
+	err := db.DowngradeTo(ctx, 1)
+	assert.ErrorIs(t, err, dbutil.ErrNoRevert)
+	assert.ErrorContains(t, err, "v2->v3 (Create rooms)")
+	assertVersion(t, db, 4, 3)
+	assertSchema(t, db, "rooms", "topic", true)
+}
+
+func TestDatabase_DowngradeTo_FailedRevert(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	db.UpgradeTable = makeRevertableUpgradeTable(false)
+	db.UpgradeTable.RegisterWithRevert(4, 5, 0, "Broken revert", true,
+		execUpgrade("CREATE TABLE broken (id INTEGER)"),
+		execUpgrade("DROP TABLE nonexistent"))
+	require.NoError(t, db.Upgrade(ctx))
+
+	assert.Error(t, db.DowngradeTo(ctx, 3))
+	assertVersion(t, db, 5, 5)
+	assertSchema(t, db, "broken", "", true)
+}
+
+func TestDatabase_DowngradeTo_Invalid(t *testing.T) {
+	db := newDowngradeTestDB(t, false)
+	ctx := context.Background()
+	assert.ErrorIs(t, db.DowngradeTo(ctx, 5), dbutil.ErrInvalidDowngrade)
+	assert.ErrorIs(t, db.DowngradeTo(ctx, -1), dbutil.ErrInvalidDowngrade)
+
+	db.UpgradeTable = nil
+	db.UpgradeTable.RegisterWithRevert(0, 4, 0, "Jump", true, execUpgrade(""), execUpgrade(""))
+	assert.ErrorIs(t, db.DowngradeTo(ctx, 2), dbutil.ErrInvalidDowngrade)
+	assertVersion(t, db, 4, 3)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// MySQL error numbers used for classifying errors.
+// See https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrDupEntry            = 1062
+	mysqlErrDupEntryWithKeyName = 1586
+	mysqlErrLockWaitTimeout     = 1205
+	mysqlErrLockDeadlock        = 1213
+)
+
+// walkErrors calls fn for each error in the tree of err (like errors.As) until fn returns true.
+func walkErrors(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err = range x.Unwrap() {
+				if walkErrors(err, fn) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// mysqlErrorNumber returns the error number of the first MySQL server error (*mysql.MySQLError from
+// go-sql-driver/mysql) in the tree of err. The driver isn't a dependency of this package, so the error type is
+// detected by its name and Number field.
+func mysqlErrorNumber(err error) (number uint16, ok bool) {
+	ok = walkErrors(err, func(err error) bool {
+		val := reflect.ValueOf(err)
+		if val.Kind() == reflect.Pointer && !val.IsNil() {
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct || val.Type().Name() != "MySQLError" {
+			return false
+		}
+		field := val.FieldByName("Number")
+		if field.Kind() != reflect.Uint16 {
+			return false
+		}
+		number = uint16(field.Uint())
+		return true
+	})
+	return
+}
+
+// IsUniqueViolation checks if the given error was caused by a unique or primary key constraint violation.
+//
+// This supports unique_violation (23505) errors from Postgres drivers, constraint errors from SQLite and
+// duplicate entry errors (1062 and 1586) from go-sql-driver/mysql.
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) && stateErr.SQLState() == "23505" {
+		return true
+	}
+	if number, ok := mysqlErrorNumber(err); ok {
+		return number == mysqlErrDupEntry || number == mysqlErrDupEntryWithKeyName
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "PRIMARY KEY must be unique")
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/exerrors"
+)
+
+// MySQLError has the same name and fields as the error type of go-sql-driver/mysql.
+type MySQLError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (me *MySQLError) Error() string {
+	return fmt.Sprintf("Error %d (%s): %s", me.Number, me.SQLState, me.Message)
+}
+
+func TestMySQLErrorNumber(t *testing.T) {
+	number, ok := mysqlErrorNumber(&MySQLError{Number: 1062})
+	assert.True(t, ok)
+	assert.EqualValues(t, 1062, number)
+	number, ok = mysqlErrorNumber(fmt.Errorf("failed to insert: %w", &MySQLError{Number: 1213}))
+	assert.True(t, ok)
+	assert.EqualValues(t, 1213, number)
+	number, ok = mysqlErrorNumber(exerrors.NewDualError(context.Canceled, &MySQLError{Number: 1205}))
+	assert.True(t, ok)
+	assert.EqualValues(t, 1205, number)
+	number, ok = mysqlErrorNumber(errors.Join(errors.New("foo"), fmt.Errorf("bar: %w", &MySQLError{Number: 1586})))
+	assert.True(t, ok)
+	assert.EqualValues(t, 1586, number)
+
+	_, ok = mysqlErrorNumber(nil)
+	assert.False(t, ok)
+	_, ok = mysqlErrorNumber(errors.New("Error 1062 (23000): Duplicate entry '1' for key 'PRIMARY'"))
+	assert.False(t, ok)
+	_, ok = mysqlErrorNumber((*MySQLError)(nil))
+	assert.False(t, ok)
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	assert.False(t, IsUniqueViolation(nil))
+	assert.False(t, IsUniqueViolation(errors.New("syntax error")))
+	assert.True(t, IsUniqueViolation(fakeSQLStateError("23505")))
+	assert.False(t, IsUniqueViolation(fakeSQLStateError("23503")))
+	assert.True(t, IsUniqueViolation(fmt.Errorf("failed to insert: %w", fakeSQLStateError("23505"))))
+	assert.True(t, IsUniqueViolation(&MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}))
+	assert.True(t, IsUniqueViolation(&MySQLError{Number: 1586, Message: "Duplicate entry '1' for key 'foo'"}))
+	assert.False(t, IsUniqueViolation(&MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}))
+	// The message isn't checked for MySQL errors
+	assert.False(t, IsUniqueViolation(&MySQLError{Number: 1064, Message: "UNIQUE constraint failed"}))
+
+	db, err := NewWithDialect(filepath.Join(t.TempDir(), "test.db"), "sqlite3")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	_, err = db.Exec(ctx, "CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT UNIQUE)")
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, "INSERT INTO foo (id, name) VALUES (1, 'a')")
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, "INSERT INTO foo (id, name) VALUES (1, 'b')")
+	assert.True(t, IsUniqueViolation(err), err)
+	_, err = db.Exec(ctx, "INSERT INTO foo (id, name) VALUES (2, 'a')")
+	assert.True(t, IsUniqueViolation(err), err)
+	_, err = db.Exec(ctx, "INSERT INTO foo (id, nonexistent) VALUES (3, 'c')")
+	assert.False(t, IsUniqueViolation(err), err)
+}
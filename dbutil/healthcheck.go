@@ -0,0 +1,123 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// HealthCheckConfig contains the settings for the background health check started with StartHealthCheck.
+type HealthCheckConfig struct {
+	// Interval is the time between pings. Must be positive.
+	Interval time.Duration
+	// Timeout is the maximum duration of a single ping. Defaults to Interval if zero.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed pings after which the database is considered unhealthy.
+	// Defaults to 3 if zero.
+	FailureThreshold int
+
+	// OnUnhealthy is called when the database becomes unhealthy with the error from the latest ping.
+	OnUnhealthy func(err error)
+	// OnRecovered is called when a ping succeeds after the database was unhealthy.
+	OnRecovered func()
+}
+
+type healthStatus struct {
+	unhealthy atomic.Bool
+}
+
+// Healthy returns false if the health check started with StartHealthCheck has marked the database as unhealthy.
+// If the health check isn't running, the database is always considered healthy.
+//
+// This is meant for readiness probes and such. Queries should still handle errors normally,
+// as the status is only updated on the health check interval.
+func (db *Database) Healthy() bool {
+	return db.health == nil || !db.health.unhealthy.Load()
+}
+
+// StartHealthCheck starts a goroutine that pings the database at the configured interval and updates the status
+// returned by Healthy. State transitions are logged using the logger in the context (zerolog.Ctx).
+//
+// The health check doesn't reconnect by itself, as the connection pool in database/sql already discards broken
+// connections and opens new ones as necessary. However, pinging regularly lets the pool notice broken connections
+// (e.g. after the database server restarts) before they're used for real queries, and the status can be used
+// to stop serving requests while the database is down.
+//
+// The goroutine stops when the context is canceled. The returned channel is closed after it has stopped.
+func (db *Database) StartHealthCheck(ctx context.Context, cfg HealthCheckConfig) <-chan struct{} {
+	if cfg.Interval <= 0 {
+		panic("dbutil: StartHealthCheck called with non-positive interval")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = cfg.Interval
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = 3
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				failures = db.checkHealth(ctx, &cfg, failures)
+			}
+		}
+	}()
+	return done
+}
+
+func (db *Database) ping(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return db.RawDB.PingContext(ctx)
+}
+
+func (db *Database) setHealthy(healthy bool) (changed bool) {
+	if db.health == nil {
+		return false
+	}
+	return db.health.unhealthy.Swap(!healthy) == healthy
+}
+
+func (db *Database) checkHealth(ctx context.Context, cfg *HealthCheckConfig, failures int) int {
+	log := zerolog.Ctx(ctx)
+	err := db.ping(ctx, cfg.Timeout)
+	if err == nil {
+		if db.setHealthy(true) {
+			log.Info().Int("failed_pings", failures).Msg("Database connection recovered")
+			if cfg.OnRecovered != nil {
+				cfg.OnRecovered()
+			}
+		}
+		return 0
+	} else if ctx.Err() != nil {
+		// The health check is being stopped, so the error is probably caused by that
+		return failures
+	}
+	failures++
+	if failures < cfg.FailureThreshold {
+		log.Warn().Err(err).Int("failed_pings", failures).Msg("Database ping failed")
+	} else if db.setHealthy(false) {
+		log.Error().Err(err).Int("failed_pings", failures).Msg("Database is unhealthy")
+		if cfg.OnUnhealthy != nil {
+			cfg.OnUnhealthy(err)
+		}
+	} else {
+		log.Debug().Err(err).Int("failed_pings", failures).Msg("Database ping failed again")
+	}
+	return failures
+}
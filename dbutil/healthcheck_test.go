@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+func waitForSignal[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case val := <-ch:
+		return val
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for health check")
+		panic("unreachable")
+	}
+}
+
+func TestDatabase_HealthCheck_ClosedDB(t *testing.T) {
+	db := newTestSQLite(t)
+	assert.True(t, db.Healthy())
+	unhealthy := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := db.StartHealthCheck(ctx, dbutil.HealthCheckConfig{
+		Interval:         5 * time.Millisecond,
+		FailureThreshold: 2,
+		OnUnhealthy: func(err error) {
+			unhealthy <- err
+		},
+	})
+	require.NoError(t, db.RawDB.Close())
+	assert.ErrorContains(t, waitForSignal(t, unhealthy), "database is closed")
+	assert.False(t, db.Healthy())
+	cancel()
+	waitForSignal(t, done)
+	// The callback must only be called on the transition
+	assert.Empty(t, unhealthy)
+}
+
+func TestDatabase_HealthCheck_Recover(t *testing.T) {
+	rawDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	db, err := dbutil.NewWithDB(rawDB, "postgres")
+	require.NoError(t, err)
+	child := db.Child("child_version", nil, nil)
+	errPing := errors.New("connection refused")
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errPing)
+	mock.ExpectPing().WillReturnError(errPing)
+	mock.ExpectPing()
+	mock.ExpectPing()
+
+	events := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := db.StartHealthCheck(ctx, dbutil.HealthCheckConfig{
+		Interval:         5 * time.Millisecond,
+		FailureThreshold: 2,
+		OnUnhealthy: func(err error) {
+			assert.ErrorIs(t, err, errPing)
+			assert.False(t, child.Healthy())
+			events <- "unhealthy"
+		},
+		OnRecovered: func() {
+			assert.True(t, db.Healthy())
+			events <- "recovered"
+		},
+	})
+	assert.Equal(t, "unhealthy", waitForSignal(t, events))
+	assert.Equal(t, "recovered", waitForSignal(t, events))
+	cancel()
+	waitForSignal(t, done)
+	assert.Empty(t, events)
+}
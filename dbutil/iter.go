@@ -22,6 +22,13 @@ type RowIter[T any] interface {
 
 	// AsList collects all rows into a slice.
 	AsList() ([]T, error)
+
+	// CollectInto appends all rows to the given slice, which allows reusing the capacity of an existing slice.
+	// If there's an error, the rows collected before the error are still appended.
+	CollectInto(dst *[]T) error
+
+	// Count iterates over all rows without keeping them and returns the number of rows.
+	Count() (int, error)
 }
 
 type ConvertRowFn[T any] func(Scannable) (T, error)
@@ -111,6 +118,29 @@ func (i *rowIterImpl[T]) AsList() (list []T, err error) {
 	return
 }
 
+func (i *rowIterImpl[T]) CollectInto(dst *[]T) error {
+	return collectInto[T](i, dst)
+}
+
+func (i *rowIterImpl[T]) Count() (int, error) {
+	return countRows[T](i)
+}
+
+func collectInto[T any](ri RowIter[T], dst *[]T) error {
+	return ri.Iter(func(item T) (bool, error) {
+		*dst = append(*dst, item)
+		return true, nil
+	})
+}
+
+func countRows[T any](ri RowIter[T]) (count int, err error) {
+	err = ri.Iter(func(item T) (bool, error) {
+		count++
+		return true, nil
+	})
+	return
+}
+
 func RowIterAsMap[T any, Key comparable, Value any](ri RowIter[T], getKeyValue func(T) (Key, Value)) (map[Key]Value, error) {
 	m := make(map[Key]Value)
 	err := ri.Iter(func(item T) (bool, error) {
@@ -164,3 +194,87 @@ func (i *sliceIterImpl[T]) AsList() ([]T, error) {
 	i.err = ErrAlreadyIterated
 	return i.items, nil
 }
+
+func (i *sliceIterImpl[T]) CollectInto(dst *[]T) error {
+	items, err := i.AsList()
+	*dst = append(*dst, items...)
+	return err
+}
+
+func (i *sliceIterImpl[T]) Count() (int, error) {
+	items, err := i.AsList()
+	return len(items), err
+}
+
+type mapRowIterImpl[T, U any] struct {
+	inner RowIter[T]
+	fn    func(T) (U, error)
+}
+
+// MapRowIter creates a new RowIter that converts each row of the given iterator using the given function.
+//
+// If the function returns an error, the iteration is stopped and the error is returned from the outer iterator.
+// The underlying rows are closed by the inner iterator like in normal iteration.
+func MapRowIter[T, U any](ri RowIter[T], fn func(T) (U, error)) RowIter[U] {
+	return &mapRowIterImpl[T, U]{inner: ri, fn: fn}
+}
+
+func (i *mapRowIterImpl[T, U]) Iter(fn func(U) (bool, error)) error {
+	return i.inner.Iter(func(item T) (bool, error) {
+		mapped, err := i.fn(item)
+		if err != nil {
+			return false, err
+		}
+		return fn(mapped)
+	})
+}
+
+func (i *mapRowIterImpl[T, U]) AsList() (list []U, err error) {
+	err = collectInto[U](i, &list)
+	return
+}
+
+func (i *mapRowIterImpl[T, U]) CollectInto(dst *[]U) error {
+	return collectInto[U](i, dst)
+}
+
+func (i *mapRowIterImpl[T, U]) Count() (int, error) {
+	return countRows[U](i)
+}
+
+type filterRowIterImpl[T any] struct {
+	inner RowIter[T]
+	pred  func(T) (bool, error)
+}
+
+// FilterRowIter creates a new RowIter that only includes the rows of the given iterator for which the given
+// predicate returns true.
+//
+// If the predicate returns an error, the iteration is stopped and the error is returned from the outer iterator.
+func FilterRowIter[T any](ri RowIter[T], pred func(T) (bool, error)) RowIter[T] {
+	return &filterRowIterImpl[T]{inner: ri, pred: pred}
+}
+
+func (i *filterRowIterImpl[T]) Iter(fn func(T) (bool, error)) error {
+	return i.inner.Iter(func(item T) (bool, error) {
+		if include, err := i.pred(item); err != nil {
+			return false, err
+		} else if !include {
+			return true, nil
+		}
+		return fn(item)
+	})
+}
+
+func (i *filterRowIterImpl[T]) AsList() (list []T, err error) {
+	err = collectInto[T](i, &list)
+	return
+}
+
+func (i *filterRowIterImpl[T]) CollectInto(dst *[]T) error {
+	return collectInto[T](i, dst)
+}
+
+func (i *filterRowIterImpl[T]) Count() (int, error) {
+	return countRows[T](i)
+}
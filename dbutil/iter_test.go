@@ -0,0 +1,151 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+// fakeRows is a dbutil.Rows implementation that returns the given integers and counts how many times it's closed.
+type fakeRows struct {
+	items  []int
+	pos    int
+	closed int
+	err    error
+}
+
+var _ dbutil.Rows = (*fakeRows)(nil)
+
+func (fr *fakeRows) Close() error                            { fr.closed++; return nil }
+func (fr *fakeRows) ColumnTypes() ([]*sql.ColumnType, error) { return nil, nil }
+func (fr *fakeRows) Columns() ([]string, error)              { return []string{"id"}, nil }
+func (fr *fakeRows) Err() error                              { return fr.err }
+func (fr *fakeRows) NextResultSet() bool                     { return false }
+
+func (fr *fakeRows) Next() bool {
+	fr.pos++
+	return fr.pos <= len(fr.items)
+}
+
+func (fr *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*int) = fr.items[fr.pos-1]
+	return nil
+}
+
+func newFakeRowIter(items ...int) (*fakeRows, dbutil.RowIter[int]) {
+	rows := &fakeRows{items: items}
+	return rows, dbutil.NewRowIter(rows, dbutil.ScanSingleColumn[int])
+}
+
+func isEvenRow(i int) (bool, error) {
+	return i%2 == 0, nil
+}
+
+func TestRowIter_Count(t *testing.T) {
+	rows, ri := newFakeRowIter(1, 2, 3)
+	count, err := ri.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 1, rows.closed)
+	_, err = ri.Count()
+	assert.ErrorIs(t, err, dbutil.ErrAlreadyIterated)
+	assert.Equal(t, 1, rows.closed)
+
+	count, err = dbutil.NewSliceIter([]int{1, 2}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestRowIter_CollectInto(t *testing.T) {
+	dst := make([]int, 0, 8)
+	dst = append(dst, 0)
+	_, ri := newFakeRowIter(1, 2, 3)
+	require.NoError(t, ri.CollectInto(&dst))
+	assert.Equal(t, []int{0, 1, 2, 3}, dst)
+	assert.Equal(t, 8, cap(dst))
+
+	require.NoError(t, dbutil.NewSliceIter([]int{4, 5}).CollectInto(&dst))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, dst)
+}
+
+func TestMapFilterRowIter(t *testing.T) {
+	rows, ri := newFakeRowIter(1, 2, 3, 4, 5, 6)
+	strs, err := dbutil.MapRowIter(dbutil.FilterRowIter(ri, isEvenRow), func(i int) (string, error) {
+		return strconv.Itoa(i * 10), nil
+	}).AsList()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20", "40", "60"}, strs)
+	assert.Equal(t, 1, rows.closed)
+
+	count, err := dbutil.FilterRowIter(dbutil.NewSliceIter([]int{1, 2, 3, 4}), isEvenRow).Count()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestMapFilterRowIter_EarlyStop(t *testing.T) {
+	rows, ri := newFakeRowIter(1, 2, 3, 4, 5, 6)
+	var mapped []int
+	err := dbutil.FilterRowIter(dbutil.MapRowIter(ri, func(i int) (int, error) {
+		mapped = append(mapped, i)
+		return i * 3, nil
+	}), isEvenRow).Iter(func(i int) (bool, error) {
+		return i < 6, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, mapped)
+	assert.Equal(t, 2, rows.pos)
+	assert.Equal(t, 1, rows.closed)
+}
+
+func TestMapFilterRowIter_Errors(t *testing.T) {
+	errMap := errors.New("map error")
+	errFilter := errors.New("filter error")
+	failingMapper := func(i int) (int, error) {
+		if i == 3 {
+			return 0, errMap
+		}
+		return i, nil
+	}
+	failingFilter := func(i int) (bool, error) {
+		if i == 4 {
+			return false, errFilter
+		}
+		return true, nil
+	}
+
+	rows, ri := newFakeRowIter(1, 2, 3, 4, 5)
+	var dst []int
+	err := dbutil.FilterRowIter(dbutil.MapRowIter(ri, failingMapper), failingFilter).CollectInto(&dst)
+	assert.ErrorIs(t, err, errMap)
+	assert.Equal(t, []int{1, 2}, dst)
+	assert.Equal(t, 3, rows.pos)
+	assert.Equal(t, 1, rows.closed)
+
+	rows, ri = newFakeRowIter(1, 2, 4, 5)
+	_, err = dbutil.MapRowIter(dbutil.FilterRowIter(ri, failingFilter), failingMapper).Count()
+	assert.ErrorIs(t, err, errFilter)
+	assert.Equal(t, 3, rows.pos)
+	assert.Equal(t, 1, rows.closed)
+
+	errRows := errors.New("rows error")
+	rows, ri = newFakeRowIter(1, 2)
+	rows.err = errRows
+	_, err = dbutil.MapRowIter(dbutil.FilterRowIter(ri, isEvenRow), failingMapper).AsList()
+	assert.ErrorIs(t, err, errRows)
+	assert.Equal(t, 1, rows.closed)
+
+	_, err = dbutil.MapRowIter(dbutil.NewRowIterWithError[int](nil, nil, errRows), failingMapper).Count()
+	assert.ErrorIs(t, err, errRows)
+}
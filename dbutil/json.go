@@ -1,6 +1,7 @@
 package dbutil
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
@@ -45,3 +46,89 @@ func UntypedNil[T any](val *T) any {
 	}
 	return val
 }
+
+// JSONColumn is a generic wrapper for storing values as JSON in database columns.
+//
+// The value is marshaled into a string when writing, so it can be stored in TEXT columns. When reading, both strings
+// and byte slices are accepted, so BLOB and BYTEA columns work too. NULL and empty strings are read as the zero value.
+// Use JSONColumnPtr if NULL should be preserved.
+//
+// Unlike JSON, this doesn't need the target pointer to be set before scanning:
+//
+//	var meta dbutil.JSONColumn[PortalMetadata]
+//	err := db.QueryRow(ctx, "SELECT metadata FROM portal WHERE id=$1", id).Scan(&meta)
+//	_, err = db.Exec(ctx, "UPDATE portal SET metadata=$1 WHERE id=$2", dbutil.JSONColumn[PortalMetadata]{Data: newMeta}, id)
+type JSONColumn[T any] struct {
+	Data T
+}
+
+var (
+	_ sql.Scanner   = (*JSONColumn[any])(nil)
+	_ driver.Valuer = JSONColumn[any]{}
+)
+
+func scanJSONColumn(i any, target any) (isNull bool, err error) {
+	var data []byte
+	switch value := i.(type) {
+	case nil:
+		return true, nil
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return false, fmt.Errorf("invalid type %T for JSON column", i)
+	}
+	if len(data) == 0 {
+		return true, nil
+	}
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal JSON column into %T: %w", target, err)
+	}
+	return false, nil
+}
+
+func (j *JSONColumn[T]) Scan(i any) error {
+	var zero T
+	j.Data = zero
+	_, err := scanJSONColumn(i, &j.Data)
+	return err
+}
+
+func (j JSONColumn[T]) Value() (driver.Value, error) {
+	v, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(v), nil
+}
+
+// JSONColumnPtr is like JSONColumn, but it reads NULL and empty strings as a nil pointer and writes nil pointers
+// as NULL instead of the JSON string "null".
+type JSONColumnPtr[T any] struct {
+	Data *T
+}
+
+var (
+	_ sql.Scanner   = (*JSONColumnPtr[any])(nil)
+	_ driver.Valuer = JSONColumnPtr[any]{}
+)
+
+func (j *JSONColumnPtr[T]) Scan(i any) error {
+	target := new(T)
+	isNull, err := scanJSONColumn(i, target)
+	if isNull || err != nil {
+		j.Data = nil
+	} else {
+		j.Data = target
+	}
+	return err
+}
+
+func (j JSONColumnPtr[T]) Value() (driver.Value, error) {
+	if j.Data == nil {
+		return nil, nil
+	}
+	return JSONColumn[*T]{Data: j.Data}.Value()
+}
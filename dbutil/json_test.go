@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+type jsonTestInner struct {
+	Tags  []string       `json:"tags"`
+	Extra map[string]int `json:"extra,omitempty"`
+}
+
+type jsonTestMeta struct {
+	Name  string         `json:"name"`
+	Inner *jsonTestInner `json:"inner,omitempty"`
+}
+
+func newJSONTestDB(t *testing.T) *dbutil.Database {
+	db := newTestSQLite(t)
+	_, err := db.Exec(context.Background(), "CREATE TABLE meta (id INTEGER PRIMARY KEY, text_data TEXT, blob_data BLOB)")
+	require.NoError(t, err)
+	return db
+}
+
+func TestJSONColumn(t *testing.T) {
+	db := newJSONTestDB(t)
+	ctx := context.Background()
+	meta := jsonTestMeta{Name: "test", Inner: &jsonTestInner{Tags: []string{"a", "b"}, Extra: map[string]int{"c": 1}}}
+	_, err := db.Exec(ctx, "INSERT INTO meta (id, text_data, blob_data) VALUES (1, $1, CAST($1 AS BLOB)), (2, NULL, ''), (3, 'null', NULL)",
+		dbutil.JSONColumn[jsonTestMeta]{Data: meta})
+	require.NoError(t, err)
+
+	var textData, blobData dbutil.JSONColumn[jsonTestMeta]
+	var textType, blobType string
+	err = db.QueryRow(ctx, "SELECT text_data, typeof(text_data), blob_data, typeof(blob_data) FROM meta WHERE id=1").
+		Scan(&textData, &textType, &blobData, &blobType)
+	require.NoError(t, err)
+	assert.Equal(t, "text", textType)
+	assert.Equal(t, "blob", blobType)
+	assert.Equal(t, meta, textData.Data)
+	assert.Equal(t, meta, blobData.Data)
+
+	// Scanning into a previously used value must not leave old data behind
+	for _, id := range []int{2, 3} {
+		err = db.QueryRow(ctx, "SELECT text_data, blob_data FROM meta WHERE id=$1", id).Scan(&textData, &blobData)
+		require.NoError(t, err)
+		assert.Zero(t, textData.Data)
+		assert.Zero(t, blobData.Data)
+	}
+}
+
+func TestJSONColumnPtr(t *testing.T) {
+	db := newJSONTestDB(t)
+	ctx := context.Background()
+	meta := &jsonTestMeta{Name: "test", Inner: &jsonTestInner{Tags: []string{"a"}}}
+	_, err := db.Exec(ctx, "INSERT INTO meta (id, text_data, blob_data) VALUES (1, $1, $2), (2, '', NULL)",
+		dbutil.JSONColumnPtr[jsonTestMeta]{Data: meta}, dbutil.JSONColumnPtr[jsonTestMeta]{})
+	require.NoError(t, err)
+
+	var isNull bool
+	err = db.QueryRow(ctx, "SELECT blob_data IS NULL FROM meta WHERE id=1").Scan(&isNull)
+	require.NoError(t, err)
+	assert.True(t, isNull, "nil pointer should be stored as NULL")
+
+	var textData, blobData dbutil.JSONColumnPtr[jsonTestMeta]
+	err = db.QueryRow(ctx, "SELECT text_data, blob_data FROM meta WHERE id=1").Scan(&textData, &blobData)
+	require.NoError(t, err)
+	assert.Equal(t, meta, textData.Data)
+	assert.Nil(t, blobData.Data)
+
+	err = db.QueryRow(ctx, "SELECT text_data FROM meta WHERE id=2").Scan(&textData)
+	require.NoError(t, err)
+	assert.Nil(t, textData.Data)
+}
+
+func TestJSONColumn_Invalid(t *testing.T) {
+	db := newJSONTestDB(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, "INSERT INTO meta (id, text_data, blob_data) VALUES (1, '{\"name\":', 5)")
+	require.NoError(t, err)
+
+	var textData dbutil.JSONColumn[jsonTestMeta]
+	err = db.QueryRow(ctx, "SELECT text_data FROM meta").Scan(&textData)
+	assert.ErrorContains(t, err, "failed to unmarshal JSON column into *dbutil_test.jsonTestMeta")
+	assert.ErrorContains(t, err, "unexpected end of JSON input")
+
+	var ptrData dbutil.JSONColumnPtr[jsonTestMeta]
+	err = db.QueryRow(ctx, "SELECT text_data FROM meta").Scan(&ptrData)
+	assert.Error(t, err)
+	assert.Nil(t, ptrData.Data)
+
+	err = db.QueryRow(ctx, "SELECT blob_data FROM meta").Scan(&textData)
+	assert.ErrorContains(t, err, "invalid type int64 for JSON column")
+}
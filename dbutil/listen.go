@@ -0,0 +1,182 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrNotificationsUnsupported = errors.New("database driver connection doesn't support waiting for notifications")
+
+// Notification is a message received from a Postgres channel that is being listened to with Listen.
+type Notification struct {
+	Channel string
+	Payload string
+	// PID is the process ID of the database server backend that sent the notification.
+	PID uint32
+}
+
+// NotificationWaiter is implemented by database driver connections that can wait for notifications.
+//
+// Postgres drivers have their own APIs for notifications, so a Database.NotificationWaiterFunc is usually needed
+// to adapt the driver connection to this interface.
+type NotificationWaiter interface {
+	// WaitForNotification blocks until a notification is received on any channel the connection is listening to,
+	// the context is canceled or the connection fails.
+	WaitForNotification(ctx context.Context) (*Notification, error)
+}
+
+// NotificationWaiterFunc converts a raw driver connection (as passed to the callback of sql.Conn.Raw) into
+// a NotificationWaiter. It may return ErrNotificationsUnsupported if the connection type isn't supported.
+//
+// For example, with pgx:
+//
+//	db.NotificationWaiterFunc = func(driverConn any) (dbutil.NotificationWaiter, error) {
+//		return pgxWaiter{driverConn.(*stdlib.Conn).Conn()}, nil
+//	}
+//
+//	type pgxWaiter struct{ conn *pgx.Conn }
+//
+//	func (pw pgxWaiter) WaitForNotification(ctx context.Context) (*dbutil.Notification, error) {
+//		notif, err := pw.conn.WaitForNotification(ctx)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return &dbutil.Notification{Channel: notif.Channel, Payload: notif.Payload, PID: notif.PID}, nil
+//	}
+type NotificationWaiterFunc func(driverConn any) (NotificationWaiter, error)
+
+const (
+	listenMinReconnectDelay = 1 * time.Second
+	listenMaxReconnectDelay = 1 * time.Minute
+)
+
+func (db *Database) notificationWaiter(driverConn any) (NotificationWaiter, error) {
+	if db.NotificationWaiterFunc != nil {
+		return db.NotificationWaiterFunc(driverConn)
+	} else if waiter, ok := driverConn.(NotificationWaiter); ok {
+		return waiter, nil
+	}
+	return nil, fmt.Errorf("%w (connection type %T)", ErrNotificationsUnsupported, driverConn)
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Listen starts listening to the given Postgres notification channel on a dedicated connection.
+//
+// Notifications are sent to the returned channel, which must be read continuously, as the connection doesn't receive
+// anything else while a notification is waiting to be delivered. The channel is closed after the context is canceled.
+//
+// If the connection fails, it's reopened and the channel is listened to again automatically. Reconnections are
+// logged using the logger in the context (zerolog.Ctx). Notifications sent while reconnecting are lost, so for
+// things like cache invalidation, the whole cache should be considered stale when that happens.
+//
+// The channel name is case-sensitive, i.e. it's quoted in the LISTEN query and must match the name passed to
+// Notify or pg_notify exactly. On other dialects than Postgres, this returns ErrUnsupportedDialect. If the driver
+// connection doesn't implement NotificationWaiter and Database.NotificationWaiterFunc isn't set, this returns
+// ErrNotificationsUnsupported.
+func (db *Database) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	if db.Dialect != Postgres {
+		return nil, fmt.Errorf("%w: LISTEN is only supported on Postgres, not %s", ErrUnsupportedDialect, db.Dialect)
+	}
+	conn, err := db.openListenConn(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Notification)
+	go db.listenLoop(ctx, channel, conn, ch)
+	return ch, nil
+}
+
+// Notify sends a notification with the given payload to the given Postgres channel using pg_notify.
+//
+// If the context contains a transaction, the notification is only delivered when the transaction is committed.
+// On other dialects than Postgres, this returns ErrUnsupportedDialect.
+func (db *Database) Notify(ctx context.Context, channel, payload string) error {
+	if db.Dialect != Postgres {
+		return fmt.Errorf("%w: NOTIFY is only supported on Postgres, not %s", ErrUnsupportedDialect, db.Dialect)
+	}
+	_, err := db.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+func (db *Database) openListenConn(ctx context.Context, channel string) (*sql.Conn, error) {
+	conn, err := db.RawDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.Raw(func(driverConn any) error {
+		_, err := db.notificationWaiter(driverConn)
+		return err
+	})
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "LISTEN "+quoteIdentifier(channel))
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (db *Database) listenLoop(ctx context.Context, channel string, conn *sql.Conn, ch chan<- Notification) {
+	defer close(ch)
+	log := zerolog.Ctx(ctx).With().Str("listen_channel", channel).Logger()
+	for {
+		err := db.waitForNotifications(ctx, conn, ch)
+		conn = nil
+		var delay time.Duration
+		for conn == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Dur("retry_in", delay).Msg("Listen connection failed, reconnecting")
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			delay = min(max(delay*2, listenMinReconnectDelay), listenMaxReconnectDelay)
+			conn, err = db.openListenConn(ctx, channel)
+		}
+		log.Info().Msg("Listen connection re-established")
+	}
+}
+
+func (db *Database) waitForNotifications(ctx context.Context, conn *sql.Conn, ch chan<- Notification) error {
+	err := conn.Raw(func(driverConn any) error {
+		waiter, err := db.notificationWaiter(driverConn)
+		if err != nil {
+			return err
+		}
+		for {
+			notif, err := waiter.WaitForNotification(ctx)
+			if err != nil {
+				// Returning ErrBadConn makes database/sql discard the connection instead of returning it to the pool,
+				// as it's still listening and may be in a broken state after the wait was interrupted.
+				return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+			}
+			select {
+			case ch <- *notif:
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %w", driver.ErrBadConn, ctx.Err())
+			}
+		}
+	})
+	_ = conn.Close()
+	return err
+}
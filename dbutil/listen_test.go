@@ -0,0 +1,235 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+// The module doesn't depend on any Postgres driver, so the tests use a fake driver
+// that only supports LISTEN and pg_notify.
+
+type fakePGHub struct {
+	lock     sync.Mutex
+	conns    map[*fakePGConn]struct{}
+	listens  int
+	noWaiter bool
+}
+
+func (hub *fakePGHub) Connect(_ context.Context) (driver.Conn, error) {
+	conn := &fakePGConn{
+		hub:      hub,
+		channels: make(map[string]struct{}),
+		queue:    make(chan *dbutil.Notification, 16),
+		dead:     make(chan struct{}),
+	}
+	hub.lock.Lock()
+	hub.conns[conn] = struct{}{}
+	hub.lock.Unlock()
+	if hub.noWaiter {
+		return fakePGConnNoWaiter{conn}, nil
+	}
+	return conn, nil
+}
+
+func (hub *fakePGHub) Driver() driver.Driver {
+	panic("not implemented")
+}
+
+func (hub *fakePGHub) listenerCount() int {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	count := 0
+	for conn := range hub.conns {
+		if len(conn.channels) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// breakListeners simulates the connections of all listeners failing.
+func (hub *fakePGHub) breakListeners() {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	for conn := range hub.conns {
+		if len(conn.channels) > 0 {
+			close(conn.dead)
+			delete(hub.conns, conn)
+		}
+	}
+}
+
+type fakePGConn struct {
+	hub      *fakePGHub
+	channels map[string]struct{}
+	queue    chan *dbutil.Notification
+	dead     chan struct{}
+}
+
+var _ driver.ExecerContext = (*fakePGConn)(nil)
+
+func (conn *fakePGConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	hub := conn.hub
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if channel, ok := strings.CutPrefix(query, "LISTEN "); ok {
+		conn.channels[strings.Trim(channel, `"`)] = struct{}{}
+		hub.listens++
+		return driver.RowsAffected(0), nil
+	} else if query == "SELECT pg_notify($1, $2)" {
+		notif := &dbutil.Notification{Channel: args[0].Value.(string), Payload: args[1].Value.(string), PID: 1234}
+		for target := range hub.conns {
+			if _, ok := target.channels[notif.Channel]; ok {
+				target.queue <- notif
+			}
+		}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("unsupported query %q", query)
+}
+
+func (conn *fakePGConn) WaitForNotification(ctx context.Context) (*dbutil.Notification, error) {
+	select {
+	case notif := <-conn.queue:
+		return notif, nil
+	case <-conn.dead:
+		return nil, errors.New("connection reset by peer")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (conn *fakePGConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (conn *fakePGConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (conn *fakePGConn) Close() error {
+	conn.hub.lock.Lock()
+	delete(conn.hub.conns, conn)
+	conn.hub.lock.Unlock()
+	return nil
+}
+
+// fakePGConnNoWaiter hides the WaitForNotification method of the fake connection,
+// like real drivers that need a NotificationWaiterFunc.
+type fakePGConnNoWaiter struct {
+	driver.Conn
+}
+
+func (conn fakePGConnNoWaiter) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return conn.Conn.(*fakePGConn).ExecContext(ctx, query, args)
+}
+
+func newFakePostgres(t *testing.T) (*dbutil.Database, *fakePGHub) {
+	hub := &fakePGHub{conns: make(map[*fakePGConn]struct{})}
+	rawDB := sql.OpenDB(hub)
+	t.Cleanup(func() {
+		_ = rawDB.Close()
+	})
+	db, err := dbutil.NewWithDB(rawDB, "postgres")
+	require.NoError(t, err)
+	return db, hub
+}
+
+func receiveNotification(t *testing.T, ch <-chan dbutil.Notification) dbutil.Notification {
+	t.Helper()
+	select {
+	case notif, ok := <-ch:
+		require.True(t, ok, "notification channel closed unexpectedly")
+		return notif
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return dbutil.Notification{}
+	}
+}
+
+func TestDatabase_Listen(t *testing.T) {
+	db, hub := newFakePostgres(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := db.Listen(ctx, "cache_invalidation")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Notify(ctx, "cache_invalidation", "foo:1"))
+	require.NoError(t, db.Notify(ctx, "other_channel", "foo:2"))
+	require.NoError(t, db.Notify(ctx, "cache_invalidation", "foo:3"))
+	assert.Equal(t, dbutil.Notification{Channel: "cache_invalidation", Payload: "foo:1", PID: 1234}, receiveNotification(t, ch))
+	assert.Equal(t, "foo:3", receiveNotification(t, ch).Payload)
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification channel wasn't closed")
+	}
+	// The listening connection must not be returned to the pool
+	assert.Equal(t, 0, hub.listenerCount())
+}
+
+func TestDatabase_Listen_Reconnect(t *testing.T) {
+	db, hub := newFakePostgres(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := db.Listen(ctx, "foo")
+	require.NoError(t, err)
+	require.NoError(t, db.Notify(ctx, "foo", "before"))
+	assert.Equal(t, "before", receiveNotification(t, ch).Payload)
+
+	hub.breakListeners()
+	require.Eventually(t, func() bool {
+		return hub.listenerCount() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, db.Notify(ctx, "foo", "after"))
+	assert.Equal(t, "after", receiveNotification(t, ch).Payload)
+	hub.lock.Lock()
+	assert.Equal(t, 2, hub.listens)
+	hub.lock.Unlock()
+}
+
+func TestDatabase_Listen_NotificationWaiterFunc(t *testing.T) {
+	db, hub := newFakePostgres(t)
+	hub.noWaiter = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := db.Listen(ctx, "foo")
+	assert.ErrorIs(t, err, dbutil.ErrNotificationsUnsupported)
+
+	db.NotificationWaiterFunc = func(driverConn any) (dbutil.NotificationWaiter, error) {
+		return driverConn.(fakePGConnNoWaiter).Conn.(*fakePGConn), nil
+	}
+	ch, err := db.Listen(ctx, "foo")
+	require.NoError(t, err)
+	require.NoError(t, db.Notify(ctx, "foo", "bar"))
+	assert.Equal(t, "bar", receiveNotification(t, ch).Payload)
+}
+
+func TestDatabase_Listen_SQLite(t *testing.T) {
+	db := newTestSQLite(t)
+	_, err := db.Listen(context.Background(), "foo")
+	assert.ErrorIs(t, err, dbutil.ErrUnsupportedDialect)
+	err = db.Notify(context.Background(), "foo", "bar")
+	assert.ErrorIs(t, err, dbutil.ErrUnsupportedDialect)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDialect_MySQL(t *testing.T) {
+	for _, engine := range []string{"mysql", "MySQL", "mariadb"} {
+		dialect, err := ParseDialect(engine)
+		assert.NoError(t, err)
+		assert.Equal(t, MySQL, dialect)
+	}
+	assert.Equal(t, "mysql", MySQL.String())
+}
+
+func TestMungeQuery_MySQL(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		args          []any
+		expectedQuery string
+		expectedArgs  []any
+	}{
+		{"No params", "SELECT 1", nil, "SELECT 1", nil},
+		{"Question marks", "SELECT * FROM foo WHERE a=? AND b=?", []any{1, 2}, "SELECT * FROM foo WHERE a=? AND b=?", []any{1, 2}},
+		{"In order", "INSERT INTO foo (a, b) VALUES ($1, $2)", []any{1, 2}, "INSERT INTO foo (a, b) VALUES (?, ?)", []any{1, 2}},
+		{"Reordered", "UPDATE foo SET b=$2 WHERE a=$1", []any{1, 2}, "UPDATE foo SET b=? WHERE a=?", []any{2, 1}},
+		{"Repeated", "SELECT * FROM foo WHERE a=$1 OR b=$1 OR c=$2", []any{"x", "y"}, "SELECT * FROM foo WHERE a=? OR b=? OR c=?", []any{"x", "x", "y"}},
+		{"Multiple digits", "SELECT $10, $1", []any{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, "SELECT ?, ?", []any{10, 1}},
+		{"Out of range", "SELECT $1, $3", []any{1, 2}, "SELECT ?, ?", []any{1}},
+		{"Unused arg", "SELECT $2", []any{1, 2}, "SELECT ?", []any{2}},
+		{"Dollar without number", "SELECT '$' || $1", []any{1}, "SELECT '$' || ?", []any{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mq := mungeQuery(test.query, MySQL)
+			query, args := mq.query, mq.remapArgs(test.args)
+			assert.Equal(t, test.expectedQuery, query)
+			assert.Equal(t, test.expectedArgs, args)
+		})
+	}
+}
+
+func TestDatabase_MySQLParams(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := NewWithDB(conn, "mysql")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectExec("UPDATE foo SET name=? WHERE id=? OR parent=?").
+		WithArgs("meow", 5, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	_, err = db.Exec(ctx, "UPDATE foo SET name=$2 WHERE id=$1 OR parent=$1", 5, "meow")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema=DATABASE() AND table_name=?)").
+		WithArgs("foo").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	exists, err := db.TableExists(ctx, "foo")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewUpsert("foo").Columns("id").Build(MySQL)
+	assert.ErrorIs(t, err, ErrUnsupportedDialect)
+	_, err = db.Listen(ctx, "foo")
+	assert.ErrorIs(t, err, ErrUnsupportedDialect)
+}
+
+func TestSplitSQLUpgrade_MySQL(t *testing.T) {
+	fs := fstest.MapFS{
+		"01-foo.postgres.sql": {Data: []byte("-- v0 -> v1: Foo\nCREATE TABLE foo (id BIGSERIAL PRIMARY KEY);")},
+		"01-foo.sqlite.sql":   {Data: []byte("-- v0 -> v1: Foo\nCREATE TABLE foo (id INTEGER PRIMARY KEY);")},
+		"01-foo.mysql.sql":    {Data: []byte("-- v0 -> v1: Foo\nCREATE TABLE foo (id BIGINT PRIMARY KEY AUTO_INCREMENT);")},
+		"02-bar.postgres.sql": {Data: []byte("-- v1 -> v2: Bar\nCREATE TABLE bar (id BIGSERIAL PRIMARY KEY);")},
+		"02-bar.sqlite.sql":   {Data: []byte("-- v1 -> v2: Bar\nCREATE TABLE bar (id INTEGER PRIMARY KEY);")},
+	}
+	var table UpgradeTable
+	table.RegisterFS(fs)
+	require.Len(t, table, 2)
+
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := NewWithDB(conn, "mysql")
+	require.NoError(t, err)
+	mock.ExpectExec("-- v0 -> v1: Foo\nCREATE TABLE foo (id BIGINT PRIMARY KEY AUTO_INCREMENT);").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ctx := context.Background()
+	require.NoError(t, table[0].fn(ctx, db))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.ErrorIs(t, table[1].fn(ctx, db), ErrUnsupportedDialect)
+}
+
+// TestMySQLIntegration runs basic operations against a real MySQL or MariaDB database. It requires the DSN of
+// an empty test database in the DBUTIL_TEST_MYSQL_DSN environment variable (e.g. `user:pass@/dbutil_test`),
+// and the go-sql-driver/mysql driver to be registered in the test binary.
+func TestMySQLIntegration(t *testing.T) {
+	dsn := os.Getenv("DBUTIL_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DBUTIL_TEST_MYSQL_DSN not set")
+	} else if !slices.Contains(sql.Drivers(), "mysql") {
+		t.Skip("mysql driver not registered")
+	}
+	db, err := NewWithDialect(dsn, "mysql")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	db.Owner = "dbutil-test"
+	db.UpgradeTable.Register(-1, 1, 0, "Create table", true, func(ctx context.Context, db *Database) error {
+		_, err := db.Exec(ctx, "CREATE TABLE dbutil_test (id BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL)")
+		return err
+	})
+	require.NoError(t, db.Upgrade(ctx))
+	defer func() {
+		_, _ = db.Exec(ctx, "DROP TABLE dbutil_test, database_owner, version")
+	}()
+	_, err = db.Exec(ctx, "INSERT INTO dbutil_test (name, id) VALUES ($2, $1)", 1, "foo")
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, "INSERT INTO dbutil_test (id, name) VALUES ($1, $2)", 1, "bar")
+	assert.True(t, IsUniqueViolation(err), err)
+	var name string
+	require.NoError(t, db.QueryRow(ctx, "SELECT name FROM dbutil_test WHERE id=$1 OR id=$1+1", 1).Scan(&name))
+	assert.Equal(t, "foo", name)
+	exists, err := db.ColumnExists(ctx, "dbutil_test", "name")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
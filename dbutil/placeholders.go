@@ -0,0 +1,197 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxMungeCacheSize is the maximum number of queries in the placeholder cache. The cache is cleared when it fills up,
+// which only happens if queries are built dynamically with lots of variations.
+const maxMungeCacheSize = 4096
+
+// mungedQuery is a query with Postgres-style placeholders converted to the style of another dialect.
+type mungedQuery struct {
+	query string
+	// params contains the index of the argument for each placeholder in the query if the arguments need to be
+	// reordered (i.e. on MySQL, which doesn't have numbered placeholders). It's nil if the arguments can be used as-is.
+	params []int
+}
+
+// remapArgs returns the arguments in the order of the placeholders in the query.
+// Out of range placeholders are skipped, which makes the driver return an error about the argument count.
+func (mq *mungedQuery) remapArgs(args []any) []any {
+	if mq.params == nil {
+		return args
+	}
+	newArgs := make([]any, 0, len(mq.params))
+	for _, idx := range mq.params {
+		if idx >= 0 && idx < len(args) {
+			newArgs = append(newArgs, args[idx])
+		}
+	}
+	return newArgs
+}
+
+type mungeCache struct {
+	lock  sync.RWMutex
+	items map[string]*mungedQuery
+}
+
+func (mc *mungeCache) get(query string, dialect Dialect) *mungedQuery {
+	if mc == nil {
+		return mungeQuery(query, dialect)
+	}
+	mc.lock.RLock()
+	mq, ok := mc.items[query]
+	mc.lock.RUnlock()
+	if ok {
+		return mq
+	}
+	mq = mungeQuery(query, dialect)
+	mc.lock.Lock()
+	if mc.items == nil || len(mc.items) >= maxMungeCacheSize {
+		mc.items = make(map[string]*mungedQuery)
+	}
+	mc.items[query] = mq
+	mc.lock.Unlock()
+	return mq
+}
+
+func (db *Database) mutateQuery(query string, args []any) (string, []any) {
+	switch db.Dialect {
+	case SQLite, MySQL:
+		mq := db.mungeCache.get(query, db.Dialect)
+		return mq.query, mq.remapArgs(args)
+	default:
+		return query, args
+	}
+}
+
+// MungeSQL converts the Postgres-style placeholders (`$1`) in the given query to the style of the database dialect.
+// This is done automatically for all queries executed through the Database, so this is only needed when passing
+// queries to other libraries or the underlying sql.DB directly.
+//
+// On SQLite, `$1` is converted to `?1`. On MySQL, each placeholder is converted to `?`, which means the arguments
+// must be in the same order as the placeholders, and repeated placeholders need the argument to be repeated.
+// On Postgres, the query is returned as-is.
+//
+// Placeholders inside string literals, quoted identifiers, dollar-quoted strings and comments are not converted.
+// The results are cached, so converting the same query again is cheap.
+func (db *Database) MungeSQL(query string) string {
+	query, _ = db.mutateQuery(query, nil)
+	return query
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skipQuoted returns the index after the quoted string or identifier starting at the given index.
+// The quote character can be escaped by doubling it, or with a backslash if backslashEscapes is true.
+func skipQuoted(query string, start int, backslashEscapes bool) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+			} else {
+				return i + 1
+			}
+		}
+	}
+	return len(query)
+}
+
+// skipUntil returns the index after the first occurrence of the given string after the given index,
+// or the length of the query if it's not found.
+func skipUntil(query string, start int, end string) int {
+	idx := strings.Index(query[start:], end)
+	if idx < 0 {
+		return len(query)
+	}
+	return start + idx + len(end)
+}
+
+// dollarQuoteTag returns the tag (e.g. `$$` or `$foo$`) if there's a dollar-quoted string starting at the given index.
+func dollarQuoteTag(query string, start int) (string, bool) {
+	for i := start + 1; i < len(query); i++ {
+		c := query[i]
+		if c == '$' {
+			return query[start : i+1], true
+		} else if !isIdentifierChar(c) || (i == start+1 && isDigit(c)) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// mungeQuery converts the Postgres-style placeholders in the query to the style of the given dialect.
+func mungeQuery(query string, dialect Dialect) *mungedQuery {
+	if (dialect != SQLite && dialect != MySQL) || !strings.Contains(query, "$") {
+		return &mungedQuery{query: query}
+	}
+	var out strings.Builder
+	out.Grow(len(query))
+	var params []int
+	// written is the index up to which the query has been written to the output
+	written := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// MySQL allows backslash escapes in both strings and double-quoted strings by default
+			i = skipQuoted(query, i, dialect == MySQL)
+		case c == '`':
+			i = skipQuoted(query, i, false)
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#' && dialect == MySQL:
+			i = skipUntil(query, i, "\n")
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			i = skipUntil(query, i+2, "*/")
+		case c == '$' && (i == 0 || !isIdentifierChar(query[i-1])):
+			end := i + 1
+			for end < len(query) && isDigit(query[end]) {
+				end++
+			}
+			if end > i+1 {
+				out.WriteString(query[written:i])
+				out.WriteByte('?')
+				if dialect == MySQL {
+					num, _ := strconv.Atoi(query[i+1 : end])
+					params = append(params, num-1)
+				} else {
+					out.WriteString(query[i+1 : end])
+				}
+				written = end
+				i = end
+			} else if tag, ok := dollarQuoteTag(query, i); ok {
+				i = skipUntil(query, i+len(tag), tag)
+			} else {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	if written == 0 {
+		return &mungedQuery{query: query}
+	}
+	out.WriteString(query[written:])
+	return &mungedQuery{query: out.String(), params: params}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMungeQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		sqlite string
+		mysql  string
+		params []int
+	}{
+		{"No placeholders", "SELECT 1", "SELECT 1", "SELECT 1", nil},
+		{"Question marks", "SELECT * FROM foo WHERE a=?1", "SELECT * FROM foo WHERE a=?1", "SELECT * FROM foo WHERE a=?1", nil},
+		{"Simple", "SELECT * FROM foo WHERE a=$1 AND b=$2", "SELECT * FROM foo WHERE a=?1 AND b=?2", "SELECT * FROM foo WHERE a=? AND b=?", []int{0, 1}},
+		{"Repeated", "SELECT $2, $1, $2", "SELECT ?2, ?1, ?2", "SELECT ?, ?, ?", []int{1, 0, 1}},
+		{"Multiple digits", "VALUES ($9, $10, $11)", "VALUES (?9, ?10, ?11)", "VALUES (?, ?, ?)", []int{8, 9, 10}},
+		{"Cast", "SELECT $1::text, $2::jsonb->>'a'", "SELECT ?1::text, ?2::jsonb->>'a'", "SELECT ?::text, ?::jsonb->>'a'", []int{0, 1}},
+		{"String literal", "SELECT '$1', $1", "SELECT '$1', ?1", "SELECT '$1', ?", []int{0}},
+		{"Escaped quote", "SELECT 'it''s $1', $2", "SELECT 'it''s $1', ?2", "SELECT 'it''s $1', ?", []int{1}},
+		{"Quoted identifier", `SELECT "$1" FROM foo WHERE "a""$2"=$3`, `SELECT "$1" FROM foo WHERE "a""$2"=?3`, `SELECT "$1" FROM foo WHERE "a""$2"=?`, []int{2}},
+		{"Backtick identifier", "SELECT `$1` FROM foo WHERE a=$1", "SELECT `$1` FROM foo WHERE a=?1", "SELECT `$1` FROM foo WHERE a=?", []int{0}},
+		{"Line comment", "SELECT $1 -- uses $2\nFROM foo", "SELECT ?1 -- uses $2\nFROM foo", "SELECT ? -- uses $2\nFROM foo", []int{0}},
+		{"Block comment", "SELECT /* $1 */ $2 /* $3", "SELECT /* $1 */ ?2 /* $3", "SELECT /* $1 */ ? /* $3", []int{1}},
+		{"Dollar-quoted", "CREATE FUNCTION f() AS $$ SELECT $1 $$; SELECT $1", "CREATE FUNCTION f() AS $$ SELECT $1 $$; SELECT ?1", "CREATE FUNCTION f() AS $$ SELECT $1 $$; SELECT ?", []int{0}},
+		{"Tagged dollar-quoted", "SELECT $tag$ $$ $1 $tag$, $2", "SELECT $tag$ $$ $1 $tag$, ?2", "SELECT $tag$ $$ $1 $tag$, ?", []int{1}},
+		{"Unterminated dollar-quoted", "SELECT $$ $1", "SELECT $$ $1", "SELECT $$ $1", nil},
+		{"Identifier with dollar", "SELECT foo$1 FROM a$b WHERE c=$1", "SELECT foo$1 FROM a$b WHERE c=?1", "SELECT foo$1 FROM a$b WHERE c=?", []int{0}},
+		{"Lone dollar", "SELECT $ || $1 || $", "SELECT $ || ?1 || $", "SELECT $ || ? || $", []int{0}},
+		{"Unterminated string", "SELECT $1, 'foo $2", "SELECT ?1, 'foo $2", "SELECT ?, 'foo $2", []int{0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.sqlite, mungeQuery(test.query, SQLite).query)
+			mq := mungeQuery(test.query, MySQL)
+			assert.Equal(t, test.mysql, mq.query)
+			assert.Equal(t, test.params, mq.params)
+			assert.Equal(t, test.query, mungeQuery(test.query, Postgres).query)
+		})
+	}
+}
+
+func TestMungeQuery_DialectSpecificSyntax(t *testing.T) {
+	// MySQL allows backslash escapes in strings and # comments
+	assert.Equal(t, `SELECT 'it\'s $1', ? # $2`, mungeQuery(`SELECT 'it\'s $1', $2 # $2`, MySQL).query)
+	assert.Equal(t, `SELECT "\"$1", ?`, mungeQuery(`SELECT "\"$1", $2`, MySQL).query)
+	// SQLite doesn't, so the backslash doesn't escape anything
+	assert.Equal(t, `SELECT 'foo\', ?1`, mungeQuery(`SELECT 'foo\', $1`, SQLite).query)
+	assert.Equal(t, `SELECT ?1 #?2`, mungeQuery(`SELECT $1 #$2`, SQLite).query)
+}
+
+func TestDatabase_MungeSQL(t *testing.T) {
+	db := newStmtCacheTestDB(t, 0)
+	query := "SELECT name FROM foo WHERE id=$1 AND name<>'$2'"
+	assert.Equal(t, "SELECT name FROM foo WHERE id=?1 AND name<>'$2'", db.MungeSQL(query))
+	assert.Same(t, db.mungeCache.get(query, db.Dialect), db.mungeCache.get(query, db.Dialect))
+
+	var name string
+	require.NoError(t, db.QueryRow(context.Background(), query, 2).Scan(&name))
+	assert.Equal(t, "b", name)
+
+	pg := &Database{Dialect: Postgres}
+	assert.Equal(t, query, pg.MungeSQL(query))
+}
+
+func TestMungeCache_Limit(t *testing.T) {
+	var mc mungeCache
+	for i := 0; i < maxMungeCacheSize+10; i++ {
+		mc.get(fmt.Sprintf("SELECT %d, $1", i), SQLite)
+	}
+	assert.Equal(t, 10, len(mc.items))
+}
+
+func BenchmarkMungeQuery(b *testing.B) {
+	query := "INSERT INTO foo (id, name, data) VALUES ($1, $2, $3::jsonb) ON CONFLICT (id) DO UPDATE SET name='$name', data=excluded.data"
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mungeQuery(query, SQLite)
+		}
+	})
+	b.Run("Cached", func(b *testing.B) {
+		var mc mungeCache
+		for i := 0; i < b.N; i++ {
+			mc.get(query, SQLite)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// PoolStats contains statistics of the connection pool of a database along with some derived numbers.
+type PoolStats struct {
+	sql.DBStats
+
+	// Utilization is the fraction of the maximum number of open connections that are currently in use.
+	// It's always zero if the number of open connections isn't limited.
+	Utilization float64
+	// AverageWait is the average time spent waiting for a connection, out of the requests that had to wait.
+	AverageWait time.Duration
+}
+
+// PoolStatsHook can be implemented to receive connection pool statistics, e.g. to export them as metrics
+// without this package having to depend on a metrics library.
+type PoolStatsHook interface {
+	// OnAcquireWait is called by the stats logger if requests had to wait for a free connection since the previous
+	// check. The duration is the total time spent waiting during the interval.
+	OnAcquireWait(d time.Duration)
+}
+
+func newPoolStats(stats sql.DBStats) PoolStats {
+	ps := PoolStats{DBStats: stats}
+	if stats.MaxOpenConnections > 0 {
+		ps.Utilization = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+	}
+	if stats.WaitCount > 0 {
+		ps.AverageWait = stats.WaitDuration / time.Duration(stats.WaitCount)
+	}
+	return ps
+}
+
+// WaitFractionSince returns the time spent waiting for connections since the previous stats relative to the given
+// elapsed time. Values above 1 mean that multiple requests were waiting at the same time.
+func (ps PoolStats) WaitFractionSince(prev PoolStats, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(ps.WaitDuration-prev.WaitDuration) / float64(elapsed)
+}
+
+// PoolStats returns statistics of the connection pool of the main (read-write) database.
+func (db *Database) PoolStats() PoolStats {
+	return newPoolStats(db.RawDB.Stats())
+}
+
+// StartStatsLogger starts a goroutine that logs connection pool statistics at the given interval and passes them
+// to the PoolStatsHook if one is set. The stats are logged using the logger in the context (zerolog.Ctx).
+//
+// The interval must be positive. The goroutine stops when the context is canceled. The returned channel is closed
+// after it has stopped.
+func (db *Database) StartStatsLogger(ctx context.Context, interval time.Duration) <-chan struct{} {
+	if interval <= 0 {
+		panic("dbutil: StartStatsLogger called with non-positive interval")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := db.PoolStats()
+		prevTime := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				stats := db.PoolStats()
+				db.logPoolStats(ctx, stats, prev, now.Sub(prevTime))
+				prev, prevTime = stats, now
+			}
+		}
+	}()
+	return done
+}
+
+func (db *Database) logPoolStats(ctx context.Context, stats, prev PoolStats, elapsed time.Duration) {
+	if waited := stats.WaitDuration - prev.WaitDuration; waited > 0 && db.PoolStatsHook != nil {
+		db.PoolStatsHook.OnAcquireWait(waited)
+	}
+	zerolog.Ctx(ctx).Debug().
+		Int("max_open", stats.MaxOpenConnections).
+		Int("open", stats.OpenConnections).
+		Int("in_use", stats.InUse).
+		Int("idle", stats.Idle).
+		Int64("wait_count", stats.WaitCount-prev.WaitCount).
+		Dur("wait_duration", stats.WaitDuration-prev.WaitDuration).
+		Float64("wait_fraction", stats.WaitFractionSince(prev, elapsed)).
+		Float64("utilization", stats.Utilization).
+		Msg("Database connection pool stats")
+}
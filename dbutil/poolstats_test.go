@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type waitRecorder struct {
+	total atomic.Int64
+}
+
+func (wr *waitRecorder) OnAcquireWait(d time.Duration) {
+	wr.total.Add(int64(d))
+}
+
+type syncBuffer struct {
+	lock  sync.Mutex
+	lines int
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.lock.Lock()
+	sb.lines++
+	sb.lock.Unlock()
+	return len(p), nil
+}
+
+func (sb *syncBuffer) Lines() int {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.lines
+}
+
+func TestDatabase_PoolStats(t *testing.T) {
+	db := newTestSQLite(t)
+	db.RawDB.SetMaxOpenConns(2)
+	ctx := context.Background()
+	conn, err := db.RawDB.Conn(ctx)
+	require.NoError(t, err)
+	stats := db.PoolStats()
+	assert.Equal(t, 2, stats.MaxOpenConnections)
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, 0.5, stats.Utilization)
+	assert.Zero(t, stats.AverageWait)
+	require.NoError(t, conn.Close())
+	assert.Zero(t, db.PoolStats().Utilization)
+}
+
+func TestDatabase_StartStatsLogger(t *testing.T) {
+	db := newTestSQLite(t)
+	db.RawDB.SetMaxOpenConns(1)
+	recorder := &waitRecorder{}
+	db.PoolStatsHook = recorder
+	var logOutput syncBuffer
+	ctx, cancel := context.WithCancel(zerolog.New(&logOutput).Level(zerolog.DebugLevel).WithContext(context.Background()))
+	defer cancel()
+	done := db.StartStatsLogger(ctx, 5*time.Millisecond)
+
+	conn, err := db.RawDB.Conn(ctx)
+	require.NoError(t, err)
+	waitDone := make(chan struct{})
+	go func() {
+		defer close(waitDone)
+		_, err := db.Exec(context.Background(), "INSERT INTO foo (id) VALUES (1)")
+		assert.NoError(t, err)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, conn.Close())
+	<-waitDone
+
+	assert.Eventually(t, func() bool {
+		return recorder.total.Load() > 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Greater(t, recorder.total.Load(), int64(15*time.Millisecond))
+	assert.Positive(t, db.PoolStats().AverageWait)
+	assert.Positive(t, logOutput.Lines())
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stats logger didn't stop after context was canceled")
+	}
+	lines := logOutput.Lines()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, lines, logOutput.Lines(), "stats logger shouldn't log after stopping")
+}
+
+func TestDatabase_StartStatsLogger_InvalidInterval(t *testing.T) {
+	db := newTestSQLite(t)
+	assert.PanicsWithValue(t, "dbutil: StartStatsLogger called with non-positive interval", func() {
+		db.StartStatsLogger(context.Background(), 0)
+	})
+}
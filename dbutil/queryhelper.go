@@ -24,11 +24,19 @@ type DataStruct[T any] interface {
 //
 // After implementing the Scan and Init methods in a data struct, the query
 // helper allows writing query functions in a single line.
+//
+// Like the query methods of Database, all methods use the transaction in the
+// context if there is one (i.e. when called inside Database.DoTxn), and the
+// connection pool otherwise, so the same query functions work in both cases.
+// The routing is done by Database.Conn, which can also be used directly to get
+// the Execable for a context.
 type QueryHelper[T DataStruct[T]] struct {
 	db      *Database
 	newFunc func(qh *QueryHelper[T]) T
 }
 
+// MakeQueryHelper creates a query helper for the given database. The new function is the row factory,
+// which must return a new empty data struct for scanning rows into.
 func MakeQueryHelper[T DataStruct[T]](db *Database, new func(qh *QueryHelper[T]) T) *QueryHelper[T] {
 	return &QueryHelper[T]{db: db, newFunc: new}
 }
@@ -93,6 +101,7 @@ func (qh *QueryHelper[T]) GetDB() *Database {
 	return qh.db
 }
 
+// New creates a new empty data struct using the row factory passed to MakeQueryHelper.
 func (qh *QueryHelper[T]) New() T {
 	return qh.newFunc(qh)
 }
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+type fooRow struct {
+	qh *dbutil.QueryHelper[*fooRow]
+	ID int
+}
+
+func (f *fooRow) Scan(row dbutil.Scannable) (*fooRow, error) {
+	return dbutil.ValueOrErr(f, row.Scan(&f.ID))
+}
+
+func newFooQueryHelper(db *dbutil.Database) *dbutil.QueryHelper[*fooRow] {
+	return dbutil.MakeQueryHelper(db, func(qh *dbutil.QueryHelper[*fooRow]) *fooRow {
+		return &fooRow{qh: qh}
+	})
+}
+
+func TestQueryHelper_TransactionRouting(t *testing.T) {
+	db := newTestSQLite(t)
+	qh := newFooQueryHelper(db)
+	ctx := context.Background()
+	require.NoError(t, qh.Exec(ctx, "INSERT INTO foo (id) VALUES (1)"))
+
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		require.NoError(t, qh.Exec(ctx, "INSERT INTO foo (id) VALUES (2)"))
+		// Queries with the transaction context see uncommitted changes
+		rows, err := qh.QueryMany(ctx, "SELECT id FROM foo ORDER BY id")
+		require.NoError(t, err)
+		assert.Len(t, rows, 2)
+		row, err := qh.QueryOne(ctx, "SELECT id FROM foo WHERE id=2")
+		require.NoError(t, err)
+		require.NotNil(t, row)
+		assert.Equal(t, 2, row.ID)
+		assert.Same(t, qh, row.qh)
+		return errInner
+	})
+	assert.ErrorIs(t, err, errInner)
+
+	// The insert inside the transaction was rolled back, while the one outside it was committed directly
+	rows, err := qh.QueryMany(ctx, "SELECT id FROM foo ORDER BY id")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 1, rows[0].ID)
+	row, err := qh.QueryOne(ctx, "SELECT id FROM foo WHERE id=2")
+	require.NoError(t, err)
+	assert.Nil(t, row)
+}
+
+func TestDatabase_Conn(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	assert.Same(t, &db.LoggingDB, db.Conn(ctx))
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		txn, ok := db.Conn(ctx).(*dbutil.LoggingTxn)
+		require.True(t, ok)
+		_, err := txn.ExecContext(ctx, "INSERT INTO foo (id) VALUES (1)")
+		require.NoError(t, err)
+		return errInner
+	})
+	assert.ErrorIs(t, err, errInner)
+	qh := newFooQueryHelper(db)
+	row, err := qh.QueryOne(ctx, "SELECT id FROM foo WHERE id=1")
+	require.NoError(t, err)
+	assert.Nil(t, row)
+	assert.Equal(t, &fooRow{qh: qh}, qh.New())
+}
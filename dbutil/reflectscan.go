@@ -7,7 +7,11 @@
 package dbutil
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 func reflectScan[T any](row Scannable) (*T, error) {
@@ -28,3 +32,235 @@ func reflectScan[T any](row Scannable) (*T, error) {
 func NewSimpleReflectRowIter[T any](rows Rows, err error) RowIter[*T] {
 	return ConvertRowFn[*T](reflectScan[T]).NewRowIter(rows, err)
 }
+
+var (
+	ErrUnknownColumn = errors.New("column doesn't match any struct field")
+	ErrMissingColumn = errors.New("no column for required struct field")
+)
+
+type structField struct {
+	column   string
+	index    []int
+	optional bool
+}
+
+type structMapping struct {
+	fields   []structField
+	byColumn map[string]int
+}
+
+var structMappingCache sync.Map
+
+// getStructMapping returns the column mapping of the given struct type, which is parsed from the db struct tags.
+func getStructMapping(typ reflect.Type) (*structMapping, error) {
+	if cached, ok := structMappingCache.Load(typ); ok {
+		return cached.(*structMapping), nil
+	} else if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dbutil: can't scan into %s: not a struct", typ)
+	}
+	mapping := &structMapping{byColumn: make(map[string]int)}
+	// VisibleFields also includes the fields of embedded structs, so they're mapped like normal fields
+	for _, field := range reflect.VisibleFields(typ) {
+		tag, hasTag := field.Tag.Lookup("db")
+		if !hasTag || tag == "-" || !field.IsExported() {
+			continue
+		}
+		if err := checkEmbeddedPointers(typ, field.Index); err != nil {
+			return nil, err
+		}
+		column, flags, _ := strings.Cut(tag, ",")
+		if _, exists := mapping.byColumn[column]; exists {
+			return nil, fmt.Errorf("dbutil: duplicate column %q in %s", column, typ)
+		}
+		mapping.byColumn[column] = len(mapping.fields)
+		mapping.fields = append(mapping.fields, structField{
+			column:   column,
+			index:    field.Index,
+			optional: flags == "optional",
+		})
+	}
+	cached, _ := structMappingCache.LoadOrStore(typ, mapping)
+	return cached.(*structMapping), nil
+}
+
+// checkEmbeddedPointers makes sure that nil pointers to embedded structs on the path to the given field can be
+// allocated by fieldByIndex, which isn't possible if the embedded struct type is unexported.
+func checkEmbeddedPointers(typ reflect.Type, index []int) error {
+	embedded := typ
+	for _, i := range index[:len(index)-1] {
+		field := embedded.Field(i)
+		embedded = field.Type
+		if embedded.Kind() == reflect.Pointer {
+			if !field.IsExported() {
+				return fmt.Errorf("dbutil: can't scan into %s: embedded pointer to unexported type %s", typ, embedded.Elem())
+			}
+			embedded = embedded.Elem()
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but allocates nil pointers to embedded structs instead of panicking.
+func fieldByIndex(val reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val
+}
+
+// resolveColumns returns the struct field index for each column.
+// Columns that don't match any field have the index -1 if ignoreExtra is true.
+func (mapping *structMapping) resolveColumns(typ reflect.Type, columns []string, ignoreExtra bool) ([]int, error) {
+	indexes := make([]int, len(columns))
+	found := make([]bool, len(mapping.fields))
+	for i, column := range columns {
+		idx, ok := mapping.byColumn[column]
+		if !ok {
+			if !ignoreExtra {
+				return nil, fmt.Errorf("%w: %q in %s", ErrUnknownColumn, column, typ)
+			}
+			idx = -1
+		} else {
+			found[idx] = true
+		}
+		indexes[i] = idx
+	}
+	for i, field := range mapping.fields {
+		if !found[i] && !field.optional {
+			return nil, fmt.Errorf("%w: %q in %s", ErrMissingColumn, field.column, typ)
+		}
+	}
+	return indexes, nil
+}
+
+type columnsGetter interface {
+	Columns() ([]string, error)
+}
+
+type structScanner[T any] struct {
+	mapping *structMapping
+	// indexes contains the field index for each column, or nil if the columns aren't known.
+	indexes []int
+}
+
+func newStructScanner[T any](columns []string, ignoreExtra bool) (*structScanner[T], error) {
+	typ := reflect.TypeFor[T]()
+	structType := typ
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	mapping, err := getStructMapping(structType)
+	if err != nil {
+		return nil, err
+	}
+	scanner := &structScanner[T]{mapping: mapping}
+	if columns != nil {
+		scanner.indexes, err = mapping.resolveColumns(structType, columns, ignoreExtra)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scanner, nil
+}
+
+func (ss *structScanner[T]) scan(row Scannable) (out T, err error) {
+	val := reflect.ValueOf(&out).Elem()
+	if val.Kind() == reflect.Pointer {
+		val.Set(reflect.New(val.Type().Elem()))
+		val = val.Elem()
+	}
+	var scanInto []any
+	if ss.indexes == nil {
+		scanInto = make([]any, len(ss.mapping.fields))
+		for i, field := range ss.mapping.fields {
+			scanInto[i] = fieldByIndex(val, field.index).Addr().Interface()
+		}
+	} else {
+		scanInto = make([]any, len(ss.indexes))
+		for i, idx := range ss.indexes {
+			if idx < 0 {
+				scanInto[i] = new(any)
+			} else {
+				scanInto[i] = fieldByIndex(val, ss.mapping.fields[idx].index).Addr().Interface()
+			}
+		}
+	}
+	err = row.Scan(scanInto...)
+	return
+}
+
+func scanStruct[T any](row Scannable, ignoreExtra bool) (T, error) {
+	var columns []string
+	if getter, ok := row.(columnsGetter); ok {
+		var err error
+		columns, err = getter.Columns()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	scanner, err := newStructScanner[T](columns, ignoreExtra)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return scanner.scan(row)
+}
+
+// ScanStruct scans a row into a struct using reflection. T must be a struct or a pointer to a struct.
+//
+// Columns are mapped to fields using `db:"column_name"` struct tags. Fields without a tag or with the tag `db:"-"`
+// are ignored, and fields of embedded structs are included as if they were in the outer struct. Nil pointers to
+// embedded structs are allocated when scanning, so the embedded struct type must be exported. Nullable columns
+// should be scanned into pointer or sql.Null* fields like with normal Scan calls.
+//
+// If the row has column names (i.e. it's a Rows rather than a *sql.Row), every column must match a field and every
+// field must have a column, unless the field is tagged with `db:"name,optional"`. If the column names aren't known,
+// the columns must be in the same order as the fields in the struct.
+//
+// The field mapping is cached per type, so reflection is only used for reading the struct tags once.
+func ScanStruct[T any](row Scannable) (T, error) {
+	return scanStruct[T](row, false)
+}
+
+// ScanStructLenient is like ScanStruct, but columns that don't match any struct field are ignored instead of
+// returning an error.
+func ScanStructLenient[T any](row Scannable) (T, error) {
+	return scanStruct[T](row, true)
+}
+
+func newStructRowIter[T any](rows Rows, err error, ignoreExtra bool) RowIter[T] {
+	if err != nil || rows == nil {
+		return NewRowIterWithError[T](rows, nil, err)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		_ = rows.Close()
+		return NewRowIterWithError[T](nil, nil, err)
+	}
+	scanner, err := newStructScanner[T](columns, ignoreExtra)
+	if err != nil {
+		_ = rows.Close()
+		return NewRowIterWithError[T](nil, nil, err)
+	}
+	return NewRowIter(rows, scanner.scan)
+}
+
+// ScanStructs creates a RowIter that scans each row into a struct with ScanStruct.
+//
+// The columns are only matched to the struct fields once, so this is faster than using ScanStruct with NewRowIter.
+// Mapping errors are returned when iterating.
+func ScanStructs[T any](rows Rows, err error) RowIter[T] {
+	return newStructRowIter[T](rows, err, false)
+}
+
+// ScanStructsLenient is like ScanStructs, but ignores columns that don't match any struct field.
+func ScanStructsLenient[T any](rows Rows, err error) RowIter[T] {
+	return newStructRowIter[T](rows, err, true)
+}
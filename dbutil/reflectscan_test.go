@@ -0,0 +1,156 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+type scanTestTimestamps struct {
+	CreatedAt int64  `db:"created_at"`
+	EditedAt  *int64 `db:"edited_at,optional"`
+}
+
+type scanTestMessage struct {
+	scanTestTimestamps
+	ID       int            `db:"id"`
+	Sender   string         `db:"sender"`
+	Body     sql.NullString `db:"body"`
+	Internal string
+	Ignored  string `db:"-"`
+}
+
+func newScanTestDB(t testing.TB) *dbutil.Database {
+	db := newTestSQLite(t)
+	_, err := db.Exec(context.Background(), `
+		CREATE TABLE message (id INTEGER PRIMARY KEY, sender TEXT NOT NULL, body TEXT, created_at BIGINT NOT NULL, edited_at BIGINT);
+		INSERT INTO message VALUES (1, 'alice', 'hello', 1000, NULL), (2, 'bob', NULL, 2000, 2500);
+	`)
+	require.NoError(t, err)
+	return db
+}
+
+func TestScanStructs(t *testing.T) {
+	db := newScanTestDB(t)
+	editedAt := int64(2500)
+	msgs, err := dbutil.ScanStructs[scanTestMessage](db.Query(context.Background(), "SELECT * FROM message ORDER BY id")).AsList()
+	require.NoError(t, err)
+	assert.Equal(t, []scanTestMessage{{
+		scanTestTimestamps: scanTestTimestamps{CreatedAt: 1000},
+		ID:                 1,
+		Sender:             "alice",
+		Body:               sql.NullString{String: "hello", Valid: true},
+	}, {
+		scanTestTimestamps: scanTestTimestamps{CreatedAt: 2000, EditedAt: &editedAt},
+		ID:                 2,
+		Sender:             "bob",
+	}}, msgs)
+
+	ptrs, err := dbutil.ScanStructs[*scanTestMessage](db.Query(context.Background(), "SELECT sender, id, body, created_at FROM message ORDER BY id")).AsList()
+	require.NoError(t, err)
+	require.Len(t, ptrs, 2)
+	assert.Equal(t, "bob", ptrs[1].Sender)
+	assert.Nil(t, ptrs[1].EditedAt)
+}
+
+func TestScanStructs_Errors(t *testing.T) {
+	db := newScanTestDB(t)
+	ctx := context.Background()
+	_, err := dbutil.ScanStructs[scanTestMessage](db.Query(ctx, "SELECT *, 1 AS extra FROM message")).AsList()
+	assert.ErrorIs(t, err, dbutil.ErrUnknownColumn)
+	assert.ErrorContains(t, err, `"extra"`)
+
+	_, err = dbutil.ScanStructs[scanTestMessage](db.Query(ctx, "SELECT id, sender, created_at FROM message")).AsList()
+	assert.ErrorIs(t, err, dbutil.ErrMissingColumn)
+	assert.ErrorContains(t, err, `"body"`)
+
+	msgs, err := dbutil.ScanStructsLenient[scanTestMessage](db.Query(ctx, "SELECT *, 1 AS extra FROM message")).AsList()
+	require.NoError(t, err)
+	assert.Len(t, msgs, 2)
+
+	_, err = dbutil.ScanStructs[int](db.Query(ctx, "SELECT id FROM message")).AsList()
+	assert.ErrorContains(t, err, "not a struct")
+}
+
+func TestScanStruct(t *testing.T) {
+	db := newScanTestDB(t)
+	ctx := context.Background()
+	msg, err := dbutil.ScanStruct[scanTestMessage](db.QueryRow(ctx, "SELECT created_at, edited_at, id, sender, body FROM message WHERE id=2"))
+	require.NoError(t, err)
+	assert.Equal(t, "bob", msg.Sender)
+	assert.Equal(t, int64(2500), *msg.EditedAt)
+
+	rows, err := db.Query(ctx, "SELECT id, sender, body, created_at FROM message WHERE id=1")
+	require.NoError(t, err)
+	msgs, err := dbutil.NewRowIter(rows, dbutil.ScanStruct[*scanTestMessage]).AsList()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "alice", msgs[0].Sender)
+}
+
+type ScanTestSender struct {
+	Sender string `db:"sender"`
+}
+
+type scanTestMessageWithPointer struct {
+	*ScanTestSender
+	ID int `db:"id"`
+}
+
+type scanTestMessageWithUnexportedPointer struct {
+	*scanTestTimestamps
+	ID int `db:"id"`
+}
+
+func TestScanStruct_EmbeddedPointer(t *testing.T) {
+	db := newScanTestDB(t)
+	ctx := context.Background()
+	msg, err := dbutil.ScanStruct[scanTestMessageWithPointer](db.QueryRow(ctx, "SELECT sender, id FROM message WHERE id=2"))
+	require.NoError(t, err)
+	require.NotNil(t, msg.ScanTestSender)
+	assert.Equal(t, "bob", msg.Sender)
+	assert.Equal(t, 2, msg.ID)
+
+	msgs, err := dbutil.ScanStructsLenient[*scanTestMessageWithPointer](db.Query(ctx, "SELECT * FROM message ORDER BY id")).AsList()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "alice", msgs[0].Sender)
+	assert.Equal(t, 1, msgs[0].ID)
+
+	_, err = dbutil.ScanStruct[scanTestMessageWithUnexportedPointer](db.QueryRow(ctx, "SELECT created_at, id FROM message WHERE id=1"))
+	assert.ErrorContains(t, err, "embedded pointer to unexported type")
+}
+
+func scanMessageManually(row dbutil.Scannable) (msg scanTestMessage, err error) {
+	err = row.Scan(&msg.ID, &msg.Sender, &msg.Body, &msg.CreatedAt, &msg.EditedAt)
+	return
+}
+
+func BenchmarkScanStructs(b *testing.B) {
+	db := newScanTestDB(b)
+	ctx := context.Background()
+	const query = "SELECT id, sender, body, created_at, edited_at FROM message"
+	b.Run("Manual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := dbutil.ConvertRowFn[scanTestMessage](scanMessageManually).NewRowIter(db.Query(ctx, query)).AsList()
+			require.NoError(b, err)
+		}
+	})
+	b.Run("ScanStructs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := dbutil.ScanStructs[scanTestMessage](db.Query(ctx, query)).AsList()
+			require.NoError(b, err)
+		}
+	})
+}
@@ -4,8 +4,15 @@ CREATE TABLE foo (
 	key BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
 	-- only: sqlite (line commented)
 --	key INTEGER PRIMARY KEY,
+	-- only: mysql (line commented)
+--	`key` BIGINT PRIMARY KEY AUTO_INCREMENT,
 
+	-- only: postgres
+	data JSONB NOT NULL
+	-- only: sqlite
 	data JSONB NOT NULL
+	-- only: mysql (line commented)
+--	data JSON NOT NULL
 );
 
 -- only: sqlite until "end only"
@@ -0,0 +1,6 @@
+CREATE TABLE foo (
+	`key` BIGINT PRIMARY KEY AUTO_INCREMENT,
+
+	data JSON NOT NULL
+);
+
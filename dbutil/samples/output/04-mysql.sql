@@ -0,0 +1 @@
+INSERT INTO foo VALUES ('meow', '{}');
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultSlowQueryMaxLength = 1000
+
+// dbutilFuncPrefix is the prefix of the names of all functions in this package, e.g. `go.mau.fi/util/dbutil.`
+var dbutilFuncPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	lastSlash := strings.LastIndexByte(name, '/')
+	return name[:lastSlash+strings.IndexByte(name[lastSlash:], '.')+1]
+}()
+
+// findQueryCaller returns the file and line of the first stack frame outside this package and the standard library.
+func findQueryCaller() string {
+	var pcs [32]uintptr
+	// Skip runtime.Callers, findQueryCaller and logSlowQuery
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, dbutilFuncPrefix) && !strings.HasPrefix(frame.Function, "database/sql.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		} else if !more {
+			return ""
+		}
+	}
+}
+
+func truncateQuery(query string, maxLength int) string {
+	query = strings.TrimSpace(whitespaceRegex.ReplaceAllLiteralString(query, " "))
+	if maxLength == 0 {
+		maxLength = defaultSlowQueryMaxLength
+	}
+	if maxLength < 0 || len(query) <= maxLength {
+		return query
+	}
+	for maxLength > 0 && !utf8.RuneStart(query[maxLength]) {
+		maxLength--
+	}
+	return query[:maxLength] + "…"
+}
+
+func (db *Database) logSlowQuery(ctx context.Context, method, query string, nrows int64, duration time.Duration, err error) {
+	evt := zerolog.Ctx(ctx).Warn()
+	if !evt.Enabled() {
+		return
+	}
+	evt.Err(err).
+		Str("method", method).
+		Str("query", truncateQuery(query, db.SlowQueryMaxLength)).
+		Float64("duration_seconds", duration.Seconds())
+	if nrows >= 0 {
+		evt.Int64("rows", nrows)
+	}
+	if caller := findQueryCaller(); caller != "" {
+		evt.Str(zerolog.CallerFieldName, caller)
+	}
+	evt.Msg("Slow query")
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slowQuery = `
+	WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM counter WHERE x < 300000)
+	SELECT COUNT(*) FROM counter
+`
+
+func parseLogLines(t *testing.T, buf *bytes.Buffer) (lines []map[string]any) {
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var parsed map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &parsed))
+		lines = append(lines, parsed)
+	}
+	return
+}
+
+func TestDatabase_SlowQueryLog(t *testing.T) {
+	db := newTestSQLite(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	var count int
+	require.NoError(t, db.QueryRow(ctx, slowQuery).Scan(&count))
+	assert.Equal(t, 300000, count)
+	assert.Empty(t, buf.String(), "slow query log should be disabled by default")
+
+	db.SlowQueryThreshold = time.Millisecond
+	db.SlowQueryMaxLength = 40
+	rows, err := db.Query(ctx, slowQuery)
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.Exec(ctx, "INSERT INTO foo (id) "+strings.TrimSpace(strings.Replace(slowQuery, "COUNT(*)", "x", 1)))
+	require.NoError(t, err)
+
+	lines := parseLogLines(t, &buf)
+	require.Len(t, lines, 2)
+	for i, method := range []string{"Query", "Exec"} {
+		assert.Equal(t, "Slow query", lines[i]["message"])
+		assert.Equal(t, "warn", lines[i]["level"])
+		assert.Equal(t, method, lines[i]["method"])
+		assert.Greater(t, lines[i]["duration_seconds"], 0.001)
+		assert.Contains(t, lines[i]["caller"], "slowquery_test.go:")
+	}
+	assert.Equal(t, "WITH RECURSIVE counter(x) AS (SELECT 1 U…", lines[0]["query"])
+	assert.Equal(t, "INSERT INTO foo (id) WITH RECURSIVE coun…", lines[1]["query"])
+	assert.EqualValues(t, 1, lines[0]["rows"])
+	assert.EqualValues(t, 300000, lines[1]["rows"])
+}
+
+func TestDatabase_SlowQueryLog_FastQuery(t *testing.T) {
+	db := newTestSQLite(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+	db.SlowQueryThreshold = time.Minute
+	_, err := db.Exec(ctx, "INSERT INTO foo (id) VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, getFooIDs(t, db))
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+}
+
+// stmtCache is a least-recently-used cache of statements prepared on the connection pool.
+type stmtCache struct {
+	db      *sql.DB
+	maxSize int
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+func newStmtCache(db *sql.DB, maxSize int) *stmtCache {
+	return &stmtCache{
+		db:      db,
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element, maxSize),
+		order:   list.New(),
+	}
+}
+
+// SetStatementCacheSize enables caching prepared statements for up to the given number of distinct queries.
+// Zero or negative values disable the cache. The cache is disabled by default.
+//
+// When enabled, Exec, Query and QueryRow calls outside transactions use the cached statement for the query,
+// preparing it on the first use, so frequent queries don't have to be parsed again every time. Queries inside
+// transactions and queries containing multiple statements don't use the cache. The cache is cleared automatically
+// after Upgrade or DowngradeTo modify the schema.
+//
+// This must be called before the database is used or any children are created.
+func (db *Database) SetStatementCacheSize(size int) {
+	if db.stmtCache != nil {
+		db.stmtCache.clear()
+	}
+	if size > 0 {
+		db.stmtCache = newStmtCache(db.RawDB, size)
+	} else {
+		db.stmtCache = nil
+	}
+}
+
+// ClearStatementCache closes all cached prepared statements. The statements will be prepared again when they're used.
+func (db *Database) ClearStatementCache() {
+	db.stmtCache.clear()
+}
+
+func (sc *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, error) {
+	sc.lock.Lock()
+	if elem, ok := sc.items[query]; ok {
+		sc.order.MoveToFront(elem)
+		sc.lock.Unlock()
+		return elem.Value.(*cachedStmt).stmt, nil
+	}
+	sc.lock.Unlock()
+	// Prepare without holding the lock to avoid blocking other queries
+	stmt, err := sc.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if elem, ok := sc.items[query]; ok {
+		// Another goroutine prepared the same query concurrently
+		_ = stmt.Close()
+		sc.order.MoveToFront(elem)
+		return elem.Value.(*cachedStmt).stmt, nil
+	}
+	sc.items[query] = sc.order.PushFront(&cachedStmt{query: query, stmt: stmt})
+	for sc.order.Len() > sc.maxSize {
+		sc.removeElement(sc.order.Back())
+	}
+	return stmt, nil
+}
+
+func (sc *stmtCache) removeElement(elem *list.Element) {
+	item := sc.order.Remove(elem).(*cachedStmt)
+	delete(sc.items, item.query)
+	// Closing is safe even if the statement is being used concurrently: open rows keep the statement alive,
+	// and queries that haven't started yet will get errStmtClosed and fall back to running the query directly.
+	_ = item.stmt.Close()
+}
+
+// invalidate removes the given statement from the cache if it's still cached for the query.
+func (sc *stmtCache) invalidate(query string, stmt *sql.Stmt) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if elem, ok := sc.items[query]; ok && elem.Value.(*cachedStmt).stmt == stmt {
+		sc.removeElement(elem)
+	}
+}
+
+func (sc *stmtCache) clear() {
+	if sc == nil {
+		return
+	}
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	for sc.order.Len() > 0 {
+		sc.removeElement(sc.order.Back())
+	}
+}
+
+func (sc *stmtCache) len() int {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	return sc.order.Len()
+}
+
+// isStmtClosedError checks if the error is the one returned by database/sql when using a closed statement.
+// The error isn't exported, so it has to be compared by message.
+func isStmtClosedError(err error) bool {
+	return err != nil && err.Error() == "sql: statement is closed"
+}
+
+// isMultiStatement checks if the query may contain multiple statements. Prepared statements can only contain
+// one statement (SQLite silently ignores the rest), so such queries must not be cached. Semicolons inside strings
+// also match, but that only means the query isn't cached.
+func isMultiStatement(query string) bool {
+	return strings.Contains(strings.TrimRight(query, "; \t\r\n"), ";")
+}
+
+// cachedStmtFor returns the cached prepared statement for the query if the executable is the connection pool,
+// the statement cache is enabled and the query can be prepared. Otherwise, it returns nil.
+func (le *LoggingExecable) cachedStmtFor(ctx context.Context, query string) *sql.Stmt {
+	if le.db.stmtCache == nil || isMultiStatement(query) {
+		return nil
+	} else if _, isPool := le.UnderlyingExecable.(*sql.DB); !isPool {
+		return nil
+	}
+	stmt, err := le.db.stmtCache.get(ctx, query)
+	if err != nil {
+		// Let the normal query path return the error
+		return nil
+	}
+	return stmt
+}
+
+func (le *LoggingExecable) execWithCache(ctx context.Context, query string, args []any) (sql.Result, error) {
+	if stmt := le.cachedStmtFor(ctx, query); stmt != nil {
+		res, err := stmt.ExecContext(ctx, args...)
+		if !isStmtClosedError(err) {
+			return res, err
+		}
+		le.db.stmtCache.invalidate(query, stmt)
+	}
+	return le.UnderlyingExecable.ExecContext(ctx, query, args...)
+}
+
+func (le *LoggingExecable) queryWithCache(ctx context.Context, query string, args []any) (*sql.Rows, error) {
+	if stmt := le.cachedStmtFor(ctx, query); stmt != nil {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if !isStmtClosedError(err) {
+			return rows, err
+		}
+		le.db.stmtCache.invalidate(query, stmt)
+	}
+	return le.UnderlyingExecable.QueryContext(ctx, query, args...)
+}
+
+func (le *LoggingExecable) queryRowWithCache(ctx context.Context, query string, args []any) *sql.Row {
+	if stmt := le.cachedStmtFor(ctx, query); stmt != nil {
+		row := stmt.QueryRowContext(ctx, args...)
+		if !isStmtClosedError(row.Err()) {
+			return row
+		}
+		le.db.stmtCache.invalidate(query, stmt)
+	}
+	return le.UnderlyingExecable.QueryRowContext(ctx, query, args...)
+}
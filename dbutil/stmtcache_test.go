@@ -0,0 +1,145 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStmtCacheTestDB(t testing.TB, cacheSize int) *Database {
+	db, err := NewWithDialect(filepath.Join(t.TempDir(), "test.db"), "sqlite3")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetStatementCacheSize(cacheSize)
+	_, err = db.Exec(context.Background(), "CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+	_, err = db.Exec(context.Background(), "INSERT INTO foo (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	require.NoError(t, err)
+	return db
+}
+
+func (sc *stmtCache) has(query string) bool {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	_, ok := sc.items[query]
+	return ok
+}
+
+func TestStatementCache_Evict(t *testing.T) {
+	db := newStmtCacheTestDB(t, 2)
+	ctx := context.Background()
+	queries := []string{
+		"SELECT name FROM foo WHERE id=?1",
+		"SELECT name FROM foo WHERE id=?1 AND 1=1",
+		"SELECT name FROM foo WHERE id=?1 AND 2=2",
+	}
+	db.ClearStatementCache()
+	var name string
+	for _, query := range queries {
+		require.NoError(t, db.QueryRow(ctx, query, 2).Scan(&name))
+		assert.Equal(t, "b", name)
+	}
+	assert.Equal(t, 2, db.stmtCache.len())
+	assert.False(t, db.stmtCache.has(queries[0]))
+	assert.True(t, db.stmtCache.has(queries[2]))
+
+	// Using a cached query makes it the most recently used one
+	require.NoError(t, db.QueryRow(ctx, queries[1], 1).Scan(&name))
+	require.NoError(t, db.QueryRow(ctx, queries[0], 3).Scan(&name))
+	assert.Equal(t, "c", name)
+	assert.True(t, db.stmtCache.has(queries[0]))
+	assert.True(t, db.stmtCache.has(queries[1]))
+	assert.False(t, db.stmtCache.has(queries[2]))
+}
+
+func TestStatementCache_ClosedStatement(t *testing.T) {
+	db := newStmtCacheTestDB(t, 10)
+	ctx := context.Background()
+	query := "SELECT COUNT(*) FROM foo"
+	var count int
+	require.NoError(t, db.QueryRow(ctx, query).Scan(&count))
+	mutatedQuery, _ := db.mutateQuery(query, nil)
+	stmt, err := db.stmtCache.get(ctx, mutatedQuery)
+	require.NoError(t, err)
+	// Simulate the statement being closed concurrently
+	require.NoError(t, stmt.Close())
+
+	require.NoError(t, db.QueryRow(ctx, query).Scan(&count))
+	assert.Equal(t, 3, count)
+	assert.False(t, db.stmtCache.has(query))
+	rows, err := db.Query(ctx, query)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.True(t, db.stmtCache.has(query))
+}
+
+func TestStatementCache_NotUsedInTransactions(t *testing.T) {
+	db := newStmtCacheTestDB(t, 10)
+	ctx := context.Background()
+	db.ClearStatementCache()
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		_, err := db.Exec(ctx, "UPDATE foo SET name='x' WHERE id=1")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, db.stmtCache.len())
+	_, err = db.Exec(ctx, "UPDATE foo SET name='y' WHERE id=1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.stmtCache.len())
+}
+
+func TestStatementCache_MultipleStatements(t *testing.T) {
+	db := newStmtCacheTestDB(t, 10)
+	ctx := context.Background()
+	db.ClearStatementCache()
+	_, err := db.Exec(ctx, "INSERT INTO foo (id) VALUES (4); INSERT INTO foo (id) VALUES (5);")
+	require.NoError(t, err)
+	assert.Equal(t, 0, db.stmtCache.len())
+	var count int
+	require.NoError(t, db.QueryRow(ctx, "SELECT COUNT(*) FROM foo;").Scan(&count))
+	assert.Equal(t, 5, count)
+	assert.Equal(t, 1, db.stmtCache.len())
+}
+
+func TestStatementCache_ClearedAfterUpgrade(t *testing.T) {
+	db := newStmtCacheTestDB(t, 10)
+	ctx := context.Background()
+	db.UpgradeTable.Register(-1, 1, 0, "Add column", true, func(ctx context.Context, db *Database) error {
+		_, err := db.Exec(ctx, "ALTER TABLE foo ADD COLUMN extra TEXT")
+		return err
+	})
+	var name string
+	require.NoError(t, db.QueryRow(ctx, "SELECT name FROM foo WHERE id=1").Scan(&name))
+	require.True(t, db.stmtCache.has("SELECT name FROM foo WHERE id=1"))
+	require.NoError(t, db.Upgrade(ctx))
+	assert.False(t, db.stmtCache.has("SELECT name FROM foo WHERE id=1"))
+}
+
+func BenchmarkStatementCache(b *testing.B) {
+	for name, size := range map[string]int{"Disabled": 0, "Enabled": 16} {
+		b.Run(name, func(b *testing.B) {
+			db := newStmtCacheTestDB(b, size)
+			ctx := context.Background()
+			var name string
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := db.QueryRow(ctx, "SELECT name FROM foo WHERE id=$1", i%3+1).Scan(&name)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -25,6 +26,12 @@ var (
 	ErrTxn       = errors.New("transaction")
 	ErrTxnBegin  = fmt.Errorf("%w: begin", ErrTxn)
 	ErrTxnCommit = fmt.Errorf("%w: commit", ErrTxn)
+
+	ErrTxnReadOnly = fmt.Errorf("%w: exec in read-only transaction", ErrTxn)
+	ErrTxnDeadline = fmt.Errorf("%w: deadline exceeded", ErrTxn)
+
+	ErrTxnSavepoint        = fmt.Errorf("%w: savepoint", ErrTxn)
+	ErrTxnSavepointRelease = fmt.Errorf("%w: release savepoint", ErrTxn)
 )
 
 type contextKey int64
@@ -33,6 +40,11 @@ const (
 	ContextKeyDoTxnCallerSkip contextKey = 1
 )
 
+// savepointDepthKey is the context key for the number of savepoints created inside the transaction of a database.
+type savepointDepthKey struct {
+	txnCtxKey contextKey
+}
+
 var nextContextKeyDatabaseTransaction atomic.Uint64
 
 func init() {
@@ -58,13 +70,28 @@ func (db *Database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*LoggingT
 	return db.LoggingDB.BeginTx(ctx, opts)
 }
 
+// DoTxn runs the given function inside a transaction. The context passed to the function must be used for
+// all queries that should be in the transaction. If the function returns an error, the transaction is rolled back.
+// If the context already has a transaction, a savepoint inside that transaction is used instead,
+// in which case the options are ignored.
+//
+// The options are passed to the database driver:
+//
+//   - On Postgres, ReadOnly and Isolation are used for the BEGIN statement (e.g. `BEGIN ISOLATION LEVEL
+//     REPEATABLE READ READ ONLY`). Read-only transactions don't take any row locks, so they're useful for long reads.
+//   - On SQLite, the options are ignored by the driver. Transactions are always serializable and deferred
+//     (unless configured otherwise with the `_txlock` connection parameter), i.e. they only acquire the write lock
+//     when writing, so read-only transactions don't block other writers.
+//
+// Additionally, read-only transactions are sent to ReadOnlyDB if it's set, and Exec calls inside them fail fast
+// with ErrTxnReadOnly without affecting the transaction. Note that queries sent using Query or QueryRow
+// (e.g. `INSERT ... RETURNING`) aren't checked, so they're only rejected if the database enforces read-only mode.
 func (db *Database) DoTxn(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
 	if ctx == nil {
 		panic("DoTxn() called with nil ctx")
 	}
-	if ctx.Value(db.txnCtxKey) != nil {
-		zerolog.Ctx(ctx).Trace().Msg("Already in a transaction, not creating a new one")
-		return fn(ctx)
+	if tx, ok := ctx.Value(db.txnCtxKey).(Transaction); ok {
+		return db.doSavepoint(ctx, tx, fn)
 	}
 
 	log := zerolog.Ctx(ctx).With().Str("db_txn_id", random.String(12)).Logger()
@@ -77,19 +104,34 @@ func (db *Database) DoTxn(ctx context.Context, opts *sql.TxOptions, fn func(ctx
 	if pc, file, line, ok := runtime.Caller(callerSkip); ok {
 		slowLog = log.With().Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(pc, file, line)).Logger()
 	}
+	watchdogInterval := 5 * time.Second
+	var stack []uintptr
+	if db.TxnWatchdogThreshold > 0 {
+		watchdogInterval = db.TxnWatchdogThreshold
+		// Only capture the program counters here, formatting the stack trace is done when it's logged
+		stack = make([]uintptr, 32)
+		stack = stack[:runtime.Callers(callerSkip+1, stack)]
+	}
+	if db.TxnDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, db.TxnDeadline, ErrTxnDeadline)
+		defer cancel()
+	}
 
 	start := time.Now()
 	deadlockCh := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
+		ticker := time.NewTicker(watchdogInterval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				slowLog.Warn().
-					Float64("duration_seconds", time.Since(start).Seconds()).
-					Msg("Transaction still running")
+				evt := slowLog.Warn().Float64("duration_seconds", time.Since(start).Seconds())
+				if stack != nil {
+					evt.Str("stack", formatStack(stack))
+				}
+				evt.Msg("Transaction still running")
 			case <-deadlockCh:
 				return
 			}
@@ -114,10 +156,15 @@ func (db *Database) DoTxn(ctx context.Context, opts *sql.TxOptions, fn func(ctx
 	ctx = log.WithContext(ctx)
 	ctx = context.WithValue(ctx, db.txnCtxKey, tx)
 	err = fn(ctx)
+	if err != nil && errors.Is(context.Cause(ctx), ErrTxnDeadline) {
+		err = exerrors.NewDualError(ErrTxnDeadline, err)
+	}
 	if err != nil {
 		log.Trace().Err(err).Msg("Database transaction failed, rolling back")
 		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
+		if errors.Is(rollbackErr, sql.ErrTxDone) && ctx.Err() != nil {
+			log.Trace().Msg("Transaction was already rolled back due to context cancellation")
+		} else if rollbackErr != nil {
 			log.Warn().Err(rollbackErr).Msg("Rollback after transaction error failed")
 		} else {
 			log.Trace().Msg("Rollback successful")
@@ -133,6 +180,76 @@ func (db *Database) DoTxn(ctx context.Context, opts *sql.TxOptions, fn func(ctx
 	return nil
 }
 
+// doSavepoint runs the given function inside a savepoint in an existing transaction. If the function returns an error,
+// only the changes made inside the savepoint are rolled back, and the outer transaction can still be committed.
+func (db *Database) doSavepoint(ctx context.Context, tx Transaction, fn func(ctx context.Context) error) error {
+	depthKey := savepointDepthKey{db.txnCtxKey}
+	depth, _ := ctx.Value(depthKey).(int)
+	depth++
+	name := fmt.Sprintf("dbutil_savepoint_%d", depth)
+	log := zerolog.Ctx(ctx).With().Str("db_savepoint", name).Logger()
+	err := execSavepointQuery(ctx, tx, "SAVEPOINT "+name)
+	if err != nil {
+		log.Trace().Err(err).Msg("Failed to create savepoint")
+		return exerrors.NewDualError(ErrTxnSavepoint, err)
+	}
+	log.Trace().Msg("Savepoint created")
+	ctx = log.WithContext(ctx)
+	ctx = context.WithValue(ctx, depthKey, depth)
+	err = fn(ctx)
+	if err != nil {
+		log.Trace().Err(err).Msg("Nested transaction failed, rolling back to savepoint")
+		// Rolling back to a savepoint doesn't remove it, so it has to be released separately
+		rollbackErr := execSavepointQuery(ctx, tx, "ROLLBACK TO SAVEPOINT "+name)
+		if rollbackErr == nil {
+			rollbackErr = execSavepointQuery(ctx, tx, "RELEASE SAVEPOINT "+name)
+		}
+		if rollbackErr != nil {
+			log.Warn().Err(rollbackErr).Msg("Rollback to savepoint after nested transaction error failed")
+		} else {
+			log.Trace().Msg("Rollback to savepoint successful")
+		}
+		return err
+	}
+	err = execSavepointQuery(ctx, tx, "RELEASE SAVEPOINT "+name)
+	if err != nil {
+		log.Trace().Err(err).Msg("Releasing savepoint failed")
+		return exerrors.NewDualError(ErrTxnSavepointRelease, err)
+	}
+	log.Trace().Msg("Savepoint released")
+	return nil
+}
+
+// formatStack formats the given program counters like a panic stack trace.
+func formatStack(stack []uintptr) string {
+	var buf strings.Builder
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		_, _ = fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
+
+// execSavepointQuery executes a savepoint query. Savepoints are allowed in read-only transactions,
+// so the read-only check of LoggingTxn is bypassed.
+func execSavepointQuery(ctx context.Context, tx Transaction, query string) error {
+	var err error
+	if lt, ok := tx.(*LoggingTxn); ok {
+		_, err = lt.LoggingExecable.ExecContext(ctx, query)
+	} else {
+		_, err = tx.ExecContext(ctx, query)
+	}
+	return err
+}
+
+// Conn returns the transaction stored in the context by DoTxn if there is one, and the connection pool otherwise.
+//
+// The Exec, Query and QueryRow methods use this to route queries, so functions that take a context work both
+// inside and outside transactions.
 func (db *Database) Conn(ctx context.Context) Execable {
 	if ctx == nil {
 		panic("Conn() called with nil ctx")
@@ -0,0 +1,221 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+var errInner = errors.New("inner transaction failed")
+
+func newTestSQLite(t testing.TB) *dbutil.Database {
+	db, err := dbutil.NewWithDialect(filepath.Join(t.TempDir(), "test.db"), "sqlite3")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	_, err = db.Exec(context.Background(), "CREATE TABLE foo (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+	return db
+}
+
+func insertFoo(db *dbutil.Database, id int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := db.Exec(ctx, "INSERT INTO foo (id) VALUES ($1)", id)
+		return err
+	}
+}
+
+func getFooIDs(t *testing.T, db *dbutil.Database) []int {
+	rows, err := db.Query(context.Background(), "SELECT id FROM foo ORDER BY id")
+	require.NoError(t, err)
+	ids, err := dbutil.NewRowIter(rows, func(row dbutil.Scannable) (id int, err error) {
+		err = row.Scan(&id)
+		return
+	}).AsList()
+	require.NoError(t, err)
+	return ids
+}
+
+func TestDatabase_DoTxn_NestedSavepoints(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		require.NoError(t, insertFoo(db, 1)(ctx))
+		err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+			require.NoError(t, insertFoo(db, 2)(ctx))
+			err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+				require.NoError(t, insertFoo(db, 3)(ctx))
+				return errInner
+			})
+			assert.ErrorIs(t, err, errInner)
+			return insertFoo(db, 4)(ctx)
+		})
+		require.NoError(t, err)
+		err = db.DoTxn(ctx, nil, func(ctx context.Context) error {
+			require.NoError(t, insertFoo(db, 5)(ctx))
+			return db.DoTxn(ctx, nil, func(ctx context.Context) error {
+				require.NoError(t, insertFoo(db, 6)(ctx))
+				return errInner
+			})
+		})
+		assert.ErrorIs(t, err, errInner)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4}, getFooIDs(t, db))
+}
+
+func TestDatabase_DoTxn_NestedErrorPropagated(t *testing.T) {
+	db := newTestSQLite(t)
+	err := db.DoTxn(context.Background(), nil, func(ctx context.Context) error {
+		require.NoError(t, insertFoo(db, 1)(ctx))
+		return db.DoTxn(ctx, nil, func(ctx context.Context) error {
+			require.NoError(t, insertFoo(db, 2)(ctx))
+			return errInner
+		})
+	})
+	assert.ErrorIs(t, err, errInner)
+	assert.Empty(t, getFooIDs(t, db))
+}
+
+func TestDatabase_DoTxn_NestedConstraintError(t *testing.T) {
+	db := newTestSQLite(t)
+	err := db.DoTxn(context.Background(), nil, func(ctx context.Context) error {
+		require.NoError(t, insertFoo(db, 1)(ctx))
+		err := db.DoTxn(ctx, nil, insertFoo(db, 1))
+		assert.Error(t, err)
+		return insertFoo(db, 2)(ctx)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, getFooIDs(t, db))
+}
+
+func TestDatabase_DoTxn_ReadOnly(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	require.NoError(t, insertFoo(db, 1)(ctx))
+	err := db.DoTxn(ctx, &sql.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		err := insertFoo(db, 2)(ctx)
+		assert.ErrorIs(t, err, dbutil.ErrTxnReadOnly)
+		assert.ErrorIs(t, err, dbutil.ErrTxn)
+		// The failed write must not break the transaction
+		var count int
+		require.NoError(t, db.QueryRow(ctx, "SELECT COUNT(*) FROM foo").Scan(&count))
+		assert.Equal(t, 1, count)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, getFooIDs(t, db))
+}
+
+func TestDatabase_DoTxn_ReadOnlyNested(t *testing.T) {
+	db := newTestSQLite(t)
+	err := db.DoTxn(context.Background(), &sql.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		err := db.DoTxn(ctx, nil, insertFoo(db, 1))
+		assert.ErrorIs(t, err, dbutil.ErrTxnReadOnly)
+		return db.DoTxn(ctx, nil, func(ctx context.Context) error {
+			var count int
+			return db.QueryRow(ctx, "SELECT COUNT(*) FROM foo").Scan(&count)
+		})
+	})
+	require.NoError(t, err)
+	assert.Empty(t, getFooIDs(t, db))
+	// Read-write transactions are unaffected
+	require.NoError(t, db.DoTxn(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}, insertFoo(db, 2)))
+	assert.Equal(t, []int{2}, getFooIDs(t, db))
+}
+
+type logBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *logBuffer) entries(t *testing.T, msg string) (entries []map[string]any) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	for _, line := range bytes.Split(bytes.TrimSpace(lb.buf.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(line, &entry))
+		if entry[zerolog.MessageFieldName] == msg {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+func TestDatabase_DoTxn_Watchdog(t *testing.T) {
+	db := newTestSQLite(t)
+	db.TxnWatchdogThreshold = 10 * time.Millisecond
+	var logs logBuffer
+	ctx := zerolog.New(&logs).Level(zerolog.WarnLevel).WithContext(context.Background())
+	err := db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	entries := logs.entries(t, "Transaction still running")
+	require.GreaterOrEqual(t, len(entries), 3)
+	for i, entry := range entries {
+		assert.ElementsMatch(t, []string{"level", "db_txn_id", "caller", "duration_seconds", "stack", "message"}, slices.Collect(maps.Keys(entry)))
+		assert.Equal(t, "warn", entry["level"])
+		assert.GreaterOrEqual(t, entry["duration_seconds"], float64(i+1)*0.01)
+		assert.Contains(t, entry["caller"], "transaction_test.go")
+		assert.Contains(t, entry["stack"], "dbutil_test.TestDatabase_DoTxn_Watchdog\n")
+		assert.NotContains(t, entry["stack"], "dbutil.(*Database).DoTxn")
+	}
+}
+
+func TestDatabase_DoTxn_WatchdogDisabled(t *testing.T) {
+	db := newTestSQLite(t)
+	var logs logBuffer
+	ctx := zerolog.New(&logs).WithContext(context.Background())
+	require.NoError(t, db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}))
+	assert.Empty(t, logs.entries(t, "Transaction still running"))
+}
+
+func TestDatabase_DoTxn_Deadline(t *testing.T) {
+	db := newTestSQLite(t)
+	db.TxnDeadline = 10 * time.Millisecond
+	err := db.DoTxn(context.Background(), nil, func(ctx context.Context) error {
+		require.NoError(t, insertFoo(db, 1)(ctx))
+		time.Sleep(30 * time.Millisecond)
+		return insertFoo(db, 2)(ctx)
+	})
+	assert.ErrorIs(t, err, dbutil.ErrTxnDeadline)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, getFooIDs(t, db))
+	// Transactions that finish in time aren't affected
+	require.NoError(t, db.DoTxn(context.Background(), nil, insertFoo(db, 3)))
+	assert.Equal(t, []int{3}, getFooIDs(t, db))
+}
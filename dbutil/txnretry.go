@@ -0,0 +1,152 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"go.mau.fi/util/exerrors"
+)
+
+// TxnRetryConfig specifies how DoTxnWithRetry retries failed transactions.
+// Fields that are left as zero values use the values from DefaultTxnRetryConfig.
+type TxnRetryConfig struct {
+	// MaxAttempts is the maximum number of times the transaction is attempted, including the first attempt.
+	MaxAttempts int
+	// MaxDuration is the maximum total time spent on all attempts including the delays between them.
+	// A retry is not started if the delay would exceed the limit. The default is no limit.
+	MaxDuration time.Duration
+	// InitialBackoff is the delay before the first retry. The delay is doubled after each retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between attempts.
+	MaxBackoff time.Duration
+	// ShouldRetry decides whether an error returned by DoTxn should be retried.
+	ShouldRetry func(err error) bool
+}
+
+// DefaultTxnRetryConfig contains the default values used by DoTxnWithRetry.
+var DefaultTxnRetryConfig = TxnRetryConfig{
+	MaxAttempts:    5,
+	InitialBackoff: 20 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	ShouldRetry:    IsRetryableTxnError,
+}
+
+func (cfg *TxnRetryConfig) withDefaults() TxnRetryConfig {
+	var out TxnRetryConfig
+	if cfg != nil {
+		out = *cfg
+	}
+	if out.MaxAttempts <= 0 {
+		out.MaxAttempts = DefaultTxnRetryConfig.MaxAttempts
+	}
+	if out.MaxDuration <= 0 {
+		out.MaxDuration = DefaultTxnRetryConfig.MaxDuration
+	}
+	if out.InitialBackoff <= 0 {
+		out.InitialBackoff = DefaultTxnRetryConfig.InitialBackoff
+	}
+	if out.MaxBackoff <= 0 {
+		out.MaxBackoff = DefaultTxnRetryConfig.MaxBackoff
+	}
+	if out.ShouldRetry == nil {
+		out.ShouldRetry = DefaultTxnRetryConfig.ShouldRetry
+	}
+	return out
+}
+
+// backoff returns the delay before the given retry (starting from 1) with jitter applied.
+func (cfg *TxnRetryConfig) backoff(retry int) time.Duration {
+	delay := cfg.MaxBackoff
+	if retry <= 30 {
+		delay = min(cfg.InitialBackoff<<(retry-1), cfg.MaxBackoff)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sqlStateError is implemented by the error types of Postgres drivers (lib/pq and pgx).
+type sqlStateError interface {
+	error
+	SQLState() string
+}
+
+// IsRetryableTxnError checks if the given error is a transient error that may succeed if the transaction is retried.
+//
+// This includes serialization failures (40001) and deadlocks (40P01) on Postgres, deadlocks (1213) and
+// lock wait timeouts (1205) on MySQL, as well as busy and locked errors on SQLite.
+func IsRetryableTxnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	if number, ok := mysqlErrorNumber(err); ok {
+		return number == mysqlErrLockDeadlock || number == mysqlErrLockWaitTimeout
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY")
+}
+
+// DoTxnWithRetry is equivalent to DoTxn, but retries the whole transaction with exponential backoff
+// if it fails with a transient error like a serialization failure on Postgres or a busy database on SQLite.
+//
+// The callback may be called multiple times, so it must be safe to re-run: any state outside the transaction
+// should be reset at the start of the callback, and row iterators must be created inside the callback rather than
+// reused from a previous attempt.
+//
+// If the context already contains a transaction, the callback is run in a savepoint without retries, as the
+// outer transaction is the one that would need to be retried. The context being canceled stops retrying,
+// in which case the returned error will match both the context error and the last transaction error.
+func (db *Database) DoTxnWithRetry(ctx context.Context, opts *sql.TxOptions, retry *TxnRetryConfig, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		panic("DoTxnWithRetry() called with nil ctx")
+	}
+	callerSkip := 1
+	if val := ctx.Value(ContextKeyDoTxnCallerSkip); val != nil {
+		callerSkip += val.(int)
+	}
+	ctx = context.WithValue(ctx, ContextKeyDoTxnCallerSkip, callerSkip)
+	if ctx.Value(db.txnCtxKey) != nil {
+		return db.DoTxn(ctx, opts, fn)
+	}
+	cfg := retry.withDefaults()
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := db.DoTxn(ctx, opts, fn)
+		if err == nil || attempt >= cfg.MaxAttempts || !cfg.ShouldRetry(err) {
+			return err
+		}
+		delay := cfg.backoff(attempt)
+		if cfg.MaxDuration > 0 && time.Since(start)+delay > cfg.MaxDuration {
+			return err
+		}
+		zerolog.Ctx(ctx).Debug().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("backoff", delay).
+			Msg("Transaction failed with retryable error, retrying")
+		select {
+		case <-ctx.Done():
+			return exerrors.NewDualError(ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDatabaseLocked = errors.New("database is locked")
+
+type fakeSQLStateError string
+
+func (err fakeSQLStateError) Error() string {
+	return "sql state " + string(err)
+}
+
+func (err fakeSQLStateError) SQLState() string {
+	return string(err)
+}
+
+func TestIsRetryableTxnError(t *testing.T) {
+	assert.False(t, IsRetryableTxnError(nil))
+	assert.False(t, IsRetryableTxnError(errors.New("syntax error")))
+	assert.False(t, IsRetryableTxnError(fakeSQLStateError("23505")))
+	assert.True(t, IsRetryableTxnError(fakeSQLStateError("40001")))
+	assert.True(t, IsRetryableTxnError(fakeSQLStateError("40P01")))
+	assert.True(t, IsRetryableTxnError(fmt.Errorf("failed to insert: %w", fakeSQLStateError("40001"))))
+	assert.True(t, IsRetryableTxnError(errDatabaseLocked))
+	assert.True(t, IsRetryableTxnError(errors.New("database table is locked: foo")))
+	assert.True(t, IsRetryableTxnError(errors.New("database is locked (5) (SQLITE_BUSY)")))
+	assert.True(t, IsRetryableTxnError(&MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}))
+	assert.True(t, IsRetryableTxnError(fmt.Errorf("failed to update: %w", &MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})))
+	assert.False(t, IsRetryableTxnError(&MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}))
+}
+
+func newMockDatabase(t *testing.T) (*Database, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := NewWithDB(conn, "sqlite3")
+	require.NoError(t, err)
+	return db, mock
+}
+
+var fastRetry = &TxnRetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestDatabase_DoTxnWithRetry(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO foo VALUES (1)").WillReturnError(errDatabaseLocked)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO foo VALUES (1)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var logs bytes.Buffer
+	ctx := zerolog.New(&logs).WithContext(context.Background())
+	attempts := 0
+	err := db.DoTxnWithRetry(ctx, nil, fastRetry, func(ctx context.Context) error {
+		attempts++
+		_, err := db.Exec(ctx, "INSERT INTO foo VALUES (1)")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, logs.String(), `"level":"debug"`)
+	assert.Contains(t, logs.String(), `"attempt":1`)
+}
+
+func TestDatabase_DoTxnWithRetry_NotRetryable(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	errSyntax := errors.New("syntax error")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts := 0
+	err := db.DoTxnWithRetry(context.Background(), nil, fastRetry, func(ctx context.Context) error {
+		attempts++
+		return errSyntax
+	})
+	assert.ErrorIs(t, err, errSyntax)
+	assert.Equal(t, 1, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabase_DoTxnWithRetry_MaxAttempts(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+
+	attempts := 0
+	cfg := *fastRetry
+	cfg.MaxAttempts = 3
+	err := db.DoTxnWithRetry(context.Background(), nil, &cfg, func(ctx context.Context) error {
+		attempts++
+		return errDatabaseLocked
+	})
+	assert.ErrorIs(t, err, errDatabaseLocked)
+	assert.Equal(t, 3, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabase_DoTxnWithRetry_MaxDuration(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts := 0
+	cfg := TxnRetryConfig{InitialBackoff: time.Second, MaxBackoff: time.Second, MaxDuration: 100 * time.Millisecond}
+	err := db.DoTxnWithRetry(context.Background(), nil, &cfg, func(ctx context.Context) error {
+		attempts++
+		return errDatabaseLocked
+	})
+	assert.ErrorIs(t, err, errDatabaseLocked)
+	assert.Equal(t, 1, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabase_DoTxnWithRetry_ContextCanceled(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := TxnRetryConfig{InitialBackoff: time.Minute, MaxBackoff: time.Minute}
+	err := db.DoTxnWithRetry(ctx, nil, &cfg, func(ctx context.Context) error {
+		cancel()
+		return errDatabaseLocked
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errDatabaseLocked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabase_DoTxnWithRetry_Nested(t *testing.T) {
+	db, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT dbutil_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT dbutil_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT dbutil_savepoint_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	innerAttempts := 0
+	err := db.DoTxn(context.Background(), nil, func(ctx context.Context) error {
+		return db.DoTxnWithRetry(ctx, nil, fastRetry, func(ctx context.Context) error {
+			innerAttempts++
+			return errDatabaseLocked
+		})
+	})
+	assert.ErrorIs(t, err, errDatabaseLocked)
+	assert.Equal(t, 1, innerAttempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxnRetryConfig_Backoff(t *testing.T) {
+	cfg := TxnRetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, maxDelay := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 100: time.Second} {
+		for i := 0; i < 20; i++ {
+			delay := cfg.backoff(retry)
+			assert.GreaterOrEqual(t, delay, maxDelay/2)
+			assert.LessOrEqual(t, delay, maxDelay)
+		}
+	}
+}
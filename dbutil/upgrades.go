@@ -9,8 +9,13 @@ package dbutil
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
 )
 
 type upgradeFunc func(context.Context, *Database) error
@@ -18,6 +23,10 @@ type upgradeFunc func(context.Context, *Database) error
 type upgrade struct {
 	message string
 	fn      upgradeFunc
+	revert  upgradeFunc
+	// checksum is a hash of the upgrade SQL or a caller-provided fingerprint, which is stored in the version table
+	// to detect upgrades that were modified after being applied.
+	checksum string
 
 	upgradesTo    int
 	compatVersion int
@@ -28,6 +37,18 @@ var ErrUnsupportedDatabaseVersion = errors.New("unsupported database schema vers
 var ErrForeignTables = errors.New("the database contains foreign tables")
 var ErrNotOwned = errors.New("the database is owned by")
 var ErrUnsupportedDialect = errors.New("unsupported database dialect")
+var ErrNoRevert = errors.New("upgrade doesn't have a revert function")
+var ErrInvalidDowngrade = errors.New("invalid downgrade target")
+var ErrUpgradeChecksumMismatch = errors.New("applied upgrades don't match registered upgrades")
+
+// checksumsColumnType returns the type of the checksums column in the version table.
+// TEXT is limited to 64 KiB on MySQL, which isn't enough for the checksums of a long upgrade history.
+func (db *Database) checksumsColumnType() string {
+	if db.Dialect == MySQL {
+		return "LONGTEXT"
+	}
+	return "TEXT"
+}
 
 func (db *Database) upgradeVersionTable(ctx context.Context) error {
 	if compatColumnExists, err := db.ColumnExists(ctx, db.VersionTable, "compat"); err != nil {
@@ -36,10 +57,11 @@ func (db *Database) upgradeVersionTable(ctx context.Context) error {
 		if tableExists, err := db.TableExists(ctx, db.VersionTable); err != nil {
 			return fmt.Errorf("failed to check if version table exists: %w", err)
 		} else if !tableExists {
-			_, err = db.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (version INTEGER, compat INTEGER)", db.VersionTable))
+			_, err = db.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (version INTEGER, compat INTEGER, checksums %s)", db.VersionTable, db.checksumsColumnType()))
 			if err != nil {
 				return fmt.Errorf("failed to create version table: %w", err)
 			}
+			return nil
 		} else {
 			_, err = db.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN compat INTEGER", db.VersionTable))
 			if err != nil {
@@ -47,16 +69,31 @@ func (db *Database) upgradeVersionTable(ctx context.Context) error {
 			}
 		}
 	}
+	// Databases created before checksums were added will have NULL checksums, which means nothing is verified
+	// until new upgrades are applied.
+	if checksumsColumnExists, err := db.ColumnExists(ctx, db.VersionTable, "checksums"); err != nil {
+		return fmt.Errorf("failed to check if version table is up to date: %w", err)
+	} else if !checksumsColumnExists {
+		_, err = db.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN checksums %s", db.VersionTable, db.checksumsColumnType()))
+		if err != nil {
+			return fmt.Errorf("failed to add checksums column to version table: %w", err)
+		}
+	}
 	return nil
 }
 
-func (db *Database) getVersion(ctx context.Context) (version, compat int, err error) {
+// upgradeChecksums maps the source version of applied upgrades to their checksums.
+type upgradeChecksums map[int]string
+
+func (db *Database) getVersion(ctx context.Context) (version, compat int, checksums upgradeChecksums, err error) {
 	if err = db.upgradeVersionTable(ctx); err != nil {
 		return
 	}
 
 	var compatNull sql.NullInt32
-	err = db.QueryRow(ctx, fmt.Sprintf("SELECT version, compat FROM %s LIMIT 1", db.VersionTable)).Scan(&version, &compatNull)
+	var checksumsJSON sql.NullString
+	err = db.QueryRow(ctx, fmt.Sprintf("SELECT version, compat, checksums FROM %s LIMIT 1", db.VersionTable)).
+		Scan(&version, &compatNull, &checksumsJSON)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = nil
 	}
@@ -65,12 +102,20 @@ func (db *Database) getVersion(ctx context.Context) (version, compat int, err er
 	} else {
 		compat = version
 	}
+	checksums = make(upgradeChecksums)
+	if err == nil && checksumsJSON.Valid && checksumsJSON.String != "" {
+		err = json.Unmarshal([]byte(checksumsJSON.String), &checksums)
+		if err != nil {
+			err = fmt.Errorf("failed to parse upgrade checksums in version table: %w", err)
+		}
+	}
 	return
 }
 
 const (
 	tableExistsPostgres = "SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name=$1)"
 	tableExistsSQLite   = "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type='table' AND tbl_name=?1)"
+	tableExistsMySQL    = "SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema=DATABASE() AND table_name=?)"
 )
 
 func (db *Database) TableExists(ctx context.Context, table string) (exists bool, err error) {
@@ -79,6 +124,8 @@ func (db *Database) TableExists(ctx context.Context, table string) (exists bool,
 		err = db.QueryRow(ctx, tableExistsSQLite, table).Scan(&exists)
 	case Postgres:
 		err = db.QueryRow(ctx, tableExistsPostgres, table).Scan(&exists)
+	case MySQL:
+		err = db.QueryRow(ctx, tableExistsMySQL, table).Scan(&exists)
 	default:
 		err = ErrUnsupportedDialect
 	}
@@ -88,6 +135,7 @@ func (db *Database) TableExists(ctx context.Context, table string) (exists bool,
 const (
 	columnExistsPostgres = "SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name=$1 AND column_name=$2)"
 	columnExistsSQLite   = "SELECT EXISTS(SELECT 1 FROM pragma_table_info(?1) WHERE name=?2)"
+	columnExistsMySQL    = "SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_schema=DATABASE() AND table_name=? AND column_name=?)"
 )
 
 func (db *Database) ColumnExists(ctx context.Context, table, column string) (exists bool, err error) {
@@ -96,6 +144,8 @@ func (db *Database) ColumnExists(ctx context.Context, table, column string) (exi
 		err = db.QueryRow(ctx, columnExistsSQLite, table, column).Scan(&exists)
 	case Postgres:
 		err = db.QueryRow(ctx, columnExistsPostgres, table, column).Scan(&exists)
+	case MySQL:
+		err = db.QueryRow(ctx, columnExistsMySQL, table, column).Scan(&exists)
 	default:
 		err = ErrUnsupportedDialect
 	}
@@ -117,6 +167,15 @@ CREATE TABLE IF NOT EXISTS database_owner (
 )
 `
 
+// createOwnerTableMySQL is the same as createOwnerTable, but with the key column quoted, as KEY is a reserved word
+// in MySQL.
+const createOwnerTableMySQL = `
+CREATE TABLE IF NOT EXISTS database_owner (
+	` + "`key`" + ` INTEGER PRIMARY KEY DEFAULT 0,
+	owner TEXT NOT NULL
+)
+`
+
 func (db *Database) checkDatabaseOwner(ctx context.Context) error {
 	var owner string
 	if !db.IgnoreForeignTables {
@@ -129,10 +188,14 @@ func (db *Database) checkDatabaseOwner(ctx context.Context) error {
 	if db.Owner == "" {
 		return nil
 	}
-	if _, err := db.Exec(ctx, createOwnerTable); err != nil {
+	createQuery, keyColumn := createOwnerTable, "key"
+	if db.Dialect == MySQL {
+		createQuery, keyColumn = createOwnerTableMySQL, "`key`"
+	}
+	if _, err := db.Exec(ctx, createQuery); err != nil {
 		return fmt.Errorf("failed to ensure database owner table exists: %w", err)
-	} else if err = db.QueryRow(ctx, "SELECT owner FROM database_owner WHERE key=0").Scan(&owner); errors.Is(err, sql.ErrNoRows) {
-		_, err = db.Exec(ctx, "INSERT INTO database_owner (key, owner) VALUES (0, $1)", db.Owner)
+	} else if err = db.QueryRow(ctx, fmt.Sprintf("SELECT owner FROM database_owner WHERE %s=0", keyColumn)).Scan(&owner); errors.Is(err, sql.ErrNoRows) {
+		_, err = db.Exec(ctx, fmt.Sprintf("INSERT INTO database_owner (%s, owner) VALUES (0, $1)", keyColumn), db.Owner)
 		if err != nil {
 			return fmt.Errorf("failed to insert database owner: %w", err)
 		}
@@ -144,22 +207,60 @@ func (db *Database) checkDatabaseOwner(ctx context.Context) error {
 	return nil
 }
 
-func (db *Database) setVersion(ctx context.Context, version, compat int) error {
+func (db *Database) setVersion(ctx context.Context, version, compat int, checksums upgradeChecksums) error {
+	var checksumsJSON sql.NullString
+	if len(checksums) > 0 {
+		data, err := json.Marshal(checksums)
+		if err != nil {
+			return err
+		}
+		checksumsJSON = sql.NullString{String: string(data), Valid: true}
+	}
 	_, err := db.Exec(ctx, fmt.Sprintf("DELETE FROM %s", db.VersionTable))
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version, compat) VALUES ($1, $2)", db.VersionTable), version, compat)
+	_, err = db.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version, compat, checksums) VALUES ($1, $2, $3)", db.VersionTable), version, compat, checksumsJSON)
 	return err
 }
 
+// verifyChecksums compares the checksums of applied upgrades to the currently registered upgrades.
+// Upgrades that don't have a checksum on either side are skipped.
+func (db *Database) verifyChecksums(ctx context.Context, checksums upgradeChecksums) error {
+	var mismatches []string
+	for from, checksum := range checksums {
+		if from < 0 || from >= len(db.UpgradeTable) {
+			continue
+		}
+		upgradeItem := db.UpgradeTable[from]
+		if upgradeItem.checksum != "" && upgradeItem.checksum != checksum {
+			mismatches = append(mismatches, fmt.Sprintf("v%d->v%d (%s)", from, upgradeItem.upgradesTo, upgradeItem.message))
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	slices.Sort(mismatches)
+	if db.StrictUpgradeChecksums {
+		return fmt.Errorf("%w: %s", ErrUpgradeChecksumMismatch, strings.Join(mismatches, ", "))
+	}
+	zerolog.Ctx(ctx).Warn().
+		Strs("upgrades", mismatches).
+		Msg("Upgrades have been modified after they were applied to the database")
+	return nil
+}
+
 func (db *Database) Upgrade(ctx context.Context) error {
+	if db.upgradeLock != nil {
+		db.upgradeLock.Lock()
+		defer db.upgradeLock.Unlock()
+	}
 	err := db.checkDatabaseOwner(ctx)
 	if err != nil {
 		return err
 	}
 
-	version, compat, err := db.getVersion(ctx)
+	version, compat, checksums, err := db.getVersion(ctx)
 	if err != nil {
 		return err
 	}
@@ -170,9 +271,13 @@ func (db *Database) Upgrade(ctx context.Context) error {
 			return nil
 		}
 		return fmt.Errorf("%w: currently on v%d (compatible down to v%d), latest known: v%d", ErrUnsupportedDatabaseVersion, version, compat, len(db.UpgradeTable))
+	} else if err = db.verifyChecksums(ctx, checksums); err != nil {
+		return err
 	}
 
 	db.Log.PrepareUpgrade(version, compat, len(db.UpgradeTable))
+	// Cached statements may have been prepared against the old schema
+	defer db.stmtCache.clear()
 	logVersion := version
 	for version < len(db.UpgradeTable) {
 		upgradeItem := db.UpgradeTable[version]
@@ -185,9 +290,12 @@ func (db *Database) Upgrade(ctx context.Context) error {
 			if err != nil {
 				return fmt.Errorf("failed to run upgrade v%d->v%d: %w", version, upgradeItem.upgradesTo, err)
 			}
+			if upgradeItem.checksum != "" {
+				checksums[version] = upgradeItem.checksum
+			}
 			version = upgradeItem.upgradesTo
 			logVersion = version
-			err = db.setVersion(ctx, version, upgradeItem.compatVersion)
+			err = db.setVersion(ctx, version, upgradeItem.compatVersion, checksums)
 			if err != nil {
 				return err
 			}
@@ -205,3 +313,72 @@ func (db *Database) Upgrade(ctx context.Context) error {
 	}
 	return nil
 }
+
+// findUpgradeTo returns the index (i.e. the source version) of the upgrade that upgrades to the given version.
+// If there are multiple such upgrades, the one with the highest source version is returned. If there are none,
+// -1 is returned.
+func (ut UpgradeTable) findUpgradeTo(version int) int {
+	for i := min(version, len(ut)) - 1; i >= 0; i-- {
+		if ut[i].fn != nil && ut[i].upgradesTo == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// compatVersionOf returns the compatible version that was stored when upgrading to the given version.
+func (ut UpgradeTable) compatVersionOf(version int) int {
+	if from := ut.findUpgradeTo(version); from >= 0 {
+		return ut[from].compatVersion
+	}
+	return version
+}
+
+// DowngradeTo reverts upgrades using the revert functions registered with UpgradeTable.RegisterWithRevert
+// until the database is at the given version.
+//
+// All reverts are done in a single transaction, and the version table is updated after each revert.
+// If any upgrade between the current and target versions doesn't have a revert function, an error
+// is returned before anything is reverted.
+func (db *Database) DowngradeTo(ctx context.Context, target int) error {
+	if db.upgradeLock != nil {
+		db.upgradeLock.Lock()
+		defer db.upgradeLock.Unlock()
+	}
+	version, _, checksums, err := db.getVersion(ctx)
+	if err != nil {
+		return err
+	} else if target < 0 || target > version {
+		return fmt.Errorf("%w: can't downgrade from v%d to v%d", ErrInvalidDowngrade, version, target)
+	}
+	var reverts []int
+	for current := version; current > target; {
+		from := db.UpgradeTable.findUpgradeTo(current)
+		if from < 0 {
+			return fmt.Errorf("%w: no upgrade to v%d found", ErrInvalidDowngrade, current)
+		} else if from < target {
+			return fmt.Errorf("%w: upgrade v%d->v%d skips over v%d", ErrInvalidDowngrade, from, current, target)
+		} else if db.UpgradeTable[from].revert == nil {
+			return fmt.Errorf("%w: v%d->v%d (%s)", ErrNoRevert, from, current, db.UpgradeTable[from].message)
+		}
+		reverts = append(reverts, from)
+		current = from
+	}
+	defer db.stmtCache.clear()
+	return db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, from := range reverts {
+			upgradeItem := db.UpgradeTable[from]
+			db.Log.DoUpgrade(upgradeItem.upgradesTo, from, "Revert: "+upgradeItem.message, true)
+			err := upgradeItem.revert(ctx, db)
+			if err != nil {
+				return fmt.Errorf("failed to revert upgrade v%d->v%d: %w", from, upgradeItem.upgradesTo, err)
+			}
+			delete(checksums, from)
+			err = db.setVersion(ctx, from, db.UpgradeTable.compatVersionOf(from), checksums)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
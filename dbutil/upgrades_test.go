@@ -9,6 +9,7 @@ package dbutil
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -26,29 +27,39 @@ func makeTable() (tbl UpgradeTable) {
 }
 
 func expectVersionCheck(dialect Dialect, mock sqlmock.Sqlmock, returnVersion, returnCompat int) {
-	if dialect == Postgres {
-		mock.ExpectQuery(columnExistsPostgres).
-			WithArgs("version", "compat").
-			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
-	} else if dialect == SQLite {
-		mock.ExpectQuery(columnExistsSQLite).
-			WithArgs("version", "compat").
-			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	for _, column := range []string{"compat", "checksums"} {
+		if dialect == Postgres {
+			mock.ExpectQuery(columnExistsPostgres).
+				WithArgs("version", column).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		} else if dialect == SQLite {
+			mock.ExpectQuery(columnExistsSQLite).
+				WithArgs("version", column).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		} else if dialect == MySQL {
+			mock.ExpectQuery(columnExistsMySQL).
+				WithArgs("version", column).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		}
 	}
-	mock.ExpectQuery("SELECT version, compat FROM version LIMIT 1").
-		WillReturnRows(sqlmock.NewRows([]string{"version", "compat"}).AddRow(returnVersion, returnCompat))
+	mock.ExpectQuery("SELECT version, compat, checksums FROM version LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "compat", "checksums"}).AddRow(returnVersion, returnCompat, nil))
 }
 
-func expectVersionBump(dialect Dialect, mock sqlmock.Sqlmock, toVersion, toCompat int) {
+func expectVersionBump(dialect Dialect, mock sqlmock.Sqlmock, toVersion, toCompat int, checksums upgradeChecksums) {
 	mock.ExpectExec("DELETE FROM version").
 		WillReturnResult(sqlmock.NewResult(0, 1))
-	q := "INSERT INTO version (version, compat) VALUES ($1, $2)"
+	q := "INSERT INTO version (version, compat, checksums) VALUES ($1, $2, $3)"
 	if dialect == SQLite {
 		q = strings.ReplaceAll(q, "$1", "?1")
 		q = strings.ReplaceAll(q, "$2", "?2")
+		q = strings.ReplaceAll(q, "$3", "?3")
+	} else if dialect == MySQL {
+		q = "INSERT INTO version (version, compat, checksums) VALUES (?, ?, ?)"
 	}
+	checksumsJSON, _ := json.Marshal(checksums)
 	mock.ExpectExec(q).
-		WithArgs(toVersion, toCompat).
+		WithArgs(toVersion, toCompat, string(checksumsJSON)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 }
 
@@ -81,15 +92,18 @@ func testUpgrade(dialect Dialect) func(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec(string(expectedUpgrade1)).
 			WillReturnResult(sqlmock.NewResult(0, 0))
-		expectVersionBump(db.Dialect, mock, 3, 3)
+		checksums := upgradeChecksums{0: db.UpgradeTable[0].checksum}
+		expectVersionBump(db.Dialect, mock, 3, 3, checksums)
 		mock.ExpectCommit()
 		mock.ExpectExec(string(expectedUpgrade2)).
 			WillReturnResult(sqlmock.NewResult(0, 0))
-		expectVersionBump(db.Dialect, mock, 4, 4)
+		checksums[3] = db.UpgradeTable[3].checksum
+		expectVersionBump(db.Dialect, mock, 4, 4, checksums)
 		mock.ExpectBegin()
 		mock.ExpectExec(string(expectedUpgrade3)).
 			WillReturnResult(sqlmock.NewResult(0, 0))
-		expectVersionBump(db.Dialect, mock, 5, 3)
+		checksums[4] = db.UpgradeTable[4].checksum
+		expectVersionBump(db.Dialect, mock, 5, 3, checksums)
 		mock.ExpectCommit()
 		err = db.Upgrade(context.TODO())
 		require.NoError(t, err)
@@ -135,9 +149,11 @@ func testCompatCheck(dialect Dialect) func(t *testing.T) {
 func TestDatabase_Upgrade(t *testing.T) {
 	t.Run("SQLite", testUpgrade(SQLite))
 	t.Run("Postgres", testUpgrade(Postgres))
+	t.Run("MySQL", testUpgrade(MySQL))
 }
 
 func TestDatabase_Upgrade_CompatCheck(t *testing.T) {
 	t.Run("SQLite", testCompatCheck(SQLite))
 	t.Run("Postgres", testCompatCheck(Postgres))
+	t.Run("MySQL", testCompatCheck(MySQL))
 }
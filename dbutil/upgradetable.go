@@ -9,6 +9,8 @@ package dbutil
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -31,6 +33,34 @@ func (ut *UpgradeTable) extend(toSize int) {
 }
 
 func (ut *UpgradeTable) Register(from, to, compat int, message string, txn bool, fn upgradeFunc) {
+	ut.register(from, to, compat, message, txn, fn, nil)
+}
+
+// RegisterWithRevert registers an upgrade like Register, but also includes a function that reverts the upgrade.
+// The revert function is used by Database.DowngradeTo and must bring the schema back to the from version.
+func (ut *UpgradeTable) RegisterWithRevert(from, to, compat int, message string, txn bool, fn, revert upgradeFunc) {
+	ut.register(from, to, compat, message, txn, fn, revert)
+}
+
+// SetFingerprint sets the checksum of the upgrade registered at the given source version. Upgrades loaded from SQL files
+// have a checksum of the file contents automatically, but upgrades implemented as Go functions need a manually
+// specified fingerprint, which should be changed whenever the upgrade is changed. See Database.StrictUpgradeChecksums.
+func (ut UpgradeTable) SetFingerprint(from int, fingerprint string) {
+	if from < 0 || from >= len(ut) || ut[from].fn == nil {
+		panic(fmt.Errorf("tried to set fingerprint of nonexistent upgrade at %d", from))
+	}
+	ut[from].checksum = fingerprint
+}
+
+func sqlChecksum(data ...[]byte) string {
+	hash := sha256.New()
+	for _, item := range data {
+		hash.Write(item)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (ut *UpgradeTable) register(from, to, compat int, message string, txn bool, fn, revert upgradeFunc) *upgrade {
 	if from < 0 {
 		from += to
 	}
@@ -40,16 +70,17 @@ func (ut *UpgradeTable) Register(from, to, compat int, message string, txn bool,
 	if compat <= 0 {
 		compat = to
 	}
-	upg := upgrade{message: message, fn: fn, upgradesTo: to, compatVersion: compat, transaction: txn}
+	upg := upgrade{message: message, fn: fn, revert: revert, upgradesTo: to, compatVersion: compat, transaction: txn}
 	if len(*ut) == from {
 		*ut = append(*ut, upg)
-		return
+		return &(*ut)[from]
 	} else if len(*ut) < from {
 		ut.extend(from + 1)
 	} else if (*ut)[from].fn != nil {
 		panic(fmt.Errorf("tried to override upgrade at %d ('%s') with '%s'", from, (*ut)[from].message, upg.message))
 	}
 	(*ut)[from] = upg
+	return &(*ut)[from]
 }
 
 // Syntax is either
@@ -123,7 +154,7 @@ func parseFileHeader(file []byte) (from, to, compat int, message string, txn boo
 // If the single-line limit is on the second line of the file, the whole file is limited to that dialect.
 //
 // If the filter ends with `(lines commented)`, then ALL lines chosen by the filter will be uncommented.
-var dialectLineFilter = regexp.MustCompile(`^\s*-- only: (postgres|sqlite)(?: for next (\d+) lines| until "(end) only")?(?: \(lines? (commented)\))?`)
+var dialectLineFilter = regexp.MustCompile(`^\s*-- only: (postgres|sqlite|mysql)(?: for next (\d+) lines| until "(end) only")?(?: \(lines? (commented)\))?`)
 
 // Constants used to make parseDialectFilter clearer
 const (
@@ -155,7 +186,7 @@ func (db *Database) parseDialectFilter(line []byte) (dialect Dialect, lineCount
 	return
 }
 
-var endLineFilter = regexp.MustCompile(`^\s*-- end only (postgres|sqlite)$`)
+var endLineFilter = regexp.MustCompile(`^\s*-- end only (postgres|sqlite|mysql)$`)
 
 func (db *Database) filterSQLUpgrade(lines [][]byte) (string, error) {
 	output := make([][]byte, 0, len(lines))
@@ -221,13 +252,19 @@ func sqlUpgradeFunc(fileName string, lines [][]byte) upgradeFunc {
 	}
 }
 
-func splitSQLUpgradeFunc(sqliteData, postgresData string) upgradeFunc {
+func splitSQLUpgradeFunc(sqliteData, postgresData, mysqlData string) upgradeFunc {
 	return func(ctx context.Context, db *Database) (err error) {
 		switch db.Dialect {
 		case SQLite:
 			_, err = db.Exec(ctx, sqliteData)
 		case Postgres:
 			_, err = db.Exec(ctx, postgresData)
+		case MySQL:
+			if mysqlData == "" {
+				err = fmt.Errorf("%w: upgrade doesn't have a mysql version", ErrUnsupportedDialect)
+			} else {
+				_, err = db.Exec(ctx, mysqlData)
+			}
 		default:
 			err = fmt.Errorf("unknown dialect %s", db.Dialect)
 		}
@@ -235,16 +272,16 @@ func splitSQLUpgradeFunc(sqliteData, postgresData string) upgradeFunc {
 	}
 }
 
-func parseSplitSQLUpgrade(name string, fs fullFS, skipNames map[string]struct{}) (from, to, compat int, message string, txn bool, fn upgradeFunc) {
+func parseSplitSQLUpgrade(name string, fsys fullFS, skipNames map[string]struct{}) (from, to, compat int, message string, txn bool, fn upgradeFunc, checksum string) {
 	postgresName := fmt.Sprintf("%s.postgres.sql", name)
 	sqliteName := fmt.Sprintf("%s.sqlite.sql", name)
 	skipNames[postgresName] = struct{}{}
 	skipNames[sqliteName] = struct{}{}
-	postgresData, err := fs.ReadFile(postgresName)
+	postgresData, err := fsys.ReadFile(postgresName)
 	if err != nil {
 		panic(err)
 	}
-	sqliteData, err := fs.ReadFile(sqliteName)
+	sqliteData, err := fsys.ReadFile(sqliteName)
 	if err != nil {
 		panic(err)
 	}
@@ -263,7 +300,25 @@ func parseSplitSQLUpgrade(name string, fs fullFS, skipNames map[string]struct{})
 	} else if txn != sqliteTxn {
 		panic(fmt.Errorf("mismatching transaction flag in postgres and sqlite versions of %s: %t != %t", name, txn, sqliteTxn))
 	}
-	fn = splitSQLUpgradeFunc(string(sqliteData), string(postgresData))
+	// The MySQL version is optional, as most upgrades are only written for Postgres and SQLite
+	mysqlName := fmt.Sprintf("%s.mysql.sql", name)
+	skipNames[mysqlName] = struct{}{}
+	mysqlData, err := fsys.ReadFile(mysqlName)
+	if errors.Is(err, fs.ErrNotExist) {
+		mysqlData = nil
+	} else if err != nil {
+		panic(err)
+	} else if mysqlFrom, mysqlTo, mysqlCompat, mysqlMessage, mysqlTxn, _, err := parseFileHeader(mysqlData); err != nil {
+		panic(fmt.Errorf("failed to parse header in %s: %w", mysqlName, err))
+	} else if from != mysqlFrom || to != mysqlTo || compat != mysqlCompat || message != mysqlMessage || txn != mysqlTxn {
+		panic(fmt.Errorf("mismatching header in postgres and mysql versions of %s", name))
+	}
+	fn = splitSQLUpgradeFunc(string(sqliteData), string(postgresData), string(mysqlData))
+	if mysqlData != nil {
+		checksum = sqlChecksum(postgresData, sqliteData, mysqlData)
+	} else {
+		checksum = sqlChecksum(postgresData, sqliteData)
+	}
 	return
 }
 
@@ -272,7 +327,7 @@ type fullFS interface {
 	fs.ReadDirFS
 }
 
-var splitFileNameRegex = regexp.MustCompile(`^(.+)\.(postgres|sqlite)\.sql$`)
+var splitFileNameRegex = regexp.MustCompile(`^(.+)\.(postgres|sqlite|mysql)\.sql$`)
 
 func (ut *UpgradeTable) RegisterFS(fs fullFS) {
 	ut.RegisterFSPath(fs, ".")
@@ -290,14 +345,14 @@ func (ut *UpgradeTable) RegisterFSPath(fs fullFS, dir string) {
 		} else if _, skip := skipNames[file.Name()]; skip {
 			// also do nothing
 		} else if splitName := splitFileNameRegex.FindStringSubmatch(file.Name()); splitName != nil {
-			from, to, compat, message, txn, fn := parseSplitSQLUpgrade(splitName[1], fs, skipNames)
-			ut.Register(from, to, compat, message, txn, fn)
+			from, to, compat, message, txn, fn, checksum := parseSplitSQLUpgrade(splitName[1], fs, skipNames)
+			ut.register(from, to, compat, message, txn, fn, nil).checksum = checksum
 		} else if data, err := fs.ReadFile(filepath.Join(dir, file.Name())); err != nil {
 			panic(err)
 		} else if from, to, compat, message, txn, lines, err := parseFileHeader(data); err != nil {
 			panic(fmt.Errorf("failed to parse header in %s: %w", file.Name(), err))
 		} else {
-			ut.Register(from, to, compat, message, txn, sqlUpgradeFunc(file.Name(), lines))
+			ut.register(from, to, compat, message, txn, sqlUpgradeFunc(file.Name(), lines), nil).checksum = sqlChecksum(data)
 		}
 	}
 }
@@ -37,6 +37,8 @@ func TestParseDialectFilter(t *testing.T) {
 		{"Other dialect: single line, commented", `-- only: postgres (line commented)`, Postgres, 1, true},
 		{"Other dialect: multiple lines, commented", `-- only: postgres for next 5 lines (lines commented)`, Postgres, 5, true},
 		{"Other dialect: fenced, commented", `-- only: postgres until "end only" (lines commented)`, Postgres, -1, true},
+		{"Other dialect: MySQL, single line", `-- only: mysql`, MySQL, 1, false},
+		{"Other dialect: MySQL, fenced, commented", `-- only: mysql until "end only" (lines commented)`, MySQL, -1, true},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
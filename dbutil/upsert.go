@@ -0,0 +1,259 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUpsertNoColumns        = errors.New("upsert: no columns specified")
+	ErrUpsertNoConflictTarget = errors.New("upsert: conflict target is required for updates and returning values")
+	ErrUpsertArgCount         = errors.New("upsert: number of arguments doesn't match number of columns")
+)
+
+type upsertSet struct {
+	column string
+	expr   string
+}
+
+// Upsert is a builder for `INSERT ... ON CONFLICT` queries of a single row.
+//
+// The query is rendered for the dialect of the database when it's executed. Table, column names and expressions
+// are inserted into the query as-is, so they must not come from user input. Arguments are bound positionally
+// in the same order as the columns.
+//
+// Example:
+//
+//	var upsertPortal = dbutil.NewUpsert("portal").
+//		Columns("id", "receiver", "name", "topic").
+//		ConflictOn("id", "receiver").
+//		UpdateAll()
+//
+//	func (pq *PortalQuery) Upsert(ctx context.Context, portal *Portal) error {
+//		_, err := upsertPortal.Exec(ctx, pq.GetDB(), portal.ID, portal.Receiver, portal.Name, portal.Topic)
+//		return err
+//	}
+type Upsert struct {
+	table     string
+	columns   []string
+	conflict  []string
+	set       []upsertSet
+	updateAll bool
+	returning []string
+}
+
+// NewUpsert creates a new upsert builder for the given table.
+func NewUpsert(table string) *Upsert {
+	return &Upsert{table: table}
+}
+
+// Columns adds columns to insert. The arguments passed when executing the query must be in the same order.
+func (u *Upsert) Columns(columns ...string) *Upsert {
+	u.columns = append(u.columns, columns...)
+	return u
+}
+
+// ConflictOn sets the conflict target, i.e. the columns of the unique index that may conflict.
+func (u *Upsert) ConflictOn(columns ...string) *Upsert {
+	u.conflict = columns
+	return u
+}
+
+// Update adds columns that will be overwritten with the new values (`column=excluded.column`) on conflict.
+func (u *Upsert) Update(columns ...string) *Upsert {
+	for _, col := range columns {
+		u.set = append(u.set, upsertSet{column: col, expr: "excluded." + col})
+	}
+	return u
+}
+
+// UpdateExpr sets a column to an arbitrary expression on conflict, e.g. `counter + excluded.counter`.
+//
+// Postgres requires references to the existing row to be qualified with the table name if the column name
+// is ambiguous, which also works on SQLite.
+func (u *Upsert) UpdateExpr(column, expr string) *Upsert {
+	u.set = append(u.set, upsertSet{column: column, expr: expr})
+	return u
+}
+
+// UpdateAll makes the query overwrite all inserted columns that aren't a part of the conflict target on conflict.
+// Columns that were already added with UpdateExpr keep their expression.
+func (u *Upsert) UpdateAll() *Upsert {
+	u.updateAll = true
+	return u
+}
+
+// DoNothing clears all update columns, which makes the query skip conflicting rows.
+// This is also the default if no update columns are specified.
+func (u *Upsert) DoNothing() *Upsert {
+	u.set = nil
+	u.updateAll = false
+	return u
+}
+
+// Returning sets the columns to return from the inserted or updated row. Use ExecReturning to read them.
+//
+// On Postgres, this adds a `RETURNING` clause to the query. On other dialects, ExecReturning will instead select
+// the row by the conflict target after executing the query, so a conflict target is required. In both cases,
+// rows skipped due to `DO NOTHING` won't be returned.
+func (u *Upsert) Returning(columns ...string) *Upsert {
+	u.returning = columns
+	return u
+}
+
+func (u *Upsert) updates() []upsertSet {
+	if !u.updateAll {
+		return u.set
+	}
+	set := slices.Clone(u.set)
+	for _, col := range u.columns {
+		if slices.Contains(u.conflict, col) || slices.ContainsFunc(set, func(s upsertSet) bool { return s.column == col }) {
+			continue
+		}
+		set = append(set, upsertSet{column: col, expr: "excluded." + col})
+	}
+	return set
+}
+
+func (u *Upsert) validate() error {
+	if len(u.columns) == 0 {
+		return ErrUpsertNoColumns
+	} else if len(u.conflict) == 0 && (len(u.updates()) > 0 || len(u.returning) > 0) {
+		return ErrUpsertNoConflictTarget
+	}
+	return nil
+}
+
+// Build renders the query for the given dialect.
+//
+// MySQL doesn't support `ON CONFLICT` clauses, so ErrUnsupportedDialect is returned for it.
+func (u *Upsert) Build(dialect Dialect) (string, error) {
+	if err := u.validate(); err != nil {
+		return "", err
+	} else if dialect == MySQL {
+		return "", fmt.Errorf("%w: MySQL doesn't support ON CONFLICT clauses", ErrUnsupportedDialect)
+	}
+	var query strings.Builder
+	query.WriteString("INSERT INTO ")
+	query.WriteString(u.table)
+	query.WriteString(" (")
+	query.WriteString(strings.Join(u.columns, ", "))
+	query.WriteString(") VALUES (")
+	for i := range u.columns {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteByte('$')
+		query.WriteString(strconv.Itoa(i + 1))
+	}
+	query.WriteString(") ON CONFLICT")
+	if len(u.conflict) > 0 {
+		query.WriteString(" (")
+		query.WriteString(strings.Join(u.conflict, ", "))
+		query.WriteByte(')')
+	}
+	if set := u.updates(); len(set) == 0 {
+		query.WriteString(" DO NOTHING")
+	} else {
+		query.WriteString(" DO UPDATE SET ")
+		for i, s := range set {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString(s.column)
+			query.WriteByte('=')
+			query.WriteString(s.expr)
+		}
+	}
+	if len(u.returning) > 0 && dialect == Postgres {
+		query.WriteString(" RETURNING ")
+		query.WriteString(strings.Join(u.returning, ", "))
+	}
+	return query.String(), nil
+}
+
+func (u *Upsert) buildFallbackSelect(args []any) (string, []any, error) {
+	selectArgs := make([]any, len(u.conflict))
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	query.WriteString(strings.Join(u.returning, ", "))
+	query.WriteString(" FROM ")
+	query.WriteString(u.table)
+	query.WriteString(" WHERE ")
+	for i, col := range u.conflict {
+		idx := slices.Index(u.columns, col)
+		if idx < 0 {
+			return "", nil, fmt.Errorf("upsert: conflict column %s is not in the inserted columns", col)
+		}
+		selectArgs[i] = args[idx]
+		if i > 0 {
+			query.WriteString(" AND ")
+		}
+		query.WriteString(col)
+		query.WriteString("=$")
+		query.WriteString(strconv.Itoa(i + 1))
+	}
+	return query.String(), selectArgs, nil
+}
+
+// Exec executes the query with the given arguments, which must match the columns.
+func (u *Upsert) Exec(ctx context.Context, db *Database, args ...any) (sql.Result, error) {
+	if len(args) != len(u.columns) {
+		return nil, fmt.Errorf("%w (expected %d, got %d)", ErrUpsertArgCount, len(u.columns), len(args))
+	}
+	query, err := u.Build(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	return db.Exec(ctx, query, args...)
+}
+
+// ExecReturning executes the query with the given arguments and scans the columns specified with Returning into dest.
+//
+// If the row was skipped due to a conflict, sql.ErrNoRows is returned. See Returning for how this works on dialects
+// other than Postgres.
+func (u *Upsert) ExecReturning(ctx context.Context, db *Database, args []any, dest ...any) error {
+	if len(args) != len(u.columns) {
+		return fmt.Errorf("%w (expected %d, got %d)", ErrUpsertArgCount, len(u.columns), len(args))
+	}
+	query, err := u.Build(db.Dialect)
+	if err != nil {
+		return err
+	} else if db.Dialect == Postgres {
+		return db.QueryRow(ctx, query, args...).Scan(dest...)
+	}
+	selectQuery, selectArgs, err := u.buildFallbackSelect(args)
+	if err != nil {
+		return err
+	}
+	var skipped bool
+	err = db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		res, err := db.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		} else if affected == 0 {
+			skipped = true
+			return nil
+		}
+		return db.QueryRow(ctx, selectQuery, selectArgs...).Scan(dest...)
+	})
+	if err == nil && skipped {
+		err = sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/dbutil"
+)
+
+func TestUpsert_Build(t *testing.T) {
+	testCases := []struct {
+		name     string
+		upsert   *dbutil.Upsert
+		postgres string
+		sqlite   string
+	}{{
+		name:     "UpdateAll",
+		upsert:   dbutil.NewUpsert("portal").Columns("id", "receiver", "name", "topic").ConflictOn("id", "receiver").UpdateAll(),
+		postgres: "INSERT INTO portal (id, receiver, name, topic) VALUES ($1, $2, $3, $4) ON CONFLICT (id, receiver) DO UPDATE SET name=excluded.name, topic=excluded.topic",
+	}, {
+		name:     "PartialUpdate",
+		upsert:   dbutil.NewUpsert("portal").Columns("id", "name", "topic").ConflictOn("id").Update("name"),
+		postgres: "INSERT INTO portal (id, name, topic) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET name=excluded.name",
+	}, {
+		name:     "UpdateExpr",
+		upsert:   dbutil.NewUpsert("counter").Columns("key", "value", "updated_at").ConflictOn("key").UpdateExpr("value", "counter.value + excluded.value").UpdateAll(),
+		postgres: "INSERT INTO counter (key, value, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO UPDATE SET value=counter.value + excluded.value, updated_at=excluded.updated_at",
+	}, {
+		name:     "DoNothing",
+		upsert:   dbutil.NewUpsert("portal").Columns("id", "name").ConflictOn("id").Update("name").DoNothing(),
+		postgres: "INSERT INTO portal (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING",
+	}, {
+		name:     "DoNothingWithoutTarget",
+		upsert:   dbutil.NewUpsert("portal").Columns("id", "name"),
+		postgres: "INSERT INTO portal (id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+	}, {
+		name:     "UpdateAllOnlyConflictColumns",
+		upsert:   dbutil.NewUpsert("member").Columns("room_id", "user_id").ConflictOn("room_id", "user_id").UpdateAll(),
+		postgres: "INSERT INTO member (room_id, user_id) VALUES ($1, $2) ON CONFLICT (room_id, user_id) DO NOTHING",
+	}, {
+		name:     "Returning",
+		upsert:   dbutil.NewUpsert("portal").Columns("mxid", "name").ConflictOn("mxid").UpdateAll().Returning("id", "name"),
+		postgres: "INSERT INTO portal (mxid, name) VALUES ($1, $2) ON CONFLICT (mxid) DO UPDATE SET name=excluded.name RETURNING id, name",
+		sqlite:   "INSERT INTO portal (mxid, name) VALUES ($1, $2) ON CONFLICT (mxid) DO UPDATE SET name=excluded.name",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.upsert.Build(dbutil.Postgres)
+			require.NoError(t, err)
+			assert.Equal(t, tc.postgres, query)
+			if tc.sqlite == "" {
+				tc.sqlite = tc.postgres
+			}
+			query, err = tc.upsert.Build(dbutil.SQLite)
+			require.NoError(t, err)
+			assert.Equal(t, tc.sqlite, query)
+		})
+	}
+}
+
+func TestUpsert_Build_Invalid(t *testing.T) {
+	_, err := dbutil.NewUpsert("portal").Build(dbutil.Postgres)
+	assert.ErrorIs(t, err, dbutil.ErrUpsertNoColumns)
+	_, err = dbutil.NewUpsert("portal").Columns("id", "name").UpdateAll().Build(dbutil.Postgres)
+	assert.ErrorIs(t, err, dbutil.ErrUpsertNoConflictTarget)
+	_, err = dbutil.NewUpsert("portal").Columns("id", "name").Returning("id").Build(dbutil.SQLite)
+	assert.ErrorIs(t, err, dbutil.ErrUpsertNoConflictTarget)
+}
+
+func TestUpsert_SQLite(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, "CREATE TABLE counter (id INTEGER PRIMARY KEY, key TEXT NOT NULL UNIQUE, value INTEGER NOT NULL, note TEXT)")
+	require.NoError(t, err)
+	getCounter := func(key string) (value int, note string) {
+		err := db.QueryRow(ctx, "SELECT value, note FROM counter WHERE key=$1", key).Scan(&value, &note)
+		require.NoError(t, err)
+		return
+	}
+
+	increment := dbutil.NewUpsert("counter").
+		Columns("key", "value", "note").
+		ConflictOn("key").
+		UpdateExpr("value", "counter.value + excluded.value").
+		UpdateAll()
+	_, err = increment.Exec(ctx, db, "a", 1, "first")
+	require.NoError(t, err)
+	_, err = increment.Exec(ctx, db, "a", 2, "second")
+	require.NoError(t, err)
+	value, note := getCounter("a")
+	assert.Equal(t, 3, value)
+	assert.Equal(t, "second", note)
+
+	insertOnly := dbutil.NewUpsert("counter").Columns("key", "value", "note").ConflictOn("key")
+	res, err := insertOnly.Exec(ctx, db, "a", 100, "ignored")
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+	value, note = getCounter("a")
+	assert.Equal(t, 3, value)
+	assert.Equal(t, "second", note)
+
+	_, err = increment.Exec(ctx, db, "a", 1)
+	assert.ErrorIs(t, err, dbutil.ErrUpsertArgCount)
+}
+
+func TestUpsert_ExecReturning_SQLite(t *testing.T) {
+	db := newTestSQLite(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, "CREATE TABLE portal (id INTEGER PRIMARY KEY, mxid TEXT NOT NULL UNIQUE, name TEXT)")
+	require.NoError(t, err)
+
+	upsert := dbutil.NewUpsert("portal").Columns("mxid", "name").ConflictOn("mxid").UpdateAll().Returning("id", "name")
+	var id1, id2 int
+	var name string
+	require.NoError(t, upsert.ExecReturning(ctx, db, []any{"!a", "Room A"}, &id1, &name))
+	assert.Equal(t, "Room A", name)
+	require.NoError(t, upsert.ExecReturning(ctx, db, []any{"!b", "Room B"}, &id2, &name))
+	assert.NotEqual(t, id1, id2)
+	var updatedID int
+	require.NoError(t, upsert.ExecReturning(ctx, db, []any{"!a", "Renamed"}, &updatedID, &name))
+	assert.Equal(t, id1, updatedID)
+	assert.Equal(t, "Renamed", name)
+
+	insertOnly := dbutil.NewUpsert("portal").Columns("mxid", "name").ConflictOn("mxid").Returning("id")
+	err = insertOnly.ExecReturning(ctx, db, []any{"!a", "Ignored"}, &updatedID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exerrors
+
+import (
+	"strings"
+)
+
+// MultiError is a collection of errors, e.g. from validating all fields of a struct
+// or processing a batch of items without stopping at the first error.
+//
+// The zero value is an empty collection. MultiError is not safe for concurrent use.
+//
+// errors.Is and errors.As check all the collected errors.
+type MultiError struct {
+	errs []error
+}
+
+// Join returns an error that wraps the given errors, like errors.Join, but the returned error is a *MultiError.
+// Nil errors are discarded. If all errors are nil, Join returns nil.
+func Join(errs ...error) error {
+	var me MultiError
+	for _, err := range errs {
+		me.Append(err)
+	}
+	return me.ErrorOrNil()
+}
+
+// Append adds the given error to the collection. Nil errors are ignored.
+func (me *MultiError) Append(err error) {
+	if err != nil {
+		me.errs = append(me.errs, err)
+	}
+}
+
+// AppendIf adds the given error to the collection if the condition is true.
+func (me *MultiError) AppendIf(cond bool, err error) {
+	if cond {
+		me.Append(err)
+	}
+}
+
+// Len returns the number of collected errors.
+func (me *MultiError) Len() int {
+	if me == nil {
+		return 0
+	}
+	return len(me.errs)
+}
+
+// Errors returns the collected errors.
+func (me *MultiError) Errors() []error {
+	if me == nil {
+		return nil
+	}
+	return me.errs
+}
+
+// ErrorOrNil returns the MultiError itself if it contains any errors and nil otherwise.
+//
+// This should be used when returning a MultiError as an error,
+// as a nil *MultiError would make the error interface non-nil.
+func (me *MultiError) ErrorOrNil() error {
+	if me.Len() == 0 {
+		return nil
+	}
+	return me
+}
+
+// Unwrap returns the collected errors, which lets errors.Is and errors.As check them.
+func (me *MultiError) Unwrap() []error {
+	return me.Errors()
+}
+
+// Error returns the messages of all collected errors separated by newlines.
+func (me *MultiError) Error() string {
+	if me.Len() == 1 {
+		return me.errs[0].Error()
+	}
+	var buf strings.Builder
+	for i, err := range me.Errors() {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
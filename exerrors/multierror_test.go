@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exerrors_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/exerrors"
+)
+
+var (
+	errFoo = errors.New("foo")
+	errBar = errors.New("bar")
+)
+
+func TestMultiError(t *testing.T) {
+	var me exerrors.MultiError
+	assert.NoError(t, me.ErrorOrNil())
+	assert.Equal(t, 0, me.Len())
+	me.Append(nil)
+	me.AppendIf(false, errFoo)
+	assert.NoError(t, me.ErrorOrNil())
+
+	me.Append(errFoo)
+	me.AppendIf(true, &fs.PathError{Op: "open", Path: "/tmp/x", Err: fs.ErrNotExist})
+	err := me.ErrorOrNil()
+	require.Error(t, err)
+	assert.Equal(t, 2, me.Len())
+	assert.Equal(t, "foo\nopen /tmp/x: file does not exist", err.Error())
+	assert.ErrorIs(t, err, errFoo)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.NotErrorIs(t, err, errBar)
+	var pathErr *fs.PathError
+	require.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, "/tmp/x", pathErr.Path)
+}
+
+func TestMultiError_Nil(t *testing.T) {
+	var me *exerrors.MultiError
+	assert.Equal(t, 0, me.Len())
+	assert.Nil(t, me.Errors())
+	assert.NoError(t, me.ErrorOrNil())
+}
+
+func TestJoin(t *testing.T) {
+	assert.NoError(t, exerrors.Join())
+	assert.NoError(t, exerrors.Join(nil, nil))
+
+	err := exerrors.Join(errFoo, nil, errBar)
+	var me *exerrors.MultiError
+	require.ErrorAs(t, err, &me)
+	assert.Equal(t, []error{errFoo, errBar}, me.Errors())
+	assert.Equal(t, errors.Join(errFoo, errBar).Error(), err.Error())
+	assert.Equal(t, "foo", exerrors.Join(errFoo).Error())
+}
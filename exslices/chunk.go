@@ -6,7 +6,15 @@
 
 package exslices
 
-// Chunk splits a slice into chunks of the given size.
+import (
+	"iter"
+)
+
+// Chunk splits a slice into chunks of the given size. The last chunk may be smaller than the given size.
+//
+// The chunks share the backing array with the input slice, so modifying items in a chunk will modify the input
+// slice too. The capacity of each chunk is limited to its length, so appending to a chunk will not overwrite
+// items in the next chunk.
 //
 // From https://github.com/golang/go/issues/53987#issuecomment-1224367139
 //
@@ -26,3 +34,41 @@ func Chunk[T any](slice []T, size int) (chunks [][]T) {
 		}
 	}
 }
+
+// ChunkIter is like Chunk, but returns an iterator instead of collecting all the chunks into a slice.
+// Like with Chunk, the chunks share the backing array with the input slice.
+//
+// Unlike Chunk, an empty input slice doesn't produce any chunks.
+func ChunkIter[T any](slice []T, size int) iter.Seq[[]T] {
+	if size < 1 {
+		panic("chunk size cannot be less than 1")
+	}
+	return func(yield func([]T) bool) {
+		for i := 0; i < len(slice); i += size {
+			end := min(i+size, len(slice))
+			if !yield(slice[i:end:end]) {
+				return
+			}
+		}
+	}
+}
+
+// Windowed returns overlapping windows of the given size, starting every step items. Items at the end of the slice
+// that don't fill a whole window are not included. If the slice is shorter than the window size, the output is nil.
+//
+// For example, Windowed([]int{1, 2, 3, 4, 5}, 3, 1) returns [[1 2 3] [2 3 4] [3 4 5]].
+//
+// The windows share the backing array with the input slice (and each other), so modifying items in a window
+// will modify the input slice and other windows too. The capacity of each window is limited to its length,
+// so appending to a window will not overwrite other items.
+func Windowed[T any](slice []T, size, step int) (windows [][]T) {
+	if size < 1 {
+		panic("window size cannot be less than 1")
+	} else if step < 1 {
+		panic("window step cannot be less than 1")
+	}
+	for i := 0; i+size <= len(slice); i += step {
+		windows = append(windows, slice[i:i+size:i+size])
+	}
+	return
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exslices"
+)
+
+func TestChunk(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7}
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, exslices.Chunk(input, 3))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5, 6, 7}}, exslices.Chunk(input, 10))
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5, 6}, {7}}, exslices.Chunk(input, 2))
+	assert.PanicsWithValue(t, "chunk size cannot be less than 1", func() {
+		exslices.Chunk(input, 0)
+	})
+}
+
+func TestChunk_SharesBackingArray(t *testing.T) {
+	input := []int{1, 2, 3, 4}
+	chunks := exslices.Chunk(input, 2)
+	chunks[1][0] = 100
+	assert.Equal(t, []int{1, 2, 100, 4}, input)
+	_ = append(chunks[0], 5)
+	assert.Equal(t, []int{1, 2, 100, 4}, input)
+}
+
+func TestChunkIter(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 7}
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, slices.Collect(exslices.ChunkIter(input, 3)))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5, 6, 7}}, slices.Collect(exslices.ChunkIter(input, 7)))
+	assert.Nil(t, slices.Collect(exslices.ChunkIter([]int{}, 3)))
+	for chunk := range exslices.ChunkIter(input, 2) {
+		assert.Equal(t, []int{1, 2}, chunk)
+		break
+	}
+	assert.PanicsWithValue(t, "chunk size cannot be less than 1", func() {
+		exslices.ChunkIter(input, -1)
+	})
+}
+
+func TestWindowed(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5}
+	assert.Equal(t, [][]int{{1, 2, 3}, {2, 3, 4}, {3, 4, 5}}, exslices.Windowed(input, 3, 1))
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}}, exslices.Windowed(input, 2, 2))
+	assert.Equal(t, [][]int{{1}, {4}}, exslices.Windowed(input, 1, 3))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, exslices.Windowed(input, 5, 1))
+	assert.Nil(t, exslices.Windowed(input, 6, 1))
+	assert.Nil(t, exslices.Windowed([]int(nil), 1, 1))
+	assert.PanicsWithValue(t, "window size cannot be less than 1", func() {
+		exslices.Windowed(input, 0, 1)
+	})
+	assert.PanicsWithValue(t, "window step cannot be less than 1", func() {
+		exslices.Windowed(input, 1, 0)
+	})
+
+	windows := exslices.Windowed(input, 2, 1)
+	windows[0][1] = 100
+	assert.Equal(t, 100, windows[1][0])
+	assert.Equal(t, 100, input[1])
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices
+
+// Filter returns a new slice containing the items of the given slice for which the predicate returns true.
+// The input slice is not modified. If no items match, the output is nil.
+func Filter[T any](s []T, pred func(T) bool) []T {
+	var out []T
+	for _, item := range s {
+		if pred(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Map returns a new slice containing the result of calling the given function for each item in the given slice.
+// A nil input produces a nil output.
+func Map[T, U any](s []T, fn func(T) U) []U {
+	if s == nil {
+		return nil
+	}
+	out := make([]U, len(s))
+	for i, item := range s {
+		out[i] = fn(item)
+	}
+	return out
+}
+
+// Reduce combines all items in the given slice into one value by calling the given function with the accumulator
+// and each item in order. The initial value of the accumulator is init, which is also returned for empty slices.
+func Reduce[T, U any](s []T, init U, fn func(U, T) U) U {
+	acc := init
+	for _, item := range s {
+		acc = fn(acc, item)
+	}
+	return acc
+}
+
+// FlatMap calls the given function for each item in the given slice and returns all the output slices concatenated.
+func FlatMap[T, U any](s []T, fn func(T) []U) []U {
+	var out []U
+	for _, item := range s {
+		out = append(out, fn(item)...)
+	}
+	return out
+}
+
+// Any returns true if the predicate returns true for at least one item in the given slice.
+// It stops calling the predicate after the first match and returns false for empty slices.
+func Any[T any](s []T, pred func(T) bool) bool {
+	for _, item := range s {
+		if pred(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns true if the predicate returns true for every item in the given slice.
+// It stops calling the predicate after the first mismatch and returns true for empty slices.
+func All[T any](s []T, pred func(T) bool) bool {
+	for _, item := range s {
+		if !pred(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the first item in the given slice for which the predicate returns true.
+// If there's no such item, the zero value and false are returned.
+func Find[T any](s []T, pred func(T) bool) (T, bool) {
+	for _, item := range s {
+		if pred(item) {
+			return item, true
+		}
+	}
+	var zero T
+	return zero, false
+}
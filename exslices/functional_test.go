@@ -0,0 +1,131 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exslices"
+)
+
+func isEven(i int) bool {
+	return i%2 == 0
+}
+
+func TestFilter(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6}
+	assert.Equal(t, []int{2, 4, 6}, exslices.Filter(input, isEven))
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, input)
+	assert.Nil(t, exslices.Filter([]int{1, 3}, isEven))
+	assert.Nil(t, exslices.Filter(nil, isEven))
+}
+
+func TestFilter_DoesNotAlias(t *testing.T) {
+	input := []int{2, 4}
+	output := exslices.Filter(input, isEven)
+	output[0] = 100
+	assert.Equal(t, []int{2, 4}, input)
+}
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, []string{"1", "2", "3"}, exslices.Map([]int{1, 2, 3}, strconv.Itoa))
+	assert.Nil(t, exslices.Map(nil, strconv.Itoa))
+	assert.Equal(t, []string{}, exslices.Map([]int{}, strconv.Itoa))
+}
+
+func TestReduce(t *testing.T) {
+	sum := func(acc, item int) int { return acc + item }
+	assert.Equal(t, 15, exslices.Reduce([]int{1, 2, 3, 4, 5}, 0, sum))
+	assert.Equal(t, 10, exslices.Reduce(nil, 10, sum))
+	assert.Equal(t, "abc", exslices.Reduce([]rune("abc"), "", func(acc string, item rune) string {
+		return acc + string(item)
+	}))
+}
+
+func TestFlatMap(t *testing.T) {
+	repeat := func(i int) []int {
+		out := make([]int, i)
+		for j := range out {
+			out[j] = i
+		}
+		return out
+	}
+	assert.Equal(t, []int{1, 2, 2, 3, 3, 3}, exslices.FlatMap([]int{1, 0, 2, 3}, repeat))
+	assert.Nil(t, exslices.FlatMap(nil, repeat))
+}
+
+func TestAnyAll(t *testing.T) {
+	assert.True(t, exslices.Any([]int{1, 2, 3}, isEven))
+	assert.False(t, exslices.Any([]int{1, 3}, isEven))
+	assert.False(t, exslices.Any(nil, isEven))
+	assert.True(t, exslices.All([]int{2, 4}, isEven))
+	assert.False(t, exslices.All([]int{2, 3}, isEven))
+	assert.True(t, exslices.All(nil, isEven))
+
+	calls := 0
+	exslices.Any([]int{2, 4, 6}, func(i int) bool {
+		calls++
+		return isEven(i)
+	})
+	assert.Equal(t, 1, calls)
+}
+
+func TestFind(t *testing.T) {
+	val, ok := exslices.Find([]int{1, 3, 4, 6}, isEven)
+	assert.True(t, ok)
+	assert.Equal(t, 4, val)
+	val, ok = exslices.Find([]int{1, 3}, isEven)
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+}
+
+var benchmarkInts = func() []int {
+	out := make([]int, 1000)
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}()
+
+func BenchmarkFilter(b *testing.B) {
+	b.Run("Manual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var out []int
+			for _, item := range benchmarkInts {
+				if item%2 == 0 {
+					out = append(out, item)
+				}
+			}
+		}
+	})
+	b.Run("Filter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			exslices.Filter(benchmarkInts, func(item int) bool { return item%2 == 0 })
+		}
+	})
+}
+
+func BenchmarkReduce(b *testing.B) {
+	var total int
+	b.Run("Manual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sum := 0
+			for _, item := range benchmarkInts {
+				sum += item
+			}
+			total += sum
+		}
+	})
+	b.Run("Reduce", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			total += exslices.Reduce(benchmarkInts, 0, func(acc, item int) int { return acc + item })
+		}
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices
+
+// GroupBy groups the items in the given slice by the key returned by the given function.
+// The items in each group are in the same order as in the input slice.
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for _, item := range s {
+		k := key(item)
+		groups[k] = append(groups[k], item)
+	}
+	return groups
+}
+
+// Partition splits the given slice into two new slices: one with the items for which the predicate returns true
+// and one with the rest. The order of items is preserved in both slices.
+func Partition[T any](s []T, pred func(T) bool) (matching, rest []T) {
+	for _, item := range s {
+		if pred(item) {
+			matching = append(matching, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return
+}
+
+// PartitionInPlace rearranges the given slice so that the items for which the predicate returns true are first,
+// followed by the rest, and returns the index of the first non-matching item. This means s[:i] contains the matching
+// items and s[i:] the rest.
+//
+// The partition is stable, i.e. the order of items is preserved within both parts. The predicate is called exactly
+// once for each item. Non-matching items are temporarily copied to a separate buffer to keep the partition stable.
+func PartitionInPlace[T any](s []T, pred func(T) bool) int {
+	var rest []T
+	n := 0
+	for _, item := range s {
+		if pred(item) {
+			s[n] = item
+			n++
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	copy(s[n:], rest)
+	return n
+}
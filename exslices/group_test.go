@@ -0,0 +1,56 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exslices"
+)
+
+func TestGroupBy(t *testing.T) {
+	groups := exslices.GroupBy([]string{"apple", "bee", "avocado", "cat", "banana"}, func(s string) byte {
+		return s[0]
+	})
+	assert.Equal(t, map[byte][]string{
+		'a': {"apple", "avocado"},
+		'b': {"bee", "banana"},
+		'c': {"cat"},
+	}, groups)
+	assert.Empty(t, exslices.GroupBy(nil, func(s string) int { return len(s) }))
+}
+
+func TestPartition(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6}
+	matching, rest := exslices.Partition(input, isEven)
+	assert.Equal(t, []int{2, 4, 6}, matching)
+	assert.Equal(t, []int{1, 3, 5}, rest)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, input)
+
+	matching, rest = exslices.Partition([]int{2, 4}, isEven)
+	assert.Equal(t, []int{2, 4}, matching)
+	assert.Nil(t, rest)
+}
+
+func TestPartitionInPlace(t *testing.T) {
+	input := []int{1, 2, 3, 4, 5, 6, 8}
+	calls := 0
+	split := exslices.PartitionInPlace(input, func(i int) bool {
+		calls++
+		return isEven(i)
+	})
+	assert.Equal(t, 4, split)
+	assert.Equal(t, []int{2, 4, 6, 8, 1, 3, 5}, input)
+	assert.Equal(t, 7, calls)
+
+	assert.Equal(t, 0, exslices.PartitionInPlace(nil, isEven))
+	input = []int{1, 3}
+	assert.Equal(t, 0, exslices.PartitionInPlace(input, isEven))
+	assert.Equal(t, []int{1, 3}, input)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices
+
+// Unique returns a new slice with duplicate items removed. The first occurrence of each item is kept,
+// so the output is in the same order as the input. A nil input produces a nil output.
+func Unique[T comparable](s []T) []T {
+	return UniqueBy(s, func(item T) T {
+		return item
+	})
+}
+
+// UniqueBy is like Unique, but uses the given function to get the key used to detect duplicates.
+// This can be used for types that aren't comparable or when only some fields should be compared.
+func UniqueBy[T any, K comparable](s []T, key func(T) K) []T {
+	if s == nil {
+		return nil
+	}
+	seen := make(map[K]struct{}, len(s))
+	out := make([]T, 0, len(s))
+	for _, item := range s {
+		k := key(item)
+		if _, alreadySeen := seen[k]; !alreadySeen {
+			seen[k] = struct{}{}
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// UniqueInPlace removes duplicate items from the given slice by moving unique items to the start of the slice
+// and returns the shortened slice. The first occurrence of each item is kept.
+//
+// Unlike Unique, this doesn't allocate a new slice, but the contents of the input slice are overwritten,
+// so it should only be used when the original slice is no longer needed. A nil input produces a nil output.
+func UniqueInPlace[T comparable](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	seen := make(map[T]struct{}, len(s))
+	n := 0
+	for _, item := range s {
+		if _, alreadySeen := seen[item]; !alreadySeen {
+			seen[item] = struct{}{}
+			s[n] = item
+			n++
+		}
+	}
+	clear(s[n:])
+	return s[:n]
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exslices"
+)
+
+func TestUnique(t *testing.T) {
+	input := []int{3, 1, 3, 2, 1, 4}
+	assert.Equal(t, []int{3, 1, 2, 4}, exslices.Unique(input))
+	assert.Equal(t, []int{3, 1, 3, 2, 1, 4}, input)
+	assert.Nil(t, exslices.Unique[int](nil))
+	assert.Equal(t, []int{}, exslices.Unique([]int{}))
+}
+
+func TestUniqueBy(t *testing.T) {
+	input := [][]string{{"a", "b"}, {"A", "B"}, {"c"}, {"a", "c"}}
+	output := exslices.UniqueBy(input, func(item []string) string {
+		return strings.ToLower(item[0])
+	})
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, output)
+	assert.Nil(t, exslices.UniqueBy(nil, func(item []string) int { return len(item) }))
+}
+
+func TestUniqueInPlace(t *testing.T) {
+	input := []string{"b", "a", "b", "c", "a"}
+	output := exslices.UniqueInPlace(input)
+	assert.Equal(t, []string{"b", "a", "c"}, output)
+	assert.Same(t, &input[0], &output[0], "output should reuse the input array")
+	assert.Equal(t, []string{"b", "a", "c", "", ""}, input)
+	assert.Nil(t, exslices.UniqueInPlace[string](nil))
+	assert.Equal(t, []string{"x"}, exslices.UniqueInPlace([]string{"x", "x", "x"}))
+}
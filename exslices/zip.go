@@ -0,0 +1,69 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Pair is a pair of two values of possibly different types.
+//
+// Pairs are marshaled to JSON as two-element arrays, e.g. `["a", 1]`.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// MarshalJSON marshals the pair as a two-element array.
+func (p Pair[A, B]) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]any{p.First, p.Second})
+}
+
+// UnmarshalJSON unmarshals a two-element array into the pair.
+func (p *Pair[A, B]) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	} else if len(raw) != 2 {
+		return fmt.Errorf("expected array with 2 elements for pair, got %d", len(raw))
+	} else if err = json.Unmarshal(raw[0], &p.First); err != nil {
+		return fmt.Errorf("failed to unmarshal first element of pair: %w", err)
+	} else if err = json.Unmarshal(raw[1], &p.Second); err != nil {
+		return fmt.Errorf("failed to unmarshal second element of pair: %w", err)
+	}
+	return nil
+}
+
+// Zip combines two slices into a slice of pairs. If the slices have different lengths,
+// the extra items in the longer slice are ignored.
+func Zip[A, B any](a []A, b []B) []Pair[A, B] {
+	return ZipWith(a, b, func(first A, second B) Pair[A, B] {
+		return Pair[A, B]{First: first, Second: second}
+	})
+}
+
+// ZipWith calls the given function with the items at each index of the two slices and returns the results.
+// If the slices have different lengths, the extra items in the longer slice are ignored.
+func ZipWith[A, B, C any](a []A, b []B, fn func(A, B) C) []C {
+	out := make([]C, min(len(a), len(b)))
+	for i := range out {
+		out[i] = fn(a[i], b[i])
+	}
+	return out
+}
+
+// Unzip splits a slice of pairs into two slices. It is the inverse of Zip.
+func Unzip[A, B any](pairs []Pair[A, B]) ([]A, []B) {
+	a := make([]A, len(pairs))
+	b := make([]B, len(pairs))
+	for i, pair := range pairs {
+		a[i] = pair.First
+		b[i] = pair.Second
+	}
+	return a, b
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exslices_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/exslices"
+)
+
+func TestZip(t *testing.T) {
+	pairs := exslices.Zip([]string{"a", "b", "c"}, []int{1, 2})
+	assert.Equal(t, []exslices.Pair[string, int]{{"a", 1}, {"b", 2}}, pairs)
+	assert.Empty(t, exslices.Zip([]string{"a"}, []int(nil)))
+
+	a, b := exslices.Unzip(pairs)
+	assert.Equal(t, []string{"a", "b"}, a)
+	assert.Equal(t, []int{1, 2}, b)
+	a, b = exslices.Unzip[string, int](nil)
+	assert.Empty(t, a)
+	assert.Empty(t, b)
+}
+
+func TestZipWith(t *testing.T) {
+	sum := exslices.ZipWith([]int{1, 2, 3}, []float64{0.5, 0.25, 0.125, 1}, func(a int, b float64) float64 {
+		return float64(a) + b
+	})
+	assert.Equal(t, []float64{1.5, 2.25, 3.125}, sum)
+}
+
+func TestPair_JSON(t *testing.T) {
+	data, err := json.Marshal(exslices.Zip([]string{"a", "b"}, []int{1, 2}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[["a", 1], ["b", 2]]`, string(data))
+
+	var pairs []exslices.Pair[string, int]
+	require.NoError(t, json.Unmarshal(data, &pairs))
+	assert.Equal(t, []exslices.Pair[string, int]{{"a", 1}, {"b", 2}}, pairs)
+
+	var pair exslices.Pair[string, int]
+	assert.Error(t, json.Unmarshal([]byte(`["a"]`), &pair))
+	assert.Error(t, json.Unmarshal([]byte(`["a", "b"]`), &pair))
+	assert.Error(t, json.Unmarshal([]byte(`{"First": "a"}`), &pair))
+}
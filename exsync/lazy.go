@@ -0,0 +1,46 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync
+
+import "sync"
+
+// Lazy is a value that is initialized when it's first accessed.
+//
+// The zero value is usable, but the init function must be set with SetInit before the first call to Value.
+type Lazy[T any] struct {
+	once  sync.Once
+	init  func() T
+	value T
+}
+
+// NewLazy creates a new Lazy that will call the given function to initialize the value.
+func NewLazy[T any](init func() T) *Lazy[T] {
+	return &Lazy[T]{init: init}
+}
+
+// SetInit sets the function that is used to initialize the value.
+//
+// This must be called before the first call to Value, and it's not safe to call concurrently with Value.
+// If the value has already been initialized, the new function is never called.
+func (lazy *Lazy[T]) SetInit(init func() T) {
+	lazy.init = init
+}
+
+// Value returns the value, calling the init function if this is the first call.
+//
+// The init function is called exactly once even if Value is called concurrently,
+// and all calls will block until it returns.
+func (lazy *Lazy[T]) Value() T {
+	lazy.once.Do(func() {
+		if lazy.init == nil {
+			panic("exsync: Lazy.Value called without an init function")
+		}
+		lazy.value = lazy.init()
+		lazy.init = nil
+	})
+	return lazy.value
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exsync"
+)
+
+func TestLazy_Concurrent(t *testing.T) {
+	var calls atomic.Int32
+	lazy := exsync.NewLazy(func() int {
+		calls.Add(1)
+		return 42
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 42, lazy.Value())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestLazy_ZeroValue(t *testing.T) {
+	var lazy exsync.Lazy[string]
+	lazy.SetInit(func() string {
+		return "hello"
+	})
+	assert.Equal(t, "hello", lazy.Value())
+	lazy.SetInit(func() string {
+		return "world"
+	})
+	assert.Equal(t, "hello", lazy.Value())
+}
+
+func TestLazy_NoInit(t *testing.T) {
+	var lazy exsync.Lazy[int]
+	assert.Panics(t, func() {
+		lazy.Value()
+	})
+}
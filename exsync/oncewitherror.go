@@ -0,0 +1,42 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OnceWithError is a wrapper for sync.Once that stores the error returned by the function.
+//
+// The zero value is ready to use. Like sync.Once, it must not be copied after first use.
+type OnceWithError struct {
+	once sync.Once
+	done atomic.Bool
+	err  error
+}
+
+// Do calls the given function if this is the first call to Do and returns the error it returned.
+// Subsequent calls don't call the function, but return the stored error.
+//
+// If the function panics, Do considers it returned and the stored error will be nil.
+func (o *OnceWithError) Do(fn func() error) error {
+	o.once.Do(func() {
+		defer o.done.Store(true)
+		o.err = fn()
+	})
+	return o.err
+}
+
+// Err returns the error stored by Do without calling any function.
+// It returns nil if Do hasn't been called yet or if the function is still running.
+func (o *OnceWithError) Err() error {
+	if !o.done.Load() {
+		return nil
+	}
+	return o.err
+}
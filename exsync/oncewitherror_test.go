@@ -0,0 +1,52 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exsync"
+)
+
+func TestOnceWithError(t *testing.T) {
+	var once exsync.OnceWithError
+	assert.NoError(t, once.Err())
+	errTest := errors.New("test")
+	var calls atomic.Int32
+	fn := func() error {
+		calls.Add(1)
+		return errTest
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.ErrorIs(t, once.Do(fn), errTest)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	assert.ErrorIs(t, once.Err(), errTest)
+	assert.ErrorIs(t, once.Do(func() error { return nil }), errTest)
+}
+
+func TestOnceWithError_Panic(t *testing.T) {
+	var once exsync.OnceWithError
+	assert.Panics(t, func() {
+		_ = once.Do(func() error {
+			panic("test")
+		})
+	})
+	assert.NoError(t, once.Do(func() error { return errors.New("not called") }))
+	assert.NoError(t, once.Err())
+}
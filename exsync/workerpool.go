@@ -0,0 +1,151 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync
+
+import (
+	"runtime"
+	"sync"
+)
+
+// WorkerPool processes jobs concurrently using a fixed number of worker goroutines.
+//
+// Results are sent to the channel returned by Results in the order they're finished. The pool buffers results
+// internally, so workers never block on sending results, but unread results are kept in memory until they're read.
+// If the processing function can fail, use result.Result[R, error] as the result type.
+//
+// Example:
+//
+//	pool := exsync.NewWorkerPool(8, func(url string) result.Result[*http.Response, error] {
+//		return result.From(http.Get(url))
+//	})
+//	go func() {
+//		pool.SubmitAll(urls)
+//		pool.Close()
+//	}()
+//	for res := range pool.Results() {
+//		...
+//	}
+type WorkerPool[J, R any] struct {
+	fn      func(J) R
+	jobs    chan J
+	results chan R
+
+	submitLock sync.RWMutex
+	closed     bool
+	closeOnce  sync.Once
+	pending    sync.WaitGroup
+	workers    sync.WaitGroup
+
+	queueLock   sync.Mutex
+	queueCond   *sync.Cond
+	queue       []R
+	workersDone bool
+}
+
+// NewWorkerPool creates a new worker pool and starts the given number of workers.
+// If the number of workers is zero or negative, runtime.NumCPU() workers are started.
+//
+// The pool must be closed with Close after all jobs have been submitted to stop the workers.
+func NewWorkerPool[J, R any](workers int, fn func(J) R) *WorkerPool[J, R] {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	wp := &WorkerPool[J, R]{
+		fn:      fn,
+		jobs:    make(chan J, workers),
+		results: make(chan R),
+	}
+	wp.queueCond = sync.NewCond(&wp.queueLock)
+	wp.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go wp.work()
+	}
+	go wp.forwardResults()
+	return wp
+}
+
+func (wp *WorkerPool[J, R]) work() {
+	defer wp.workers.Done()
+	for job := range wp.jobs {
+		res := wp.fn(job)
+		wp.queueLock.Lock()
+		wp.queue = append(wp.queue, res)
+		wp.queueCond.Signal()
+		wp.queueLock.Unlock()
+		wp.pending.Done()
+	}
+}
+
+func (wp *WorkerPool[J, R]) forwardResults() {
+	defer close(wp.results)
+	var zero R
+	for {
+		wp.queueLock.Lock()
+		for len(wp.queue) == 0 && !wp.workersDone {
+			wp.queueCond.Wait()
+		}
+		if len(wp.queue) == 0 {
+			wp.queueLock.Unlock()
+			return
+		}
+		res := wp.queue[0]
+		wp.queue[0] = zero
+		wp.queue = wp.queue[1:]
+		wp.queueLock.Unlock()
+		wp.results <- res
+	}
+}
+
+// Submit adds a job to the queue. This blocks if all workers are busy and the job queue is full.
+//
+// This panics if the pool has been closed.
+func (wp *WorkerPool[J, R]) Submit(job J) {
+	wp.submitLock.RLock()
+	defer wp.submitLock.RUnlock()
+	if wp.closed {
+		panic("exsync: Submit called on closed WorkerPool")
+	}
+	wp.pending.Add(1)
+	wp.jobs <- job
+}
+
+// SubmitAll adds all the given jobs to the queue. See Submit for details.
+func (wp *WorkerPool[J, R]) SubmitAll(jobs []J) {
+	for _, job := range jobs {
+		wp.Submit(job)
+	}
+}
+
+// Results returns the channel that results are sent to.
+// The channel is closed after the pool is closed and all results have been read.
+func (wp *WorkerPool[J, R]) Results() <-chan R {
+	return wp.results
+}
+
+// Wait blocks until all jobs that have been submitted so far have been processed.
+// The results of the jobs may not have been read from the Results channel yet.
+func (wp *WorkerPool[J, R]) Wait() {
+	wp.pending.Wait()
+}
+
+// Close stops accepting new jobs and waits for the workers to process the already submitted jobs and exit.
+// The Results channel is closed after the remaining results have been read.
+//
+// Calling Close multiple times is safe.
+func (wp *WorkerPool[J, R]) Close() {
+	wp.closeOnce.Do(func() {
+		wp.submitLock.Lock()
+		wp.closed = true
+		close(wp.jobs)
+		wp.submitLock.Unlock()
+	})
+	wp.workers.Wait()
+	wp.queueLock.Lock()
+	wp.workersDone = true
+	wp.queueCond.Broadcast()
+	wp.queueLock.Unlock()
+}
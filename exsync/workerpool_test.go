@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package exsync_test
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/exsync"
+	"go.mau.fi/util/result"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pool := exsync.NewWorkerPool(4, func(i int) int {
+		return i * 2
+	})
+	jobs := make([]int, 100)
+	for i := range jobs {
+		jobs[i] = i
+	}
+	// Results are buffered, so waiting before reading them doesn't deadlock
+	pool.SubmitAll(jobs)
+	pool.Wait()
+	pool.Close()
+	var results []int
+	for res := range pool.Results() {
+		results = append(results, res)
+	}
+	slices.Sort(results)
+	expected := make([]int, 100)
+	for i := range expected {
+		expected[i] = i * 2
+	}
+	assert.Equal(t, expected, results)
+}
+
+func TestWorkerPool_Concurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	pool := exsync.NewWorkerPool(3, func(struct{}) struct{} {
+		cur := running.Add(1)
+		for {
+			prev := maxRunning.Load()
+			if cur <= prev || maxRunning.CompareAndSwap(prev, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return struct{}{}
+	})
+	go func() {
+		pool.SubmitAll(make([]struct{}, 20))
+		pool.Close()
+	}()
+	count := 0
+	for range pool.Results() {
+		count++
+	}
+	assert.Equal(t, 20, count)
+	assert.Equal(t, int32(3), maxRunning.Load())
+}
+
+func TestWorkerPool_Errors(t *testing.T) {
+	errOdd := errors.New("odd number")
+	pool := exsync.NewWorkerPool(0, func(i int) result.Result[int, error] {
+		if i%2 == 1 {
+			return result.Err[int](errOdd)
+		}
+		return result.Ok(i)
+	})
+	pool.SubmitAll([]int{1, 2, 3, 4})
+	pool.Close()
+	var oks, errs int
+	for res := range pool.Results() {
+		if res.IsOk() {
+			oks++
+		} else {
+			assert.ErrorIs(t, res.UnwrapErr(), errOdd)
+			errs++
+		}
+	}
+	assert.Equal(t, 2, oks)
+	assert.Equal(t, 2, errs)
+}
+
+func TestWorkerPool_SubmitAfterClose(t *testing.T) {
+	pool := exsync.NewWorkerPool(1, func(i int) int { return i })
+	pool.Close()
+	pool.Close()
+	assert.Panics(t, func() {
+		pool.Submit(1)
+	})
+	_, ok := <-pool.Results()
+	assert.False(t, ok)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package funcutil contains wrappers that change when and how often functions are called.
+package funcutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer is a wrapper for a function that delays calls until there have been no new calls for a while.
+// It's safe for concurrent use.
+//
+// The Call method value can be used wherever a plain func(T) is expected.
+type Debouncer[T any] struct {
+	fn      func(T)
+	wait    time.Duration
+	leading bool
+
+	lock  sync.Mutex
+	timer *time.Timer
+	// gen is incremented on every call and cancellation to make timers that were already firing when stopped no-ops.
+	gen uint64
+}
+
+// Debounce returns a wrapper that calls the given function once wait has elapsed since the last call to the wrapper.
+// Each call cancels the pending invocation, so only the argument of the last call in a burst is passed to the function.
+//
+// The function is called in a separate goroutine.
+func Debounce[T any](fn func(T), wait time.Duration) *Debouncer[T] {
+	return &Debouncer[T]{fn: fn, wait: wait}
+}
+
+// DebounceLeading returns a wrapper that calls the given function immediately on the first call, then ignores
+// calls until wait has elapsed since the last call to the wrapper.
+//
+// The function is called synchronously in the goroutine calling the wrapper.
+func DebounceLeading[T any](fn func(T), wait time.Duration) *Debouncer[T] {
+	return &Debouncer[T]{fn: fn, wait: wait, leading: true}
+}
+
+// Call calls or schedules the wrapped function depending on the type of the debouncer.
+func (d *Debouncer[T]) Call(arg T) {
+	d.lock.Lock()
+	fireNow := d.leading && d.timer == nil
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.gen++
+	gen := d.gen
+	d.timer = time.AfterFunc(d.wait, func() {
+		d.lock.Lock()
+		if d.gen != gen {
+			d.lock.Unlock()
+			return
+		}
+		d.timer = nil
+		d.lock.Unlock()
+		if !d.leading {
+			d.fn(arg)
+		}
+	})
+	d.lock.Unlock()
+	if fireNow {
+		d.fn(arg)
+	}
+}
+
+// Cancel stops the internal timer. For Debounce, this drops the pending call. For DebounceLeading, the next call
+// will be passed through immediately.
+func (d *Debouncer[T]) Cancel() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.gen++
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+// Throttler is a wrapper for a function that limits how often the function can be called.
+// It's safe for concurrent use.
+//
+// The Call method value can be used wherever a plain func(T) is expected.
+type Throttler[T any] struct {
+	fn       func(T)
+	interval time.Duration
+
+	lock     sync.Mutex
+	lastCall time.Time
+}
+
+// Throttle returns a wrapper that calls the given function immediately, then drops all calls until the interval
+// has elapsed since the function was last called.
+//
+// The function is called synchronously in the goroutine calling the wrapper.
+func Throttle[T any](fn func(T), interval time.Duration) *Throttler[T] {
+	return &Throttler[T]{fn: fn, interval: interval}
+}
+
+// Call calls the wrapped function unless it was already called within the interval.
+func (t *Throttler[T]) Call(arg T) {
+	t.lock.Lock()
+	now := time.Now()
+	if !t.lastCall.IsZero() && now.Sub(t.lastCall) < t.interval {
+		t.lock.Unlock()
+		return
+	}
+	t.lastCall = now
+	t.lock.Unlock()
+	t.fn(arg)
+}
+
+// Cancel resets the throttler, so the next call will be passed through immediately.
+func (t *Throttler[T]) Cancel() {
+	t.lock.Lock()
+	t.lastCall = time.Time{}
+	t.lock.Unlock()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package funcutil_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/funcutil"
+)
+
+type callRecorder struct {
+	lock  sync.Mutex
+	calls []int
+}
+
+func (cr *callRecorder) Record(arg int) {
+	cr.lock.Lock()
+	cr.calls = append(cr.calls, arg)
+	cr.lock.Unlock()
+}
+
+func (cr *callRecorder) Calls() []int {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	return slices.Clone(cr.calls)
+}
+
+func TestDebounce(t *testing.T) {
+	var rec callRecorder
+	debounced := funcutil.Debounce(rec.Record, 20*time.Millisecond)
+	for i := 1; i <= 5; i++ {
+		debounced.Call(i)
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Empty(t, rec.Calls())
+	assert.Eventually(t, func() bool {
+		return len(rec.Calls()) > 0
+	}, time.Second, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, []int{5}, rec.Calls())
+
+	debounced.Call(6)
+	assert.Eventually(t, func() bool {
+		return len(rec.Calls()) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []int{5, 6}, rec.Calls())
+}
+
+func TestDebounce_Cancel(t *testing.T) {
+	var rec callRecorder
+	debounced := funcutil.Debounce(rec.Record, 10*time.Millisecond)
+	debounced.Call(1)
+	debounced.Cancel()
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, rec.Calls())
+	debounced.Cancel()
+}
+
+func TestDebounceLeading(t *testing.T) {
+	var rec callRecorder
+	debounced := funcutil.DebounceLeading(rec.Record, 20*time.Millisecond)
+	for i := 1; i <= 5; i++ {
+		debounced.Call(i)
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Equal(t, []int{1}, rec.Calls())
+	time.Sleep(40 * time.Millisecond)
+	debounced.Call(6)
+	debounced.Call(7)
+	assert.Equal(t, []int{1, 6}, rec.Calls())
+	debounced.Cancel()
+	debounced.Call(8)
+	assert.Equal(t, []int{1, 6, 8}, rec.Calls())
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, []int{1, 6, 8}, rec.Calls())
+}
+
+func TestThrottle(t *testing.T) {
+	var rec callRecorder
+	throttled := funcutil.Throttle(rec.Record, 30*time.Millisecond)
+	throttled.Call(1)
+	throttled.Call(2)
+	assert.Equal(t, []int{1}, rec.Calls())
+	time.Sleep(40 * time.Millisecond)
+	throttled.Call(3)
+	throttled.Call(4)
+	assert.Equal(t, []int{1, 3}, rec.Calls())
+	throttled.Cancel()
+	throttled.Call(5)
+	assert.Equal(t, []int{1, 3, 5}, rec.Calls())
+}
+
+func TestThrottle_Concurrent(t *testing.T) {
+	var rec callRecorder
+	throttled := funcutil.Throttle(rec.Record, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttled.Call(i)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, rec.Calls(), 1)
+}
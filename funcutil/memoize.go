@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package funcutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Memoize returns a wrapper that caches the results of the given function, so it's only called once per argument.
+//
+// The cache is unbounded, see the cache package for an LRU cache. The returned function is not safe for concurrent
+// use, use MemoizeSync for that.
+func Memoize[K comparable, V any](fn func(K) V) func(K) V {
+	cache := make(map[K]V)
+	return func(key K) V {
+		val, ok := cache[key]
+		if !ok {
+			val = fn(key)
+			cache[key] = val
+		}
+		return val
+	}
+}
+
+type memoEntry[V any] struct {
+	once sync.Once
+	val  V
+	// expiry is only used by MemoizeWithTTL
+	expiry time.Time
+}
+
+func (entry *memoEntry[V]) get(fn func() V) V {
+	entry.once.Do(func() {
+		entry.val = fn()
+	})
+	return entry.val
+}
+
+// MemoizeSync is a version of Memoize that is safe for concurrent use.
+//
+// The function is called exactly once per argument even if the wrapper is called concurrently: other callers with
+// the same argument wait for the first call to return. Calls with different arguments don't block each other.
+func MemoizeSync[K comparable, V any](fn func(K) V) func(K) V {
+	var cache sync.Map
+	return func(key K) V {
+		entry, ok := cache.Load(key)
+		if !ok {
+			entry, _ = cache.LoadOrStore(key, &memoEntry[V]{})
+		}
+		return entry.(*memoEntry[V]).get(func() V {
+			return fn(key)
+		})
+	}
+}
+
+// MemoizeWithTTL is a version of MemoizeSync where cached results expire after the given duration.
+// The duration is counted from when the function was called for the key.
+//
+// Expired results are only removed from the cache when the same argument is used again,
+// so the cache is unbounded like with the other variants.
+func MemoizeWithTTL[K comparable, V any](fn func(K) V, ttl time.Duration) func(K) V {
+	var lock sync.Mutex
+	cache := make(map[K]*memoEntry[V])
+	return func(key K) V {
+		lock.Lock()
+		entry, ok := cache[key]
+		if now := time.Now(); !ok || now.After(entry.expiry) {
+			entry = &memoEntry[V]{expiry: now.Add(ttl)}
+			cache[key] = entry
+		}
+		lock.Unlock()
+		return entry.get(func() V {
+			return fn(key)
+		})
+	}
+}
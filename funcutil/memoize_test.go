@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package funcutil_test
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/funcutil"
+)
+
+func TestMemoize(t *testing.T) {
+	calls := map[int]int{}
+	memoized := funcutil.Memoize(func(i int) string {
+		calls[i]++
+		return strconv.Itoa(i)
+	})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "1", memoized(1))
+		assert.Equal(t, "2", memoized(2))
+	}
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, calls)
+}
+
+func TestMemoizeSync(t *testing.T) {
+	var calls atomic.Int32
+	memoized := funcutil.MemoizeSync(func(i int) int {
+		calls.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		return i * 2
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, (i%4)*2, memoized(i%4))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestMemoizeWithTTL(t *testing.T) {
+	var calls atomic.Int32
+	memoized := funcutil.MemoizeWithTTL(func(i int) int32 {
+		return calls.Add(1)
+	}, 20*time.Millisecond)
+	assert.Equal(t, int32(1), memoized(1))
+	assert.Equal(t, int32(2), memoized(2))
+	assert.Equal(t, int32(1), memoized(1))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, int32(3), memoized(1))
+	assert.Equal(t, int32(3), memoized(1))
+}
@@ -1,6 +1,6 @@
 module go.mau.fi/util
 
-go 1.21
+go 1.23
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.2
@@ -9,6 +9,7 @@ require (
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842
 	golang.org/x/sys v0.12.0
+	golang.org/x/text v0.15.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
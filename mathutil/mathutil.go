@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package mathutil contains generic versions of common math functions that the standard library
+// only provides for float64 or not at all.
+package mathutil
+
+import (
+	"cmp"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Clamp returns v limited to the range [lo, hi]. The result is undefined if lo > hi.
+func Clamp[T constraints.Ordered](v, lo, hi T) T {
+	return min(max(v, lo), hi)
+}
+
+// Min returns the smaller of a and b. For floats, the result is NaN if either value is NaN.
+func Min[T constraints.Ordered](a, b T) T {
+	return min(a, b)
+}
+
+// Max returns the larger of a and b. For floats, the result is NaN if either value is NaN.
+func Max[T constraints.Ordered](a, b T) T {
+	return max(a, b)
+}
+
+// Min3 returns the smallest of a, b and c.
+func Min3[T constraints.Ordered](a, b, c T) T {
+	return min(a, b, c)
+}
+
+// Max3 returns the largest of a, b and c.
+func Max3[T constraints.Ordered](a, b, c T) T {
+	return max(a, b, c)
+}
+
+// Abs returns the absolute value of v.
+//
+// The absolute value of the smallest value of the type (e.g. math.MinInt64) can't be represented,
+// so that value is returned unchanged.
+func Abs[T constraints.Signed](v T) T {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Sign returns -1 if v is negative, 1 if v is positive and 0 if v is zero.
+func Sign[T constraints.Signed](v T) T {
+	return T(cmp.Compare(v, 0))
+}
+
+// SumSlice returns the sum of all values in the slice, or zero if the slice is empty.
+// Overflows wrap around like with the + operator.
+func SumSlice[T constraints.Integer | constraints.Float](s []T) T {
+	var sum T
+	for _, v := range s {
+		sum += v
+	}
+	return sum
+}
+
+// ProductSlice returns the product of all values in the slice, or one if the slice is empty.
+// Overflows wrap around like with the * operator.
+func ProductSlice[T constraints.Integer | constraints.Float](s []T) T {
+	product := T(1)
+	for _, v := range s {
+		product *= v
+	}
+	return product
+}
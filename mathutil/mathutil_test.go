@@ -0,0 +1,87 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mathutil_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/mathutil"
+)
+
+func TestClamp(t *testing.T) {
+	assert.Equal(t, 5, mathutil.Clamp(5, 0, 10))
+	assert.Equal(t, 0, mathutil.Clamp(-5, 0, 10))
+	assert.Equal(t, 10, mathutil.Clamp(15, 0, 10))
+	assert.Equal(t, 10, mathutil.Clamp(10, 10, 10))
+	assert.Equal(t, uint8(255), mathutil.Clamp[uint8](255, 1, 255))
+	assert.Equal(t, 0.5, mathutil.Clamp(0.5, 0, 1))
+	assert.Equal(t, 1.0, mathutil.Clamp(math.Inf(1), 0, 1))
+	assert.Equal(t, "b", mathutil.Clamp("a", "b", "d"))
+	assert.Equal(t, time.Minute, mathutil.Clamp(time.Hour, time.Second, time.Minute))
+}
+
+func TestMinMax(t *testing.T) {
+	assert.Equal(t, 1, mathutil.Min(1, 2))
+	assert.Equal(t, 2, mathutil.Max(1, 2))
+	assert.Equal(t, -2.5, mathutil.Min(-2.5, 2))
+	assert.Equal(t, "b", mathutil.Max("a", "b"))
+	assert.Equal(t, 1, mathutil.Min3(3, 1, 2))
+	assert.Equal(t, 1, mathutil.Min3(1, 1, 1))
+	assert.Equal(t, 3, mathutil.Max3(2, 1, 3))
+	assert.Equal(t, int64(math.MinInt64), mathutil.Min3[int64](0, math.MinInt64, math.MaxInt64))
+	assert.True(t, math.IsNaN(mathutil.Max(math.NaN(), 1)))
+	assert.True(t, math.IsNaN(mathutil.Min3(1, 2, math.NaN())))
+}
+
+func TestAbs(t *testing.T) {
+	assert.Equal(t, 5, mathutil.Abs(-5))
+	assert.Equal(t, 5, mathutil.Abs(5))
+	assert.Equal(t, 0, mathutil.Abs(0))
+	assert.Equal(t, int8(127), mathutil.Abs[int8](-127))
+	assert.Equal(t, int8(math.MinInt8), mathutil.Abs[int8](math.MinInt8))
+	assert.Equal(t, time.Second, mathutil.Abs(-time.Second))
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, -1, mathutil.Sign(-42))
+	assert.Equal(t, 0, mathutil.Sign(0))
+	assert.Equal(t, 1, mathutil.Sign(42))
+	assert.Equal(t, int8(-1), mathutil.Sign[int8](math.MinInt8))
+	assert.Equal(t, int64(1), mathutil.Sign[int64](math.MaxInt64))
+}
+
+func TestSumSlice(t *testing.T) {
+	assert.Equal(t, 0, mathutil.SumSlice[int](nil))
+	assert.Equal(t, 10, mathutil.SumSlice([]int{1, 2, 3, 4}))
+	assert.Equal(t, 1.75, mathutil.SumSlice([]float64{1, 0.5, 0.25}))
+	assert.Equal(t, uint8(4), mathutil.SumSlice([]uint8{255, 5}))
+}
+
+func TestProductSlice(t *testing.T) {
+	assert.Equal(t, 1, mathutil.ProductSlice[int](nil))
+	assert.Equal(t, 24, mathutil.ProductSlice([]int{1, 2, 3, 4}))
+	assert.Equal(t, 0, mathutil.ProductSlice([]int{1, 0, 3}))
+	assert.Equal(t, -0.125, mathutil.ProductSlice([]float64{0.5, -0.5, 0.5}))
+}
+
+func TestNoAllocations(t *testing.T) {
+	values := []int{1, 2, 3}
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = mathutil.Clamp(values[0], values[1], values[2])
+		_ = mathutil.Min3(values[0], values[1], values[2])
+		_ = mathutil.Max3(values[0], values[1], values[2])
+		_ = mathutil.Abs(values[0])
+		_ = mathutil.Sign(values[0])
+		_ = mathutil.SumSlice(values)
+		_ = mathutil.ProductSlice(values)
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mathutil
+
+import (
+	"golang.org/x/exp/constraints"
+)
+
+// isSigned returns true if T is a signed integer type.
+func isSigned[T constraints.Integer]() bool {
+	return ^T(0) < 0
+}
+
+// bitSize returns the number of bits in T.
+func bitSize[T constraints.Integer]() int {
+	size := 8
+	for size < 64 && T(1)<<size != 0 {
+		size *= 2
+	}
+	return size
+}
+
+// MinValue returns the smallest value of the integer type T, e.g. math.MinInt16 for int16 or 0 for uint16.
+func MinValue[T constraints.Integer]() T {
+	if !isSigned[T]() {
+		return 0
+	}
+	return T(1) << (bitSize[T]() - 1)
+}
+
+// MaxValue returns the largest value of the integer type T, e.g. math.MaxInt16 for int16.
+func MaxValue[T constraints.Integer]() T {
+	return ^MinValue[T]()
+}
+
+// CheckedAdd returns a + b. If the result overflows, it returns zero and false.
+func CheckedAdd[T constraints.Integer](a, b T) (T, bool) {
+	sum := a + b
+	// With wrapping arithmetic, the sum overflowed if adding a positive number made it smaller
+	// or adding a negative number made it larger.
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// CheckedSub returns a - b. If the result overflows, it returns zero and false.
+func CheckedSub[T constraints.Integer](a, b T) (T, bool) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return 0, false
+	}
+	return diff, true
+}
+
+// CheckedMul returns a * b. If the result overflows, it returns zero and false.
+func CheckedMul[T constraints.Integer](a, b T) (T, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	// MinValue / -1 overflows back to MinValue, so the division check doesn't catch MinValue * -1.
+	if product/b != a || (isSigned[T]() && b == ^T(0) && a == MinValue[T]()) {
+		return 0, false
+	}
+	return product, true
+}
+
+// SaturatingAdd returns a + b, clamped to the range of T instead of wrapping around on overflow.
+//
+// Like the other saturating functions, this detects the overflow first and then clamps the result to the limit
+// in the direction of the overflow, as described in Hacker's Delight (section 2-13).
+func SaturatingAdd[T constraints.Integer](a, b T) T {
+	if sum, ok := CheckedAdd(a, b); ok {
+		return sum
+	} else if b < 0 {
+		return MinValue[T]()
+	}
+	return MaxValue[T]()
+}
+
+// SaturatingSub returns a - b, clamped to the range of T instead of wrapping around on overflow.
+// For unsigned types, this means the result is zero if b > a.
+func SaturatingSub[T constraints.Integer](a, b T) T {
+	if diff, ok := CheckedSub(a, b); ok {
+		return diff
+	} else if b > 0 {
+		return MinValue[T]()
+	}
+	return MaxValue[T]()
+}
+
+// SaturatingMul returns a * b, clamped to the range of T instead of wrapping around on overflow.
+func SaturatingMul[T constraints.Integer](a, b T) T {
+	if product, ok := CheckedMul(a, b); ok {
+		return product
+	} else if (a < 0) != (b < 0) {
+		return MinValue[T]()
+	}
+	return MaxValue[T]()
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mathutil_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/constraints"
+
+	"go.mau.fi/util/mathutil"
+)
+
+func TestMinMaxValue(t *testing.T) {
+	assert.Equal(t, int8(math.MinInt8), mathutil.MinValue[int8]())
+	assert.Equal(t, int8(math.MaxInt8), mathutil.MaxValue[int8]())
+	assert.Equal(t, int16(math.MinInt16), mathutil.MinValue[int16]())
+	assert.Equal(t, int32(math.MaxInt32), mathutil.MaxValue[int32]())
+	assert.Equal(t, int64(math.MinInt64), mathutil.MinValue[int64]())
+	assert.Equal(t, int64(math.MaxInt64), mathutil.MaxValue[int64]())
+	assert.Equal(t, math.MaxInt, mathutil.MaxValue[int]())
+	assert.Equal(t, uint8(0), mathutil.MinValue[uint8]())
+	assert.Equal(t, uint8(math.MaxUint8), mathutil.MaxValue[uint8]())
+	assert.Equal(t, uint32(math.MaxUint32), mathutil.MaxValue[uint32]())
+	assert.Equal(t, uint64(math.MaxUint64), mathutil.MaxValue[uint64]())
+	assert.Equal(t, uintptr(0), mathutil.MinValue[uintptr]())
+}
+
+// testExhaustive compares the results of the checked and saturating functions for all pairs of 8-bit values
+// with the results of the same operations done with int64s.
+func testExhaustive[T int8 | uint8](t *testing.T, from, to int64) {
+	ops := []struct {
+		name       string
+		checked    func(a, b T) (T, bool)
+		saturating func(a, b T) T
+		wide       func(a, b int64) int64
+	}{
+		{"Add", mathutil.CheckedAdd[T], mathutil.SaturatingAdd[T], func(a, b int64) int64 { return a + b }},
+		{"Sub", mathutil.CheckedSub[T], mathutil.SaturatingSub[T], func(a, b int64) int64 { return a - b }},
+		{"Mul", mathutil.CheckedMul[T], mathutil.SaturatingMul[T], func(a, b int64) int64 { return a * b }},
+	}
+	for _, op := range ops {
+		for a := from; a <= to; a++ {
+			for b := from; b <= to; b++ {
+				expected := op.wide(a, b)
+				inRange := expected >= from && expected <= to
+				result, ok := op.checked(T(a), T(b))
+				if ok != inRange || (ok && int64(result) != expected) {
+					t.Fatalf("Checked%s(%d, %d) = %d, %t; expected %d, %t", op.name, a, b, result, ok, expected, inRange)
+				}
+				saturated := op.saturating(T(a), T(b))
+				if int64(saturated) != mathutil.Clamp(expected, from, to) {
+					t.Fatalf("Saturating%s(%d, %d) = %d; expected %d", op.name, a, b, saturated, mathutil.Clamp(expected, from, to))
+				}
+			}
+		}
+	}
+}
+
+func TestSaturating_Exhaustive(t *testing.T) {
+	t.Run("int8", func(t *testing.T) {
+		testExhaustive[int8](t, math.MinInt8, math.MaxInt8)
+	})
+	t.Run("uint8", func(t *testing.T) {
+		testExhaustive[uint8](t, 0, math.MaxUint8)
+	})
+}
+
+func assertChecked[T constraints.Integer](t *testing.T, expected T, expectedOK bool) func(T, bool) {
+	return func(result T, ok bool) {
+		t.Helper()
+		assert.Equal(t, expectedOK, ok)
+		assert.Equal(t, expected, result)
+	}
+}
+
+func TestChecked_Int64(t *testing.T) {
+	assertChecked[int64](t, math.MaxInt64, true)(mathutil.CheckedAdd[int64](math.MaxInt64-1, 1))
+	assertChecked[int64](t, 0, false)(mathutil.CheckedAdd[int64](math.MaxInt64, 1))
+	assertChecked[int64](t, 0, false)(mathutil.CheckedSub[int64](math.MinInt64, 1))
+	assertChecked[int64](t, math.MaxInt64, true)(mathutil.CheckedSub[int64](-1, math.MinInt64))
+	assertChecked[int64](t, 0, false)(mathutil.CheckedMul[int64](math.MinInt64, -1))
+	assertChecked[int64](t, 0, false)(mathutil.CheckedMul[int64](-1, math.MinInt64))
+	assertChecked[int64](t, math.MinInt64, true)(mathutil.CheckedMul[int64](math.MinInt64, 1))
+	assertChecked[int64](t, -math.MaxInt64, true)(mathutil.CheckedMul[int64](math.MaxInt64, -1))
+	assertChecked[int64](t, 0, false)(mathutil.CheckedMul[int64](1<<32, 1<<31))
+	assertChecked[uint64](t, 0, false)(mathutil.CheckedMul[uint64](1<<32, 1<<32))
+	assertChecked[uint64](t, 1<<63, true)(mathutil.CheckedMul[uint64](1<<32, 1<<31))
+}
+
+func TestSaturating_Int64(t *testing.T) {
+	assert.Equal(t, int64(math.MaxInt64), mathutil.SaturatingAdd[int64](math.MaxInt64, math.MaxInt64))
+	assert.Equal(t, int64(math.MinInt64), mathutil.SaturatingAdd[int64](math.MinInt64, -1))
+	assert.Equal(t, int64(math.MaxInt64), mathutil.SaturatingSub[int64](0, math.MinInt64))
+	assert.Equal(t, int64(math.MinInt64), mathutil.SaturatingSub[int64](-2, math.MaxInt64))
+	assert.Equal(t, int64(math.MaxInt64), mathutil.SaturatingMul[int64](math.MinInt64, -1))
+	assert.Equal(t, int64(math.MinInt64), mathutil.SaturatingMul[int64](math.MaxInt64, -2))
+	assert.Equal(t, uint64(0), mathutil.SaturatingSub[uint64](1, 2))
+	assert.Equal(t, uint64(math.MaxUint64), mathutil.SaturatingAdd[uint64](math.MaxUint64, 1))
+	assert.Equal(t, 42, mathutil.SaturatingAdd(40, 2))
+}
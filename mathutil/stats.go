@@ -0,0 +1,158 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mathutil
+
+import (
+	"math"
+	"slices"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Mean returns the arithmetic mean of the values in the slice, or NaN if the slice is empty.
+func Mean[T constraints.Float](s []T) T {
+	return SumSlice(s) / T(len(s))
+}
+
+// MeanInt returns the arithmetic mean of the integers in the slice as a float, or NaN if the slice is empty.
+//
+// The sum is calculated using floats, so it doesn't overflow even if the sum doesn't fit in the integer type.
+func MeanInt[T constraints.Integer](s []T) float64 {
+	var sum float64
+	for _, v := range s {
+		sum += float64(v)
+	}
+	return sum / float64(len(s))
+}
+
+// Variance returns the population variance of the values in the slice, or NaN if the slice is empty.
+func Variance[T constraints.Float](s []T) T {
+	mean := Mean(s)
+	var sum T
+	for _, v := range s {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / T(len(s))
+}
+
+// StdDev returns the population standard deviation of the values in the slice, or NaN if the slice is empty.
+func StdDev[T constraints.Float](s []T) T {
+	return T(math.Sqrt(float64(Variance(s))))
+}
+
+// Median returns the median of the values in the slice, or NaN if the slice is empty.
+// If the slice has an even number of values, the mean of the two middle values is returned.
+//
+// The input slice is not modified.
+func Median[T constraints.Float](s []T) T {
+	return Percentile(s, 50)
+}
+
+// Percentile returns the p-th percentile (0-100) of the values in the slice, or NaN if the slice is empty
+// or p is NaN. Values of p outside the range are clamped to it.
+//
+// If the percentile falls between two values, the result is linearly interpolated between them
+// (i.e. the same method as the default in numpy and Excel's PERCENTILE.INC). The input slice is not modified.
+func Percentile[T constraints.Float](s []T, p float64) T {
+	if len(s) == 0 || math.IsNaN(p) {
+		return T(math.NaN())
+	}
+	sorted := slices.Clone(s)
+	slices.Sort(sorted)
+	rank := Clamp(p, 0, 100) / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower == len(sorted)-1 {
+		return sorted[lower]
+	}
+	frac := T(rank - float64(lower))
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*frac
+}
+
+// Mode returns the most common value in the slice and the number of times it occurs.
+// If multiple values are equally common, the one that occurs first in the slice is returned.
+// If the slice is empty, the zero value and 0 are returned.
+func Mode[T comparable](s []T) (mode T, count int) {
+	counts := make(map[T]int, len(s))
+	for _, v := range s {
+		counts[v]++
+	}
+	for _, v := range s {
+		if counts[v] > count {
+			mode, count = v, counts[v]
+		}
+	}
+	return
+}
+
+// Stats calculates statistics incrementally, so that values don't need to be stored.
+// The zero value is ready to use. Stats is not safe for concurrent use.
+//
+// The mean and variance are calculated using Welford's algorithm, which is numerically stable
+// even with a large number of values.
+type Stats[T constraints.Integer | constraints.Float] struct {
+	count int
+	mean  float64
+	// m2 is the sum of squared differences from the current mean.
+	m2       float64
+	sum      float64
+	min, max T
+}
+
+// Add adds a value to the statistics.
+func (st *Stats[T]) Add(v T) {
+	st.count++
+	if st.count == 1 {
+		st.min, st.max = v, v
+	} else {
+		st.min, st.max = min(st.min, v), max(st.max, v)
+	}
+	st.sum += float64(v)
+	delta := float64(v) - st.mean
+	st.mean += delta / float64(st.count)
+	st.m2 += delta * (float64(v) - st.mean)
+}
+
+// Count returns the number of values added.
+func (st *Stats[T]) Count() int {
+	return st.count
+}
+
+// Sum returns the sum of all values added.
+func (st *Stats[T]) Sum() float64 {
+	return st.sum
+}
+
+// Mean returns the arithmetic mean of the values added, or NaN if no values have been added.
+func (st *Stats[T]) Mean() float64 {
+	if st.count == 0 {
+		return math.NaN()
+	}
+	return st.mean
+}
+
+// Variance returns the population variance of the values added, or NaN if no values have been added.
+func (st *Stats[T]) Variance() float64 {
+	if st.count == 0 {
+		return math.NaN()
+	}
+	return st.m2 / float64(st.count)
+}
+
+// StdDev returns the population standard deviation of the values added, or NaN if no values have been added.
+func (st *Stats[T]) StdDev() float64 {
+	return math.Sqrt(st.Variance())
+}
+
+// Min returns the smallest value added, or zero if no values have been added.
+func (st *Stats[T]) Min() T {
+	return st.min
+}
+
+// Max returns the largest value added, or zero if no values have been added.
+func (st *Stats[T]) Max() T {
+	return st.max
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mathutil_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/mathutil"
+)
+
+var statsTestData = []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+func TestMean(t *testing.T) {
+	assert.Equal(t, 5.0, mathutil.Mean(statsTestData))
+	assert.Equal(t, float32(1.5), mathutil.Mean([]float32{1, 2}))
+	assert.True(t, math.IsNaN(mathutil.Mean[float64](nil)))
+}
+
+func TestMeanInt(t *testing.T) {
+	assert.Equal(t, 2.5, mathutil.MeanInt([]int{1, 2, 3, 4}))
+	assert.Equal(t, 127.5, mathutil.MeanInt([]uint8{255, 0}))
+	assert.Equal(t, float64(math.MaxInt64), mathutil.MeanInt([]int64{math.MaxInt64, math.MaxInt64}))
+	assert.True(t, math.IsNaN(mathutil.MeanInt[int](nil)))
+}
+
+func TestVarianceStdDev(t *testing.T) {
+	assert.Equal(t, 4.0, mathutil.Variance(statsTestData))
+	assert.Equal(t, 2.0, mathutil.StdDev(statsTestData))
+	assert.Equal(t, 0.0, mathutil.Variance([]float64{3}))
+	assert.True(t, math.IsNaN(mathutil.StdDev[float64](nil)))
+}
+
+func TestMedian(t *testing.T) {
+	input := []float64{5, 1, 4, 2, 3}
+	assert.Equal(t, 3.0, mathutil.Median(input))
+	assert.Equal(t, []float64{5, 1, 4, 2, 3}, input, "input must not be modified")
+	assert.Equal(t, 2.5, mathutil.Median([]float64{4, 1, 3, 2}))
+	assert.Equal(t, 7.0, mathutil.Median([]float64{7}))
+	assert.True(t, math.IsNaN(mathutil.Median[float64](nil)))
+}
+
+func TestPercentile(t *testing.T) {
+	input := []float64{15, 20, 35, 40, 50}
+	assert.Equal(t, 15.0, mathutil.Percentile(input, 0))
+	assert.Equal(t, 50.0, mathutil.Percentile(input, 100))
+	assert.Equal(t, 20.0, mathutil.Percentile(input, 25))
+	assert.Equal(t, 29.0, mathutil.Percentile(input, 40))
+	assert.True(t, math.IsNaN(mathutil.Percentile(input, math.NaN())))
+	assert.InDelta(t, 48.0, mathutil.Percentile(input, 95), 1e-9)
+	assert.Equal(t, 15.0, mathutil.Percentile(input, -10))
+	assert.Equal(t, 50.0, mathutil.Percentile(input, 150))
+	assert.Equal(t, 3.0, mathutil.Percentile([]float64{3}, 90))
+	assert.True(t, math.IsNaN(mathutil.Percentile[float64](nil, 50)))
+}
+
+func TestMode(t *testing.T) {
+	mode, count := mathutil.Mode(statsTestData)
+	assert.Equal(t, 4.0, mode)
+	assert.Equal(t, 3, count)
+	strMode, count := mathutil.Mode([]string{"b", "a", "a", "b", "c"})
+	assert.Equal(t, "b", strMode)
+	assert.Equal(t, 2, count)
+	strMode, count = mathutil.Mode[string](nil)
+	assert.Equal(t, "", strMode)
+	assert.Equal(t, 0, count)
+}
+
+func TestStats(t *testing.T) {
+	var st mathutil.Stats[float64]
+	assert.Equal(t, 0, st.Count())
+	assert.True(t, math.IsNaN(st.Mean()))
+	assert.True(t, math.IsNaN(st.Variance()))
+	for _, v := range statsTestData {
+		st.Add(v)
+	}
+	assert.Equal(t, len(statsTestData), st.Count())
+	assert.Equal(t, 40.0, st.Sum())
+	assert.Equal(t, mathutil.Mean(statsTestData), st.Mean())
+	assert.InDelta(t, mathutil.Variance(statsTestData), st.Variance(), 1e-9)
+	assert.InDelta(t, mathutil.StdDev(statsTestData), st.StdDev(), 1e-9)
+	assert.Equal(t, 2.0, st.Min())
+	assert.Equal(t, 9.0, st.Max())
+
+	var intStats mathutil.Stats[int]
+	for _, v := range []int{-3, 10, 4} {
+		intStats.Add(v)
+	}
+	assert.Equal(t, -3, intStats.Min())
+	assert.Equal(t, 10, intStats.Max())
+	assert.Equal(t, 11.0/3, intStats.Mean())
+}
+
+func TestStats_NumericalStability(t *testing.T) {
+	var st mathutil.Stats[float64]
+	for _, v := range []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16} {
+		st.Add(v)
+	}
+	assert.Equal(t, 1e9+10, st.Mean())
+	assert.InDelta(t, 22.5, st.Variance(), 1e-6)
+}
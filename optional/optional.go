@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package optional contains a generic type for values that may or may not be present.
+package optional
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Optional is a value that may or may not be present.
+//
+// The zero value is an empty optional (None). Optionals are comparable if the contained type is comparable,
+// so they can be used as map keys.
+type Optional[T any] struct {
+	value   T
+	present bool
+}
+
+// Some returns an optional containing the given value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{value: value, present: true}
+}
+
+// None returns an empty optional.
+func None[T any]() Optional[T] {
+	return Optional[T]{}
+}
+
+// IsPresent returns true if the optional contains a value.
+func (opt Optional[T]) IsPresent() bool {
+	return opt.present
+}
+
+// Get returns the value and a boolean indicating whether it's present.
+func (opt Optional[T]) Get() (T, bool) {
+	return opt.value, opt.present
+}
+
+// OrElse returns the value if it's present and the given default value otherwise.
+func (opt Optional[T]) OrElse(def T) T {
+	if opt.present {
+		return opt.value
+	}
+	return def
+}
+
+// IfPresent calls the given function with the value if it's present.
+func (opt Optional[T]) IfPresent(fn func(T)) {
+	if opt.present {
+		fn(opt.value)
+	}
+}
+
+// Map converts the value inside the optional with the given function if it's present.
+//
+// This is a function rather than a method, because Go methods can't have type parameters.
+func Map[T, U any](opt Optional[T], fn func(T) U) Optional[U] {
+	if !opt.present {
+		return None[U]()
+	}
+	return Some(fn(opt.value))
+}
+
+var jsonNull = []byte("null")
+
+// MarshalJSON marshals the value, or null if the optional is empty.
+func (opt Optional[T]) MarshalJSON() ([]byte, error) {
+	if !opt.present {
+		return jsonNull, nil
+	}
+	return json.Marshal(opt.value)
+}
+
+// UnmarshalJSON sets the optional to None if the data is null, and unmarshals the value otherwise.
+func (opt *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		*opt = None[T]()
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*opt = Some(value)
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package optional_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/optional"
+)
+
+func TestOptional(t *testing.T) {
+	some := optional.Some(5)
+	assert.True(t, some.IsPresent())
+	val, ok := some.Get()
+	assert.True(t, ok)
+	assert.Equal(t, 5, val)
+	assert.Equal(t, 5, some.OrElse(10))
+
+	none := optional.None[int]()
+	assert.False(t, none.IsPresent())
+	_, ok = none.Get()
+	assert.False(t, ok)
+	assert.Equal(t, 10, none.OrElse(10))
+
+	var zero optional.Optional[int]
+	assert.Equal(t, none, zero)
+	assert.NotEqual(t, optional.Some(0), zero)
+}
+
+func TestOptional_IfPresent(t *testing.T) {
+	var called []int
+	optional.Some(1).IfPresent(func(v int) { called = append(called, v) })
+	optional.None[int]().IfPresent(func(v int) { called = append(called, v) })
+	assert.Equal(t, []int{1}, called)
+}
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, optional.Some("5"), optional.Map(optional.Some(5), strconv.Itoa))
+	assert.Equal(t, optional.None[string](), optional.Map(optional.None[int](), strconv.Itoa))
+}
+
+func TestOptional_MapKey(t *testing.T) {
+	m := map[optional.Optional[string]]int{
+		optional.Some("a"):      1,
+		optional.None[string](): 2,
+	}
+	assert.Equal(t, 1, m[optional.Some("a")])
+	assert.Equal(t, 2, m[optional.None[string]()])
+}
+
+type jsonTest struct {
+	Name  optional.Optional[string] `json:"name"`
+	Count optional.Optional[int]    `json:"count"`
+}
+
+func TestOptional_JSON(t *testing.T) {
+	data, err := json.Marshal(jsonTest{Name: optional.Some("meow")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "meow", "count": null}`, string(data))
+
+	var parsed jsonTest
+	require.NoError(t, json.Unmarshal([]byte(`{"name": null, "count": 0}`), &parsed))
+	assert.Equal(t, optional.None[string](), parsed.Name)
+	assert.Equal(t, optional.Some(0), parsed.Count)
+
+	parsed = jsonTest{Name: optional.Some("old")}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": null}`), &parsed))
+	assert.False(t, parsed.Name.IsPresent())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"count": "not a number"}`), &parsed))
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package pipeline contains helpers for processing data in concurrent stages connected with channels.
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Stage is a function that transforms a single item in a pipeline.
+//
+// If the function returns an error, the item is dropped and the error is sent to the error channel.
+type Stage[T, U any] func(T) (U, error)
+
+// Chain starts a single stage that reads items from the input channel and processes them with the given number
+// of workers. The output and error channels are closed after the input channel is closed and all items have been
+// processed. Both channels must be drained, as the workers block until their outputs are read.
+//
+// If there are multiple workers, the output order isn't guaranteed to match the input order.
+func Chain[T, U any](in <-chan T, fn Stage[T, U], workers int) (<-chan U, <-chan error) {
+	return ChainBuffered(in, fn, workers, 0)
+}
+
+// ChainBuffered is like Chain, but the output and error channels have the given buffer size,
+// which allows the stage to process items ahead of the consumer.
+func ChainBuffered[T, U any](in <-chan T, fn Stage[T, U], workers, bufferSize int) (<-chan U, <-chan error) {
+	errs := make(chan error, bufferSize)
+	var wg sync.WaitGroup
+	out := runStage(context.Background(), in, fn, workers, bufferSize, errs, &wg)
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	return out, errs
+}
+
+func runStage[T, U any](ctx context.Context, in <-chan T, fn Stage[T, U], workers, bufferSize int, errs chan<- error, wg *sync.WaitGroup) <-chan U {
+	if workers <= 0 {
+		workers = 1
+	}
+	out := make(chan U, bufferSize)
+	var stageWG sync.WaitGroup
+	stageWG.Add(workers)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			defer stageWG.Done()
+			for {
+				var item T
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case item, ok = <-in:
+					if !ok {
+						return
+					}
+				}
+				res, err := fn(item)
+				if err != nil {
+					select {
+					case errs <- err:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				select {
+				case out <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		stageWG.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Pipeline is a builder for a sequence of stages. In is the type of items in the source channel
+// and Out is the type of items produced by the last stage.
+//
+// Go doesn't allow type parameters on methods, so stages are added using the Then function rather than a method.
+//
+// Example:
+//
+//	p := pipeline.NewPipeline[string]().WithBufferSize(16)
+//	parsed := pipeline.Then(p, parseLine, 4)
+//	enriched := pipeline.Then(parsed, enrich, 8)
+//	out, errs := enriched.Run(ctx, lines)
+type Pipeline[In, Out any] struct {
+	bufferSize int
+	run        func(ctx context.Context, source <-chan In, errs chan<- error, wg *sync.WaitGroup) <-chan Out
+}
+
+// NewPipeline creates a new pipeline with no stages.
+func NewPipeline[T any]() *Pipeline[T, T] {
+	return &Pipeline[T, T]{
+		run: func(_ context.Context, source <-chan T, _ chan<- error, _ *sync.WaitGroup) <-chan T {
+			return source
+		},
+	}
+}
+
+// WithBufferSize sets the buffer size of the channels between stages that are added after this call,
+// as well as the error channel. The default is zero, i.e. unbuffered channels.
+func (p *Pipeline[In, Out]) WithBufferSize(size int) *Pipeline[In, Out] {
+	p.bufferSize = size
+	return p
+}
+
+// Then returns a new pipeline that has the given stage added after the stages of the given pipeline.
+// The stage is processed with the given number of workers (at least one).
+func Then[In, T, U any](p *Pipeline[In, T], fn Stage[T, U], workers int) *Pipeline[In, U] {
+	prev, bufferSize := p.run, p.bufferSize
+	return &Pipeline[In, U]{
+		bufferSize: bufferSize,
+		run: func(ctx context.Context, source <-chan In, errs chan<- error, wg *sync.WaitGroup) <-chan U {
+			return runStage(ctx, prev(ctx, source, errs, wg), fn, workers, bufferSize, errs, wg)
+		},
+	}
+}
+
+// Run starts all stages of the pipeline, reading items from the given source channel.
+//
+// Errors from all stages are sent to the returned error channel, and the items that caused them are dropped.
+// Processing continues after errors; cancel the context to stop the pipeline early. Both returned channels are
+// closed after the source channel is closed and all items have been processed, or after the context is canceled.
+// Both channels must be drained, as the stages block until their outputs are read.
+func (p *Pipeline[In, Out]) Run(ctx context.Context, source <-chan In) (<-chan Out, <-chan error) {
+	errs := make(chan error, p.bufferSize)
+	var wg sync.WaitGroup
+	out := p.run(ctx, source, errs, &wg)
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	return out, errs
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/util/pipeline"
+)
+
+func sendAll[T any](items ...T) <-chan T {
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		for _, item := range items {
+			ch <- item
+		}
+	}()
+	return ch
+}
+
+func drain[T any](out <-chan T, errs <-chan error) (items []T, errList []error) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for err := range errs {
+			errList = append(errList, err)
+		}
+	}()
+	for item := range out {
+		items = append(items, item)
+	}
+	wg.Wait()
+	return
+}
+
+func TestChain(t *testing.T) {
+	out, errs := pipeline.Chain(sendAll("1", "2", "x", "4"), strconv.Atoi, 2)
+	items, errList := drain(out, errs)
+	slices.Sort(items)
+	assert.Equal(t, []int{1, 2, 4}, items)
+	assert.Len(t, errList, 1)
+}
+
+func TestPipeline(t *testing.T) {
+	p := pipeline.NewPipeline[string]().WithBufferSize(4)
+	parsed := pipeline.Then(p, strconv.Atoi, 3)
+	doubled := pipeline.Then(parsed, func(i int) (int, error) {
+		if i < 0 {
+			return 0, fmt.Errorf("negative number %d", i)
+		}
+		return i * 2, nil
+	}, 2)
+	formatted := pipeline.Then(doubled, func(i int) (string, error) {
+		return fmt.Sprintf("<%d>", i), nil
+	}, 1)
+	items, errList := drain(formatted.Run(context.Background(), sendAll("1", "-2", "3", "foo", "5")))
+	slices.Sort(items)
+	assert.Equal(t, []string{"<10>", "<2>", "<6>"}, items)
+	assert.Len(t, errList, 2)
+}
+
+func TestPipeline_NoStages(t *testing.T) {
+	items, errList := drain(pipeline.NewPipeline[int]().Run(context.Background(), sendAll(1, 2, 3)))
+	assert.Equal(t, []int{1, 2, 3}, items)
+	assert.Empty(t, errList)
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int)
+	p := pipeline.Then(pipeline.NewPipeline[int](), func(i int) (int, error) {
+		return i, nil
+	}, 2)
+	out, errs := p.Run(ctx, source)
+	source <- 1
+	assert.Equal(t, 1, <-out)
+	// The source is never closed, so the pipeline only stops because of the cancellation
+	cancel()
+	items, errList := drain(out, errs)
+	assert.Empty(t, items)
+	assert.Empty(t, errList)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ratelimit contains rate limiters for outbound requests.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter is the common interface implemented by all rate limiters in this package.
+type Limiter interface {
+	// Allow checks if an event may happen now and consumes a slot if so. It never blocks.
+	Allow() bool
+	// Wait blocks until an event may happen or the context is canceled.
+	Wait(ctx context.Context) error
+	// Reserve reserves a slot for an event in the future. The caller must wait for the reservation's delay
+	// before acting, or cancel the reservation if it decides not to act.
+	Reserve() *Reservation
+}
+
+var ErrWouldExceedDeadline = errors.New("ratelimit: wait would exceed context deadline")
+
+// Reservation is a slot reserved using Limiter.Reserve.
+type Reservation struct {
+	timeToAct time.Time
+	now       func() time.Time
+	cancel    func()
+	canceled  atomic.Bool
+}
+
+// Delay returns how long the caller must wait before acting. Zero means the caller can act immediately.
+func (r *Reservation) Delay() time.Duration {
+	return max(r.timeToAct.Sub(r.now()), 0)
+}
+
+// Cancel returns the reserved slot to the limiter if the reservation time hasn't passed yet.
+// Calling Cancel multiple times has no effect.
+func (r *Reservation) Cancel() {
+	if r.canceled.CompareAndSwap(false, true) && r.Delay() > 0 {
+		r.cancel()
+	}
+}
+
+func waitReservation(ctx context.Context, r *Reservation) error {
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	} else if deadline, ok := ctx.Deadline(); ok && deadline.Before(r.timeToAct) {
+		r.Cancel()
+		return ErrWouldExceedDeadline
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	fc.now = fc.now.Add(d)
+	fc.lock.Unlock()
+}
+
+func countAllowed(l Limiter, attempts int) (allowed int) {
+	for i := 0; i < attempts; i++ {
+		if l.Allow() {
+			allowed++
+		}
+	}
+	return
+}
+
+func TestTokenBucket_Allow(t *testing.T) {
+	clock := newFakeClock()
+	tb := NewTokenBucket(10, 5)
+	tb.now = clock.Now
+	assert.Equal(t, 5, countAllowed(tb, 10))
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, 1, countAllowed(tb, 10))
+	clock.Advance(250 * time.Millisecond)
+	assert.Equal(t, 2, countAllowed(tb, 10))
+	clock.Advance(time.Hour)
+	assert.Equal(t, 5, countAllowed(tb, 10))
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	clock := newFakeClock()
+	tb := NewTokenBucket(10, 2)
+	tb.now = clock.Now
+	assert.Zero(t, tb.Reserve().Delay())
+	assert.Zero(t, tb.Reserve().Delay())
+	r1 := tb.Reserve()
+	assert.Equal(t, 100*time.Millisecond, r1.Delay())
+	r2 := tb.Reserve()
+	assert.Equal(t, 200*time.Millisecond, r2.Delay())
+	assert.False(t, tb.Allow())
+
+	r2.Cancel()
+	r2.Cancel()
+	// The slot of the canceled reservation is reused instead of waiting 300ms
+	assert.Equal(t, 200*time.Millisecond, tb.Reserve().Delay())
+
+	clock.Advance(time.Second)
+	// Canceling after the reservation time has passed doesn't return the token
+	r1.Cancel()
+	assert.Equal(t, 2, countAllowed(tb, 5))
+}
+
+func TestSlidingWindow_Allow(t *testing.T) {
+	clock := newFakeClock()
+	sw := NewSlidingWindow(3, time.Minute)
+	sw.now = clock.Now
+	assert.Equal(t, 2, countAllowed(sw, 2))
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 1, countAllowed(sw, 5))
+	// The first two events leave the window after a minute, the third one 30 seconds later
+	clock.Advance(30*time.Second - time.Nanosecond)
+	assert.Equal(t, 0, countAllowed(sw, 5))
+	clock.Advance(time.Nanosecond)
+	assert.Equal(t, 2, countAllowed(sw, 5))
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 1, countAllowed(sw, 5))
+}
+
+func TestSlidingWindow_Reserve(t *testing.T) {
+	clock := newFakeClock()
+	sw := NewSlidingWindow(2, time.Minute)
+	sw.now = clock.Now
+	assert.Zero(t, sw.Reserve().Delay())
+	clock.Advance(10 * time.Second)
+	assert.Zero(t, sw.Reserve().Delay())
+	r1 := sw.Reserve()
+	assert.Equal(t, 50*time.Second, r1.Delay())
+	r2 := sw.Reserve()
+	assert.Equal(t, 60*time.Second, r2.Delay())
+	r2.Cancel()
+	assert.Equal(t, 60*time.Second, sw.Reserve().Delay())
+	assert.False(t, sw.Allow())
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	for name, limiter := range map[string]Limiter{
+		"TokenBucket":   NewTokenBucket(100, 1),
+		"SlidingWindow": NewSlidingWindow(1, 10*time.Millisecond),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				assert.NoError(t, limiter.Wait(ctx))
+			}
+			assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+			canceledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			assert.ErrorIs(t, limiter.Wait(canceledCtx), context.Canceled)
+
+			deadlineCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+			defer cancel()
+			assert.ErrorIs(t, limiter.Wait(deadlineCtx), ErrWouldExceedDeadline)
+
+			// Canceled waits must return their slots
+			time.Sleep(15 * time.Millisecond)
+			assert.True(t, limiter.Allow())
+		})
+	}
+}
+
+func TestTokenBucket_Concurrent(t *testing.T) {
+	tb := NewTokenBucket(0.001, 50)
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if tb.Allow() {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(50), allowed.Load())
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ratelimit
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SlidingWindow is a rate limiter that allows at most limit events within any period of the given window length.
+//
+// Unlike TokenBucket, this doesn't spread events out evenly, which matches APIs that document their limits as
+// e.g. "60 requests per minute" and count requests over the past minute. It stores the times of the last limit
+// events, so memory usage is proportional to the limit.
+type SlidingWindow struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	lock sync.Mutex
+	// events contains the times of events (including reserved future events) within the window in order.
+	events []time.Time
+}
+
+var _ Limiter = (*SlidingWindow)(nil)
+
+// NewSlidingWindow creates a new sliding window rate limiter that allows limit events per window.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	if limit < 1 {
+		panic("ratelimit: limit cannot be less than 1")
+	} else if window <= 0 {
+		panic("ratelimit: window must be positive")
+	}
+	return &SlidingWindow{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		events: make([]time.Time, 0, limit),
+	}
+}
+
+// nextSlot returns the earliest time when a new event can happen. The lock must be held.
+func (sw *SlidingWindow) nextSlot(now time.Time) time.Time {
+	cutoff := now.Add(-sw.window)
+	expired := 0
+	for expired < len(sw.events) && !sw.events[expired].After(cutoff) {
+		expired++
+	}
+	sw.events = slices.Delete(sw.events, 0, expired)
+	if len(sw.events) < sw.limit {
+		return now
+	}
+	// The new event can happen once the event limit events ago has left the window. Reserved events may be
+	// in the future, so the new event is also kept after the last one to keep the list sorted.
+	slot := sw.events[len(sw.events)-sw.limit].Add(sw.window)
+	if last := sw.events[len(sw.events)-1]; last.After(slot) {
+		slot = last
+	}
+	if now.After(slot) {
+		slot = now
+	}
+	return slot
+}
+
+func (sw *SlidingWindow) Allow() bool {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	now := sw.now()
+	if sw.nextSlot(now).After(now) {
+		return false
+	}
+	sw.events = append(sw.events, now)
+	return true
+}
+
+func (sw *SlidingWindow) Reserve() *Reservation {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	timeToAct := sw.nextSlot(sw.now())
+	sw.events = append(sw.events, timeToAct)
+	return &Reservation{
+		timeToAct: timeToAct,
+		now:       sw.now,
+		cancel: func() {
+			sw.remove(timeToAct)
+		},
+	}
+}
+
+func (sw *SlidingWindow) remove(eventTime time.Time) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if idx := slices.IndexFunc(sw.events, eventTime.Equal); idx >= 0 {
+		sw.events = slices.Delete(sw.events, idx, idx+1)
+	}
+}
+
+func (sw *SlidingWindow) Wait(ctx context.Context) error {
+	return waitReservation(ctx, sw.Reserve())
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// TokenBucket is a lock-free token bucket rate limiter.
+//
+// Instead of storing the number of tokens and the last refill time separately, which couldn't be updated atomically
+// together, it uses the generic cell rate algorithm: the state is a single timestamp of when the bucket would be full
+// again, so every operation is one compare-and-swap. The behavior is the same as a token bucket that refills at
+// the given rate and holds at most burst tokens.
+type TokenBucket struct {
+	interval  int64
+	tolerance int64
+	// fullAt is the Unix nanosecond timestamp at which all tokens have been refilled.
+	fullAt atomic.Int64
+	now    func() time.Time
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+// NewTokenBucket creates a new token bucket that allows rate events per second on average and bursts of up to
+// burst events. The bucket starts full.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		panic("ratelimit: rate must be positive")
+	} else if burst < 1 {
+		panic("ratelimit: burst cannot be less than 1")
+	}
+	interval := int64(float64(time.Second) / rate)
+	return &TokenBucket{
+		interval:  interval,
+		tolerance: interval * int64(burst),
+		now:       time.Now,
+	}
+}
+
+// take consumes one token and returns the time when the caller can act.
+// If maxDelay is non-negative and the caller would have to wait longer than that, no token is consumed.
+func (tb *TokenBucket) take(maxDelay int64) (now, timeToAct int64, ok bool) {
+	for {
+		now = tb.now().UnixNano()
+		fullAt := tb.fullAt.Load()
+		newFullAt := max(fullAt, now) + tb.interval
+		timeToAct = newFullAt - tb.tolerance
+		if maxDelay >= 0 && timeToAct-now > maxDelay {
+			return now, timeToAct, false
+		} else if tb.fullAt.CompareAndSwap(fullAt, newFullAt) {
+			return now, timeToAct, true
+		}
+	}
+}
+
+func (tb *TokenBucket) Allow() bool {
+	_, _, ok := tb.take(0)
+	return ok
+}
+
+func (tb *TokenBucket) Reserve() *Reservation {
+	_, timeToAct, _ := tb.take(-1)
+	return &Reservation{
+		timeToAct: time.Unix(0, timeToAct),
+		now:       tb.now,
+		cancel:    tb.giveBack,
+	}
+}
+
+func (tb *TokenBucket) giveBack() {
+	for {
+		fullAt := tb.fullAt.Load()
+		if tb.fullAt.CompareAndSwap(fullAt, max(fullAt-tb.interval, tb.now().UnixNano())) {
+			return
+		}
+	}
+}
+
+func (tb *TokenBucket) Wait(ctx context.Context) error {
+	return waitReservation(ctx, tb.Reserve())
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package result contains a generic type for values that may have failed to be computed.
+//
+// It's mostly meant for passing errors through slice transforms and pipelines, where the normal
+// multi-return error idiom can't be used. Direct callers should keep using (T, error) return values.
+package result
+
+import (
+	"fmt"
+)
+
+// Result is either a successful value (Ok) or an error (Err).
+//
+// The zero value is an Ok result containing the zero value of T.
+type Result[T any, E error] struct {
+	value T
+	err   E
+	isErr bool
+}
+
+// Ok returns a successful result containing the given value.
+func Ok[T any](value T) Result[T, error] {
+	return Result[T, error]{value: value}
+}
+
+// Err returns a failed result containing the given error. The error should not be nil.
+func Err[T any](err error) Result[T, error] {
+	return Result[T, error]{err: err, isErr: true}
+}
+
+// From converts a normal (T, error) return value pair into a result.
+// If the error is nil, the result is Ok, otherwise it's Err and the value is discarded.
+func From[T any](value T, err error) Result[T, error] {
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(value)
+}
+
+// IsOk returns true if the result contains a value rather than an error.
+func (res Result[T, E]) IsOk() bool {
+	return !res.isErr
+}
+
+// Get returns the value and error as a normal (T, error) pair.
+// The value is the zero value of T if the result is an error.
+func (res Result[T, E]) Get() (T, error) {
+	if res.isErr {
+		var zero T
+		return zero, res.err
+	}
+	return res.value, nil
+}
+
+// Unwrap returns the value in the result.
+//
+// This panics if the result is an error, so it should only be used after checking IsOk,
+// or when an error is a programming mistake.
+func (res Result[T, E]) Unwrap() T {
+	if res.isErr {
+		panic(fmt.Errorf("result: called Unwrap on an Err result: %w", res.err))
+	}
+	return res.value
+}
+
+// UnwrapErr returns the error in the result, or nil if the result is Ok.
+func (res Result[T, E]) UnwrapErr() error {
+	if !res.isErr {
+		return nil
+	}
+	return res.err
+}
+
+// Map converts the value inside the result with the given function if the result is Ok.
+// Errors are passed through as-is without calling the function.
+//
+// This is a function rather than a method, because Go methods can't have type parameters.
+func Map[T, U any](res Result[T, error], fn func(T) U) Result[U, error] {
+	if res.isErr {
+		return Err[U](res.err)
+	}
+	return Ok(fn(res.value))
+}
+
+// Collect converts a slice of results into a slice of values.
+//
+// If any of the results is an error, the first error is returned and the rest of the slice isn't checked.
+func Collect[T any](results []Result[T, error]) ([]T, error) {
+	values := make([]T, len(results))
+	for i, res := range results {
+		if res.isErr {
+			return nil, res.err
+		}
+		values[i] = res.value
+	}
+	return values, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package result_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/result"
+)
+
+var errTest = errors.New("test error")
+
+func TestResult(t *testing.T) {
+	ok := result.Ok(5)
+	assert.True(t, ok.IsOk())
+	assert.Equal(t, 5, ok.Unwrap())
+	assert.NoError(t, ok.UnwrapErr())
+	val, err := ok.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, val)
+
+	failed := result.Err[int](errTest)
+	assert.False(t, failed.IsOk())
+	assert.ErrorIs(t, failed.UnwrapErr(), errTest)
+	val, err = failed.Get()
+	assert.ErrorIs(t, err, errTest)
+	assert.Zero(t, val)
+
+	var zero result.Result[int, error]
+	assert.True(t, zero.IsOk())
+	assert.Zero(t, zero.Unwrap())
+}
+
+func TestResult_UnwrapPanics(t *testing.T) {
+	assert.PanicsWithError(t, "result: called Unwrap on an Err result: test error", func() {
+		result.Err[int](errTest).Unwrap()
+	})
+}
+
+func TestFrom(t *testing.T) {
+	assert.Equal(t, result.Ok(123), result.From(strconv.Atoi("123")))
+	res := result.From(strconv.Atoi("abc"))
+	assert.False(t, res.IsOk())
+	assert.ErrorIs(t, res.UnwrapErr(), strconv.ErrSyntax)
+}
+
+func TestMap(t *testing.T) {
+	double := func(i int) int { return i * 2 }
+	assert.Equal(t, result.Ok(10), result.Map(result.Ok(5), double))
+	res := result.Map(result.Err[int](errTest), func(i int) int {
+		t.Fatal("map function shouldn't be called for errors")
+		return i
+	})
+	assert.ErrorIs(t, res.UnwrapErr(), errTest)
+}
+
+func TestCollect(t *testing.T) {
+	values, err := result.Collect([]result.Result[int, error]{result.Ok(1), result.Ok(2), result.Ok(3)})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, values)
+
+	otherErr := errors.New("other error")
+	values, err = result.Collect([]result.Result[int, error]{result.Ok(1), result.Err[int](errTest), result.Err[int](otherErr)})
+	assert.ErrorIs(t, err, errTest)
+	assert.Nil(t, values)
+
+	values, err = result.Collect[int](nil)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
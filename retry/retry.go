@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package retry contains helpers for retrying failed operations with exponential backoff.
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"go.mau.fi/util/exerrors"
+)
+
+type config struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+	retryOn      func(error) bool
+}
+
+func defaultConfig() config {
+	return config{
+		maxAttempts:  5,
+		initialDelay: 100 * time.Millisecond,
+		maxDelay:     10 * time.Second,
+		multiplier:   2,
+	}
+}
+
+// Option configures the retry behavior of Do and DoCtx.
+type Option func(*config)
+
+// MaxAttempts sets the maximum number of attempts including the first one. Defaults to 5.
+// Zero or negative values mean there's no limit, in which case only the context can stop retrying.
+func MaxAttempts(n int) Option {
+	return func(cfg *config) {
+		cfg.maxAttempts = n
+	}
+}
+
+// InitialDelay sets the delay before the first retry. Defaults to 100ms.
+func InitialDelay(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.initialDelay = d
+	}
+}
+
+// MaxDelay sets the maximum delay between attempts. Defaults to 10 seconds.
+func MaxDelay(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxDelay = d
+	}
+}
+
+// Multiplier sets the factor that the delay is multiplied by after each retry. Defaults to 2.
+// A multiplier of 1 makes the delay constant.
+func Multiplier(f float64) Option {
+	return func(cfg *config) {
+		cfg.multiplier = f
+	}
+}
+
+// WithJitter randomizes each delay by up to the given fraction in either direction, e.g. 0.2 makes
+// the delay vary between 80% and 120% of the base delay. The fraction is clamped between 0 and 1.
+// Jitter is disabled by default.
+func WithJitter(fraction float64) Option {
+	return func(cfg *config) {
+		cfg.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// RetryOn sets the function that decides whether an error should be retried.
+// By default, all errors are retried.
+func RetryOn(fn func(error) bool) Option {
+	return func(cfg *config) {
+		cfg.retryOn = fn
+	}
+}
+
+// delay returns the delay before the given retry (starting from 1).
+func (cfg *config) delay(retry int) time.Duration {
+	delay := float64(cfg.initialDelay) * math.Pow(cfg.multiplier, float64(retry-1))
+	if cfg.jitter > 0 {
+		delay *= 1 - cfg.jitter + rand.Float64()*2*cfg.jitter
+	}
+	if delay > float64(cfg.maxDelay) {
+		return cfg.maxDelay
+	}
+	return time.Duration(delay)
+}
+
+type attemptContextKey struct{}
+
+// Attempt returns the number of the current attempt (starting from 1) inside a function called by DoCtx.
+// If the context didn't come from DoCtx, this returns 0.
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptContextKey{}).(int)
+	return attempt
+}
+
+// Do calls the given function until it succeeds, the maximum number of attempts is reached,
+// the error isn't retryable according to RetryOn, or the context is canceled.
+//
+// If all attempts fail, the error from the last attempt is returned. If the context is canceled while waiting
+// for the next attempt, the returned error will match both the context error and the last error from the function.
+func Do(ctx context.Context, fn func() error, opts ...Option) error {
+	return DoCtx(ctx, func(context.Context) error {
+		return fn()
+	}, opts...)
+}
+
+// DoCtx is like Do, but passes a context to the function. The number of the current attempt can be read
+// from the context using Attempt, e.g. for logging.
+func DoCtx(ctx context.Context, fn func(ctx context.Context) error, opts ...Option) error {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(context.WithValue(ctx, attemptContextKey{}, attempt))
+		if err == nil || (cfg.maxAttempts > 0 && attempt >= cfg.maxAttempts) || (cfg.retryOn != nil && !cfg.retryOn(err)) {
+			return err
+		}
+		timer := time.NewTimer(cfg.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return exerrors.NewDualError(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryN calls the given function up to n times with the default backoff settings until it succeeds.
+func RetryN(n int, fn func() error) error {
+	return Do(context.Background(), fn, MaxAttempts(n))
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errTemporary = errors.New("temporary error")
+	errPermanent = errors.New("permanent error")
+)
+
+func TestConfig_Delay(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Equal(t, 100*time.Millisecond, cfg.delay(1))
+	assert.Equal(t, 200*time.Millisecond, cfg.delay(2))
+	assert.Equal(t, 800*time.Millisecond, cfg.delay(4))
+	assert.Equal(t, 10*time.Second, cfg.delay(10))
+	assert.Equal(t, 10*time.Second, cfg.delay(5000))
+
+	MaxDelay(time.Minute)(&cfg)
+	Multiplier(1.5)(&cfg)
+	assert.Equal(t, 225*time.Millisecond, cfg.delay(3))
+
+	WithJitter(0.5)(&cfg)
+	for i := 0; i < 100; i++ {
+		delay := cfg.delay(1)
+		assert.GreaterOrEqual(t, delay, 50*time.Millisecond)
+		assert.LessOrEqual(t, delay, 150*time.Millisecond)
+	}
+}
+
+func TestDo(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errTemporary
+		}
+		return nil
+	}, InitialDelay(time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_MaxAttempts(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), func() error {
+		calls++
+		return errTemporary
+	}, MaxAttempts(4), InitialDelay(time.Millisecond))
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 4, calls)
+}
+
+func TestDo_RetryOn(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), func() error {
+		calls++
+		if calls == 2 {
+			return errPermanent
+		}
+		return errTemporary
+	}, InitialDelay(time.Millisecond), RetryOn(func(err error) bool {
+		return errors.Is(err, errTemporary)
+	}))
+	assert.ErrorIs(t, err, errPermanent)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDo_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := Do(ctx, func() error {
+		calls++
+		return errTemporary
+	}, MaxAttempts(0), InitialDelay(time.Hour))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoCtx_Attempt(t *testing.T) {
+	assert.Equal(t, 0, Attempt(context.Background()))
+	var attempts []int
+	err := DoCtx(context.Background(), func(ctx context.Context) error {
+		attempts = append(attempts, Attempt(ctx))
+		return errTemporary
+	}, MaxAttempts(3), InitialDelay(time.Millisecond))
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+}
+
+func TestRetryN(t *testing.T) {
+	calls := 0
+	err := RetryN(1, func() error {
+		calls++
+		return errTemporary
+	})
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 1, calls)
+}
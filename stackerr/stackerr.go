@@ -0,0 +1,110 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package stackerr contains an error wrapper that records the stack trace of where the error was created.
+package stackerr
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+const maxDepth = 32
+
+var disabled atomic.Bool
+
+// SetEnabled sets whether New and Wrap capture stack traces. Capturing is enabled by default.
+//
+// When disabled, New is equivalent to errors.New and Wrap returns the error as-is,
+// which avoids the overhead of capturing stack traces in hot paths.
+func SetEnabled(enabled bool) {
+	disabled.Store(!enabled)
+}
+
+// StackTraceError is an error with the stack trace of where it was created.
+type StackTraceError struct {
+	err   error
+	stack []uintptr
+}
+
+func capture(err error) error {
+	stack := make([]uintptr, maxDepth)
+	// Skip runtime.Callers, capture and the exported function that called it
+	stack = stack[:runtime.Callers(3, stack)]
+	return &StackTraceError{err: err, stack: stack}
+}
+
+// New returns an error with the given message and the stack trace of the caller.
+func New(msg string) error {
+	if disabled.Load() {
+		return errors.New(msg)
+	}
+	return capture(errors.New(msg))
+}
+
+// Wrap adds the stack trace of the caller to the given error.
+//
+// If the error is nil, this returns nil. If the error already has a stack trace somewhere in its chain,
+// it's returned as-is, so that the original (deepest) location is preserved.
+func Wrap(err error) error {
+	if err == nil || disabled.Load() {
+		return err
+	}
+	var ste *StackTraceError
+	if errors.As(err, &ste) {
+		return err
+	}
+	return capture(err)
+}
+
+func (ste *StackTraceError) Error() string {
+	return ste.err.Error()
+}
+
+func (ste *StackTraceError) Unwrap() error {
+	return ste.err
+}
+
+// StackTrace returns the program counters of the stack trace that was captured when the error was created.
+func (ste *StackTraceError) StackTrace() []uintptr {
+	return ste.stack
+}
+
+// StackTrace returns the program counters of the first stack trace found in the error chain,
+// or nil if there isn't one.
+func StackTrace(err error) []uintptr {
+	var ste *StackTraceError
+	if errors.As(err, &ste) {
+		return ste.stack
+	}
+	return nil
+}
+
+// Format returns the error message followed by the stack trace (if there is one) in the same format as panics,
+// i.e. the function name on one line and the file and line number indented on the next line.
+func Format(err error) string {
+	if err == nil {
+		return ""
+	}
+	stack := StackTrace(err)
+	if len(stack) == 0 {
+		return err.Error()
+	}
+	var buf strings.Builder
+	buf.WriteString(err.Error())
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		_, _ = fmt.Fprintf(&buf, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
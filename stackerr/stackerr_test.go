@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package stackerr_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/stackerr"
+)
+
+func failingFunction() error {
+	return stackerr.Wrap(&fs.PathError{Op: "open", Path: "/tmp/x", Err: fs.ErrNotExist})
+}
+
+func TestWrap(t *testing.T) {
+	assert.NoError(t, stackerr.Wrap(nil))
+	err := fmt.Errorf("failed to load config: %w", failingFunction())
+	assert.Equal(t, "failed to load config: open /tmp/x: file does not exist", err.Error())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	var pathErr *fs.PathError
+	require.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, "/tmp/x", pathErr.Path)
+
+	stack := stackerr.StackTrace(err)
+	require.NotEmpty(t, stack)
+	frame, _ := runtime.CallersFrames(stack).Next()
+	assert.Equal(t, "go.mau.fi/util/stackerr_test.failingFunction", frame.Function)
+
+	// Wrapping again keeps the original stack trace
+	wrapped := stackerr.Wrap(err)
+	assert.Same(t, err, wrapped)
+}
+
+func TestNew(t *testing.T) {
+	err := stackerr.New("something broke")
+	assert.Equal(t, "something broke", err.Error())
+	formatted := stackerr.Format(err)
+	lines := strings.Split(formatted, "\n")
+	require.GreaterOrEqual(t, len(lines), 3)
+	assert.Equal(t, "something broke", lines[0])
+	assert.Equal(t, "go.mau.fi/util/stackerr_test.TestNew", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "\t"))
+	assert.Contains(t, lines[2], "stackerr_test.go:")
+}
+
+func TestFormat_NoStack(t *testing.T) {
+	assert.Equal(t, "", stackerr.Format(nil))
+	assert.Equal(t, "plain", stackerr.Format(errors.New("plain")))
+	assert.Nil(t, stackerr.StackTrace(errors.New("plain")))
+}
+
+func TestSetEnabled(t *testing.T) {
+	stackerr.SetEnabled(false)
+	defer stackerr.SetEnabled(true)
+	plain := errors.New("plain")
+	assert.Same(t, plain, stackerr.Wrap(plain))
+	assert.Nil(t, stackerr.StackTrace(stackerr.New("no stack")))
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/transform"
+)
+
+var oldVariationReplacer = sync.OnceValue(func() *strings.Replacer {
+	var emojisWithVariations []string
+	err := json.Unmarshal(emojisWithVariationsJSON, &emojisWithVariations)
+	if err != nil {
+		panic(err)
+	}
+	replaceInput := make([]string, 2*len(emojisWithVariations))
+	for i, emoji := range emojisWithVariations {
+		replaceInput[i*2] = emoji
+		replaceInput[(i*2)+1] = emoji + VS16
+	}
+	return strings.NewReplacer(replaceInput...)
+})
+
+var oldSkinToneReplacer = strings.NewReplacer(
+	"\ufe0f\U0001F3FB", "\U0001F3FB",
+	"\ufe0f\U0001F3FC", "\U0001F3FC",
+	"\ufe0f\U0001F3FD", "\U0001F3FD",
+	"\ufe0f\U0001F3FE", "\U0001F3FE",
+	"\ufe0f\U0001F3FF", "\U0001F3FF",
+)
+
+// addWithReplacer is the old strings.Replacer based implementation of Add,
+// with the exception that keycap bases only get variation selectors before a keycap.
+func addWithReplacer(val string) string {
+	val = oldSkinToneReplacer.Replace(oldVariationReplacer().Replace(RemoveAll(val)))
+	var buf strings.Builder
+	for i := 0; i < len(val); i++ {
+		if isKeycapBase(rune(val[i])) && strings.HasPrefix(val[i+1:], VS16) {
+			buf.WriteByte(val[i])
+			i += len(VS16)
+			if strings.HasPrefix(val[i+1:], "\u20e3") {
+				buf.WriteString(VS16)
+			}
+			continue
+		}
+		buf.WriteByte(val[i])
+	}
+	return buf.String()
+}
+
+func makeAddTestCorpus(t testing.TB) []string {
+	var emojis []string
+	require.NoError(t, json.Unmarshal(fullyQualifiedEmojisJSON, &emojis))
+	corpus := []string{
+		"",
+		"plain text",
+		"\U0001f44d\U0001f3fd",
+		"\U0001f44d\ufe0f\U0001f3fd",
+		"4\u20e3",
+		"\u263a\ufe0e",
+		"\u263a\ufe0f\ufe0f",
+		"invalid \xff utf-8 \u263a",
+		"123 #hashtag *bold* 4\ufe0f 5\u20e3\ufe0f",
+		"\u263a\xf0\x9f",
+	}
+	for _, emoji := range emojis {
+		corpus = append(corpus, emoji, Remove(emoji), RemoveAll(emoji), "a"+emoji+"b")
+	}
+	return append(corpus, strings.Join(emojis, ""), strings.Join(emojis, " "))
+}
+
+func TestAdd_CompareWithReplacer(t *testing.T) {
+	for _, input := range makeAddTestCorpus(t) {
+		assert.Equal(t, addWithReplacer(input), Add(input), "Add(%+q)", input)
+	}
+}
+
+func FuzzAdd_CompareWithReplacer(f *testing.F) {
+	for _, input := range makeAddTestCorpus(f)[:200] {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		assert.Equal(t, addWithReplacer(input), Add(input), "Add(%+q)", input)
+	})
+}
+
+func TestRemoveQualified_CompareWithRemove(t *testing.T) {
+	for _, input := range makeAddTestCorpus(t) {
+		assert.Equal(t, Remove(input), RemoveQualified(input), "RemoveQualified(%+q)", input)
+	}
+}
+
+func TestCanonicalize_Idempotent(t *testing.T) {
+	for _, input := range makeAddTestCorpus(t) {
+		for _, opts := range []CanonicalOptions{{}, {StripSkinTone: true}} {
+			canonical := CanonicalizeWithOptions(input, opts)
+			assert.Equal(t, canonical, CanonicalizeWithOptions(canonical, opts), "CanonicalizeWithOptions(%+q, %+v)", input, opts)
+			assert.Equal(t, canonical, CanonicalizeWithOptions(Add(input), opts), "CanonicalizeWithOptions(Add(%+q), %+v)", input, opts)
+			assert.Equal(t, canonical, CanonicalizeWithOptions(AddTextPresentation(input), opts), "CanonicalizeWithOptions(AddTextPresentation(%+q), %+v)", input, opts)
+		}
+	}
+}
+
+func TestAddTextPresentation_Toggle(t *testing.T) {
+	for _, input := range makeAddTestCorpus(t) {
+		emoji, text := Add(input), AddTextPresentation(input)
+		assert.Equal(t, emoji, Add(text), "Add(AddTextPresentation(%+q))", input)
+		assert.Equal(t, text, AddTextPresentation(emoji), "AddTextPresentation(Add(%+q))", input)
+	}
+}
+
+var oldSkinToneQualifier = strings.NewReplacer(
+	VS16+"\U0001F3FB", "\U0001F3FB",
+	VS16+"\U0001F3FC", "\U0001F3FC",
+	VS16+"\U0001F3FD", "\U0001F3FD",
+	VS16+"\U0001F3FE", "\U0001F3FE",
+	VS16+"\U0001F3FF", "\U0001F3FF",
+)
+
+var oldQualifiers sync.Map
+
+// fullyQualifyWithReplacer is the old strings.Replacer based implementation of FullyQualifyUpToVersion.
+func fullyQualifyWithReplacer(val string, maxVersion EmojiVersion) string {
+	qualifier, ok := oldQualifiers.Load(maxVersion)
+	if !ok {
+		data := getData()
+		var replaceInput []string
+		for _, emoji := range data.fullyQualifiedEmojis {
+			if strings.Contains(emoji, VS16) && data.versions[emoji] <= maxVersion {
+				replaceInput = append(replaceInput, strings.ReplaceAll(emoji, VS16, ""), emoji)
+			}
+		}
+		qualifier, _ = oldQualifiers.LoadOrStore(maxVersion, strings.NewReplacer(replaceInput...))
+	}
+	return oldSkinToneQualifier.Replace(qualifier.(*strings.Replacer).Replace(RemoveQualified(val)))
+}
+
+func TestFullyQualify_CompareWithReplacer(t *testing.T) {
+	prefix := []byte("prefix \u263a")
+	for _, input := range makeAddTestCorpus(t) {
+		expected := fullyQualifyWithReplacer(input, latestEmojiVersion)
+		assert.Equal(t, expected, FullyQualify(input), "FullyQualify(%+q)", input)
+		assert.Equal(t, string(prefix)+expected, string(AppendFullyQualify(slices.Clip(prefix), input)), "AppendFullyQualify(%+q)", input)
+	}
+	for _, version := range []EmojiVersion{Emoji1_0, Emoji5_0, Emoji12_0} {
+		for _, input := range makeAddTestCorpus(t) {
+			expected := fullyQualifyWithReplacer(input, version)
+			assert.Equal(t, expected, FullyQualifyUpToVersion(input, version), "FullyQualifyUpToVersion(%+q, %s)", input, version)
+		}
+	}
+}
+
+func FuzzFullyQualify_CompareWithReplacer(f *testing.F) {
+	for _, input := range makeAddTestCorpus(f)[:200] {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		assert.Equal(t, fullyQualifyWithReplacer(input, latestEmojiVersion), FullyQualify(input), "FullyQualify(%+q)", input)
+	})
+}
+
+// transformChunked runs the transformer manually with the given source and destination buffer sizes.
+// The buffers are grown if the transformer can't make progress with them, like transform.Reader does.
+func transformChunked(t *testing.T, tr transform.Transformer, input string, srcSize, dstSize int) string {
+	var out []byte
+	for start := 0; ; {
+		end := min(start+srcSize, len(input))
+		dst := make([]byte, dstSize)
+		nDst, nSrc, err := tr.Transform(dst, []byte(input[start:end]), end == len(input))
+		out = append(out, dst[:nDst]...)
+		start += nSrc
+		switch {
+		case err == nil && end == len(input):
+			return string(out)
+		case errors.Is(err, transform.ErrShortDst) && nDst == 0:
+			dstSize++
+		case errors.Is(err, transform.ErrShortSrc) && nSrc == 0:
+			srcSize++
+		case err != nil && !errors.Is(err, transform.ErrShortDst) && !errors.Is(err, transform.ErrShortSrc):
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestTransformers_CompareWithString(t *testing.T) {
+	for _, input := range makeAddTestCorpus(t) {
+		if len(input) > 1000 {
+			continue
+		}
+		for _, size := range []int{4, 7, 64} {
+			assert.Equal(t, Add(input), transformChunked(t, AddTransformer(), input, size, size), "AddTransformer(%+q) with buffer size %d", input, size)
+			assert.Equal(t, Remove(input), transformChunked(t, RemoveTransformer(), input, size, size), "RemoveTransformer(%+q) with buffer size %d", input, size)
+		}
+	}
+}
+
+func TestTransformers_Reader(t *testing.T) {
+	var emojis []string
+	require.NoError(t, json.Unmarshal(fullyQualifiedEmojisJSON, &emojis))
+	input := "text " + strings.Join(emojis, " ") + " 1\u20e3"
+	for name, tc := range map[string]struct {
+		transformer transform.Transformer
+		expected    string
+	}{
+		"Add":    {AddTransformer(), Add(input)},
+		"Remove": {RemoveTransformer(), Remove(input)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			output, err := io.ReadAll(transform.NewReader(iotest.OneByteReader(strings.NewReader(input)), tc.transformer))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(output))
+		})
+	}
+}
+
+func benchmarkAdd(b *testing.B, input string) {
+	b.Run("Replacer", func(b *testing.B) {
+		oldVariationReplacer()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			addWithReplacer(input)
+		}
+	})
+	b.Run("RuneWalk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Add(input)
+		}
+	})
+}
+
+func BenchmarkAdd(b *testing.B) {
+	var emojis []string
+	require.NoError(b, json.Unmarshal(fullyQualifiedEmojisJSON, &emojis))
+	b.Run("Short", func(b *testing.B) {
+		benchmarkAdd(b, "hello \u263a")
+	})
+	b.Run("LongPlainText", func(b *testing.B) {
+		benchmarkAdd(b, strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 50))
+	})
+	b.Run("EmojiDense", func(b *testing.B) {
+		benchmarkAdd(b, strings.Join(emojis[:500], ""))
+	})
+}
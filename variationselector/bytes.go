@@ -0,0 +1,55 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+var vs16Bytes = []byte(VS16)
+
+// bytesAsString returns a string that shares memory with the given byte slice.
+// The string must not be retained after the byte slice is modified.
+func bytesAsString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// AddBytes is equivalent to Add, but operates on a byte slice.
+//
+// If the input doesn't need any changes, the input slice itself is returned. Otherwise, a new slice is allocated
+// and the input is not modified. Invalid UTF-8 is copied as-is, like in Add.
+func AddBytes(b []byte) []byte {
+	buf, changed := appendAdd(nil, getData(), bytesAsString(b), defaultOptions, latestEmojiVersion)
+	if !changed {
+		return b
+	}
+	return buf
+}
+
+// RemoveBytes is equivalent to Remove, but operates on a byte slice.
+//
+// If the input doesn't contain any emoji variation selectors, the input slice itself is returned.
+// Otherwise, a new slice is allocated and the input is not modified.
+func RemoveBytes(b []byte) []byte {
+	if !bytes.Contains(b, vs16Bytes) {
+		return b
+	}
+	return bytes.ReplaceAll(b, vs16Bytes, nil)
+}
+
+// FullyQualifyBytes is equivalent to FullyQualify, but operates on a byte slice.
+//
+// If the input is already fully qualified, the input slice itself is returned.
+// Otherwise, a new slice is allocated and the input is not modified.
+func FullyQualifyBytes(b []byte) []byte {
+	buf, changed := appendFullyQualify(nil, getData(), bytesAsString(b), latestEmojiVersion)
+	if !changed {
+		return b
+	}
+	return buf
+}
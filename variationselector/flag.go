@@ -0,0 +1,103 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const firstRegionalIndicator = 0x1F1E6
+
+// Flag returns the flag emoji for the given two-letter country code, e.g. "US" becomes U+1F1FA U+1F1F8.
+// The code is case-insensitive. If it isn't exactly two ASCII letters, this returns false.
+//
+// The code isn't checked against the list of actual countries, so e.g. "XX" produces a pair of regional
+// indicators that most platforms render as two separate letters.
+func Flag(countryCode string) (string, bool) {
+	if len(countryCode) != 2 {
+		return "", false
+	}
+	var buf [8]byte
+	length := 0
+	for i := 0; i < 2; i++ {
+		char := countryCode[i] | 0x20
+		if char < 'a' || char > 'z' {
+			return "", false
+		}
+		length += utf8.EncodeRune(buf[length:], rune(firstRegionalIndicator+int(char-'a')))
+	}
+	return string(buf[:length]), true
+}
+
+// CountryCode returns the uppercase country code of the given flag emoji, e.g. "US" for U+1F1FA U+1F1F8.
+// If the string is anything other than a single pair of regional indicators, this returns false.
+func CountryCode(flag string) (string, bool) {
+	first, size1 := utf8.DecodeRuneInString(flag)
+	second, size2 := utf8.DecodeRuneInString(flag[size1:])
+	if !isRegionalIndicator(first) || !isRegionalIndicator(second) || size1+size2 != len(flag) {
+		return "", false
+	}
+	return string([]byte{byte('A' + first - firstRegionalIndicator), byte('A' + second - firstRegionalIndicator)}), true
+}
+
+const (
+	blackFlagString = "\U0001F3F4"
+	firstTag        = 0xE0000
+)
+
+// SubdivisionFlag returns the flag emoji for the given Unicode subdivision code, e.g. "gbeng" becomes the flag of
+// England (U+1F3F4 U+E0067 U+E0062 U+E0065 U+E006E U+E0067 U+E007F). The code is case-insensitive.
+//
+// The code must be a two-letter country code followed by 1-4 letters or digits, otherwise this returns false.
+// Like Flag, the code isn't checked against the actual list of subdivisions, and most platforms only have
+// images for England, Scotland and Wales.
+func SubdivisionFlag(code string) (string, bool) {
+	if len(code) < 3 || len(code) > 6 {
+		return "", false
+	}
+	var buf strings.Builder
+	buf.Grow(len(blackFlagString) + (len(code)+1)*utf8.UTFMax)
+	buf.WriteString(blackFlagString)
+	for i := 0; i < len(code); i++ {
+		char := code[i]
+		if char >= 'A' && char <= 'Z' {
+			char |= 0x20
+		}
+		if !(char >= 'a' && char <= 'z') && (i < 2 || !(char >= '0' && char <= '9')) {
+			return "", false
+		}
+		buf.WriteRune(rune(firstTag + int(char)))
+	}
+	buf.WriteRune(cancelTag)
+	return buf.String(), true
+}
+
+// SubdivisionCode returns the lowercase subdivision code of the given flag emoji, e.g. "gbeng" for the flag
+// of England. If the string is anything other than a single valid tag sequence flag, this returns false.
+func SubdivisionCode(flag string) (string, bool) {
+	tags, ok := strings.CutPrefix(flag, blackFlagString)
+	if !ok {
+		return "", false
+	}
+	tags, ok = strings.CutSuffix(tags, string(cancelTag))
+	if !ok {
+		return "", false
+	}
+	code := make([]byte, 0, len(tags)/utf8.UTFMax)
+	for _, char := range tags {
+		if !isTagSpec(char) {
+			return "", false
+		}
+		code = append(code, byte(char-firstTag))
+	}
+	// Round-trip to validate the code
+	if _, ok = SubdivisionFlag(string(code)); !ok || strings.ToLower(string(code)) != string(code) {
+		return "", false
+	}
+	return string(code), true
+}
@@ -1,17 +1,40 @@
 #!/bin/bash
-echo -e "$(
+# Why does this need a \n at the beginning to avoid eating the first emoji?!?!
+echo -e "\n$(
 	curl -s https://www.unicode.org/Public/15.0.0/ucd/emoji/emoji-variation-sequences.txt \
 	| grep FE0F \
 	| awk '{ printf("\\U%8s\n", $1) }' \
 	| sed 's/ /0/g'
 )" | jq -RcM '[inputs]' > emojis-with-variations.json
 
-# Why does this need a \n at the beginning to avoid eating the first emoji?!?!
 echo -e "\n$(
 	curl -s https://unicode.org/Public/emoji/15.0/emoji-test.txt \
 	| grep '; fully-qualified' \
-	| grep FE0F \
 	| sed -E 's/\s+;.*//g' \
 	| awk '{ for (i = 1; i <= NF; i++) {printf("\\U%8s", $i) }; printf("\n") }' \
 	| sed 's/ /0/g'
-)" | jq -RcM '[inputs]' > fully-qualified-variations.json
+)" | jq -RcM '[inputs]' > fully-qualified-emojis.json
+
+echo -e "\n$(
+	curl -s https://unicode.org/Public/emoji/15.0/emoji-test.txt \
+	| grep '; fully-qualified' \
+	| sed -E 's/\s+;[^#]+# \S+ E([0-9.]+) .*/ E\1/g' \
+	| awk '{ for (i = 1; i < NF; i++) {printf("\\U%8s", $i) }; printf("\t%s\n", substr($NF, 2)) }' \
+	| sed 's/ /0/g'
+)" | jq -RcM '[inputs | split("\t") | {version: .[1], emoji: .[0]}] | group_by(.version) | map({key: .[0].version, value: map(.emoji)}) | from_entries' > emoji-versions.json
+
+echo -e "\n$(
+	curl -s https://unicode.org/Public/15.0.0/ucd/emoji/emoji-data.txt \
+	| grep '; Emoji_Modifier_Base' \
+	| sed -E 's/\s+;.*//g' \
+	| while IFS=. read -r start _ end; do
+		for ((i = 16#$start; i <= 16#${end:-$start}; i++)); do printf '\\U%08X\n' "$i"; done
+	done
+)" | jq -RcM '[inputs]' > emoji-modifier-bases.json
+
+echo -e "\n$(
+	curl -s https://unicode.org/Public/emoji/15.0/emoji-test.txt \
+	| grep '; fully-qualified' \
+	| sed -E 's/\s+;[^#]+# \S+ E[0-9.]+ /\t/g' \
+	| awk -F '\t' '{ n = split($1, cps, " "); for (i = 1; i <= n; i++) { cp = cps[i]; while (length(cp) < 8) cp = "0" cp; printf("\\U%s", cp) }; printf("\t%s\n", $2) }'
+)" | jq -RcM '[inputs | split("\t") | {key: .[0], value: .[1]}] | from_entries' > emoji-names.json
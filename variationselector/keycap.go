@@ -0,0 +1,32 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"unicode/utf8"
+)
+
+// Keycap returns the fully-qualified keycap emoji for the given character, e.g. '1' becomes U+0031 U+FE0F U+20E3.
+// Digits, # and * are supported. For other characters, this returns false.
+func Keycap(base rune) (string, bool) {
+	if !isKeycapBase(base) {
+		return "", false
+	}
+	return string([]rune{base, vs16, keycap}), true
+}
+
+// ParseKeycap returns the base character of the given keycap emoji, e.g. '1' for U+0031 U+FE0F U+20E3.
+//
+// Keycaps without a variation selector or with a text presentation selector are also accepted.
+// If the string is anything other than a single keycap sequence, this returns false.
+func ParseKeycap(val string) (rune, bool) {
+	base, _ := utf8.DecodeRuneInString(val)
+	if !isKeycapBase(base) || emojiElementLength(val) != len(val) {
+		return 0, false
+	}
+	return base, true
+}
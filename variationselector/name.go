@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"strings"
+
+	"go.mau.fi/util/exsync"
+)
+
+func (data *unicodeData) setNames(getNames func() map[string]string) {
+	data.names = exsync.NewLazy(getNames)
+	data.emojisByName = exsync.NewLazy(func() map[string]string {
+		names := data.names.Value()
+		emojisByName := make(map[string]string, len(names))
+		for emoji, name := range names {
+			emojisByName[strings.ToLower(name)] = emoji
+		}
+		return emojisByName
+	})
+}
+
+// Name returns the CLDR short name of the given emoji, e.g. "grinning face" for U+1F600,
+// which can be used for things like accessibility labels.
+//
+// Skin tone modifier and zero-width joiner sequences have their own names, e.g. "man astronaut: medium-dark skin tone"
+// for U+1F468 U+1F3FE U+200D U+1F680. The emoji is converted with Canonicalize before looking it up, so the variation
+// selectors in the input don't matter. If the input isn't a single known emoji, this returns false.
+//
+// The names come from the comments in emoji-test.txt, so they're only available in English.
+func Name(emoji string) (string, bool) {
+	data := getData()
+	if data.names == nil {
+		return "", false
+	}
+	name, ok := data.names.Value()[Canonicalize(emoji)]
+	return name, ok
+}
+
+// ByName returns the fully-qualified emoji with the given CLDR short name, e.g. U+1F600 for "grinning face".
+// This is the inverse of Name. The name is matched case-insensitively, but otherwise it must match exactly,
+// e.g. "thumbs up: medium skin tone" and "flag: Finland" are valid names.
+//
+// Only the short names are supported: CLDR keywords (e.g. "smile" or "happy" for U+1F600) aren't included
+// in the data, so looking up emojis by them returns false.
+func ByName(name string) (string, bool) {
+	data := getData()
+	if data.emojisByName == nil {
+		return "", false
+	}
+	emoji, ok := data.emojisByName.Value()[strings.ToLower(name)]
+	return emoji, ok
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+const whiteFlag = '\U0001F3F3'
+
+func isFlag(char rune) bool {
+	return char == whiteFlag || char == blackFlag
+}
+
+// Options specifies which kinds of emojis AddWithOptions adds variation selectors to.
+//
+// Characters that are excluded by the options are left as-is: existing variation selectors after them
+// are neither removed nor added.
+type Options struct {
+	// Keycaps enables adding variation selectors to keycap sequences (e.g. U+0031 U+FE0F U+20E3).
+	Keycaps bool
+	// Flags enables adding variation selectors to flag emojis (e.g. the white flag U+1F3F3),
+	// including all parts of zero-width joiner sequences that start with a flag, like the rainbow flag.
+	// Country flags made of regional indicators never have variation selectors.
+	Flags bool
+	// SkipTextDefault disables adding variation selectors to characters that are displayed as text by default
+	// (e.g. U+263A), so only characters that already default to emoji presentation (e.g. U+231A) get them.
+	SkipTextDefault bool
+}
+
+var defaultOptions = Options{Keycaps: true, Flags: true}
+
+func (opts Options) includes(data *unicodeData, char rune, inFlag bool) bool {
+	if isKeycapBase(char) {
+		return opts.Keycaps
+	} else if inFlag && !opts.Flags {
+		return false
+	} else if _, isTextDefault := data.textDefaultRunes[char]; isTextDefault && opts.SkipTextDefault {
+		return false
+	}
+	return true
+}
+
+// AddWithOptions adds emoji variation selectors to the emojis in the given string like Add,
+// but the options can be used to exclude some kinds of emojis.
+//
+// Add is equivalent to AddWithOptions with Keycaps and Flags enabled and SkipTextDefault disabled.
+func AddWithOptions(val string, opts Options) string {
+	return add(getData(), val, opts, latestEmojiVersion)
+}
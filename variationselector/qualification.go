@@ -0,0 +1,138 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Level is the qualification level of an emoji as defined in Unicode Technical Standard #51.
+type Level int
+
+const (
+	// Unqualified means that the first character of the emoji is missing a required emoji variation selector.
+	Unqualified Level = iota
+	// MinimallyQualified means that the first character of the emoji is qualified, but the rest of it isn't.
+	MinimallyQualified
+	// FullyQualified means that the emoji is in the exact form listed as fully-qualified in emoji-test.txt.
+	FullyQualified
+)
+
+func (level Level) String() string {
+	switch level {
+	case Unqualified:
+		return "unqualified"
+	case MinimallyQualified:
+		return "minimally-qualified"
+	case FullyQualified:
+		return "fully-qualified"
+	default:
+		return ""
+	}
+}
+
+func sequenceQualificationLevel(emoji string) Level {
+	qualified := FullyQualify(RemoveAll(emoji))
+	if emoji == qualified {
+		return FullyQualified
+	}
+	firstChar, size := utf8.DecodeRuneInString(qualified)
+	if strings.HasPrefix(qualified[size:], VS16) && !strings.HasPrefix(emoji, string(firstChar)+VS16) {
+		return Unqualified
+	}
+	return MinimallyQualified
+}
+
+// QualificationLevel returns the qualification level of the emojis in the given string.
+//
+// For a single emoji, the result is the same as the status of the emoji in emoji-test.txt, e.g. U+263A is
+// unqualified, U+1F441 U+FE0F U+200D U+1F5E8 is minimally-qualified and U+1F441 U+FE0F U+200D U+1F5E8 U+FE0F is
+// fully-qualified. This can be used for diagnostics, like finding out which clients send under-qualified emojis.
+//
+// If the string contains multiple emojis, the lowest level is returned.
+// Non-emoji text is ignored, so strings without any emojis are considered fully qualified.
+//
+// Variation selectors that are allowed but not included in the fully-qualified form
+// (e.g. the ones added by Add) also make an emoji not fully qualified.
+func QualificationLevel(val string) Level {
+	level := FullyQualified
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			return level
+		}
+		level = min(level, sequenceQualificationLevel(val[start:end]))
+		if level == Unqualified {
+			return level
+		}
+		val = val[end:]
+	}
+}
+
+func hasVariationSelector(val string) bool {
+	return strings.Contains(val, VS16) || strings.Contains(val, VS15)
+}
+
+func isElementFullyQualified(data *unicodeData, element string) bool {
+	qualified, ok := data.qualifiedForms[element]
+	if !ok {
+		// Emojis that don't have qualified forms in the data set must not have any variation selectors
+		return !hasVariationSelector(element)
+	}
+	return qualified == element
+}
+
+func isSequenceFullyQualified(data *unicodeData, emoji string) bool {
+	if _, ok := data.qualifiedForms[emoji]; ok || !strings.ContainsRune(emoji, zwj) {
+		return isElementFullyQualified(data, emoji)
+	} else if hasVariationSelector(emoji) {
+		// The sequence isn't a fully-qualified form, so it must not be a variant of one either
+		if _, ok = data.qualifiedForms[RemoveAll(emoji)]; ok {
+			return false
+		}
+	}
+	// Sequences that aren't in the data set as a whole are checked one element at a time
+	for {
+		element, rest, found := strings.Cut(emoji, string(zwj))
+		if !isElementFullyQualified(data, element) {
+			return false
+		} else if !found {
+			return true
+		}
+		emoji = rest
+	}
+}
+
+// IsFullyQualified checks if all emojis in the given string are fully qualified.
+//
+// If this returns true, FullyQualify would return the string unchanged, so it can be used to skip reprocessing.
+// It's faster than comparing the output of FullyQualify, as it only looks up the emojis in the fully-qualified
+// data set instead of converting them. Strings without any emojis are considered fully qualified.
+//
+// This is mostly equivalent to checking that QualificationLevel returns FullyQualified. Emojis with text
+// presentation selectors are not fully qualified in either, even though FullyQualify doesn't change them.
+// However, unlike QualificationLevel, this also checks emoji variation selectors outside emojis: selectors that
+// FullyQualify would remove (e.g. duplicate selectors after an emoji) make the string not fully qualified.
+func IsFullyQualified(val string) bool {
+	data := getData()
+	hasStraySelectors := false
+	for rest := val; ; {
+		start, end := nextEmoji(rest)
+		if start < 0 {
+			hasStraySelectors = hasStraySelectors || strings.Contains(rest, VS16)
+			break
+		} else if !isSequenceFullyQualified(data, rest[start:end]) {
+			return false
+		}
+		hasStraySelectors = hasStraySelectors || strings.Contains(rest[:start], VS16)
+		rest = rest[end:]
+	}
+	// Selectors after non-emoji characters are usually kept as-is, but whether FullyQualify removes them depends
+	// on the preceding characters, so fall back to comparing the output in the rare case that there are any.
+	return !hasStraySelectors || fullyQualify(data, val, latestEmojiVersion) == val
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFullyQualified_CompareWithQualificationLevel(t *testing.T) {
+	extra := []string{"\u2764\u200d\U0001f680", "\u2764\ufe0f\u200d\U0001f680", "\U0001f441\u200d\U0001f5e8\ufe0f", "\U0001f441\ufe0f\u200d\U0001f5e8", "\U0001f3f3\u200d\U0001f308\ufe0f"}
+	for _, input := range append(makeAddTestCorpus(t), extra...) {
+		for _, variant := range []string{input, Add(input), AddTextPresentation(input), FullyQualify(input)} {
+			if IsFullyQualified(variant) {
+				assert.Equal(t, FullyQualified, QualificationLevel(variant), "QualificationLevel(%+q)", variant)
+				assert.Equal(t, variant, FullyQualify(variant), "FullyQualify(%+q)", variant)
+			} else if QualificationLevel(variant) == FullyQualified {
+				// QualificationLevel ignores stray selectors outside emojis, which FullyQualify may remove
+				assert.NotEqual(t, variant, FullyQualify(variant), "FullyQualify(%+q)", variant)
+			}
+		}
+	}
+}
+
+func TestIsFullyQualified_StraySelectors(t *testing.T) {
+	assert.False(t, IsFullyQualified("\u263a\ufe0f\ufe0f"))
+	assert.Equal(t, FullyQualified, QualificationLevel("\u263a\ufe0f\ufe0f"))
+	assert.True(t, IsFullyQualified("\u845b\ufe0f \u263a\ufe0f"))
+	assert.True(t, IsFullyQualified("\ufe0f"))
+	// Text presentation sequences aren't fully-qualified emojis, but FullyQualify leaves them as-is
+	assert.False(t, IsFullyQualified("\u25fd\ufe0e"))
+	assert.Equal(t, "\u25fd\ufe0e", FullyQualify("\u25fd\ufe0e"))
+}
+
+func FuzzIsFullyQualified_FullyQualifyIsNoop(f *testing.F) {
+	for _, input := range makeAddTestCorpus(f)[:200] {
+		f.Add(input)
+	}
+	f.Add("\u263a\ufe0f\ufe0f")
+	f.Fuzz(func(t *testing.T, input string) {
+		if IsFullyQualified(input) {
+			assert.Equal(t, input, FullyQualify(input), "FullyQualify(%+q)", input)
+		}
+	})
+}
+
+func BenchmarkIsFullyQualified(b *testing.B) {
+	input := "Lorem ipsum dolor sit amet \U0001f44d\U0001f3fd, consectetur \u263a\ufe0f adipiscing elit \U0001f3f3\ufe0f\u200d\U0001f308."
+	b.Run("QualificationLevel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = QualificationLevel(input) == FullyQualified
+		}
+	})
+	b.Run("Lookup", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			IsFullyQualified(input)
+		}
+	})
+}
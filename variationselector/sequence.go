@@ -0,0 +1,364 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"iter"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	vs15      = '\uFE0E'
+	vs16      = '\uFE0F'
+	zwj       = '\u200D'
+	keycap    = '\u20E3'
+	blackFlag = '\U0001F3F4'
+	cancelTag = '\U000E007F'
+)
+
+func isSkinTone(char rune) bool {
+	return char >= 0x1F3FB && char <= 0x1F3FF
+}
+
+func isKeycapBase(char rune) bool {
+	return (char >= '0' && char <= '9') || char == '#' || char == '*'
+}
+
+func isRegionalIndicator(char rune) bool {
+	return char >= 0x1F1E6 && char <= 0x1F1FF
+}
+
+func isTagSpec(char rune) bool {
+	return char >= 0xE0020 && char <= 0xE007E
+}
+
+// emojiElementLength returns the length in bytes of the single emoji element (i.e. a part of a zero-width joiner
+// sequence) at the start of the given string, or 0 if the string doesn't start with an emoji.
+//
+// Elements are keycap sequences, flags (pairs of regional indicators), tag sequences (subdivision flags)
+// and emoji characters optionally followed by a variation selector and/or a skin tone modifier.
+// A variation selector after the skin tone modifier is also allowed, as some platforms put them there.
+func emojiElementLength(val string) int {
+	char, length := utf8.DecodeRuneInString(val)
+	next, size := utf8.DecodeRuneInString(val[length:])
+	switch {
+	case isKeycapBase(char):
+		if next == vs15 || next == vs16 {
+			length += size
+			next, size = utf8.DecodeRuneInString(val[length:])
+		}
+		if next != keycap {
+			return 0
+		}
+		return length + size
+	case isRegionalIndicator(char):
+		if isRegionalIndicator(next) {
+			length += size
+		}
+		return length
+	case char == blackFlag && isTagSpec(next):
+		tagLength := length
+		for isTagSpec(next) {
+			tagLength += size
+			next, size = utf8.DecodeRuneInString(val[tagLength:])
+		}
+		if next == cancelTag {
+			return tagLength + size
+		}
+	case !IsEmoji(char):
+		return 0
+	}
+	if next == vs15 || next == vs16 {
+		length += size
+		next, size = utf8.DecodeRuneInString(val[length:])
+	}
+	if isSkinTone(next) && !isSkinTone(char) {
+		length += size
+		// Stray variation selectors after the modifier are also included in the element
+		if next, size = utf8.DecodeRuneInString(val[length:]); next == vs15 || next == vs16 {
+			length += size
+		}
+	}
+	return length
+}
+
+// emojiSequenceLength returns the length in bytes of the emoji sequence at the start of the given string,
+// or 0 if the string doesn't start with an emoji. Zero-width joiner sequences are treated as a single emoji.
+//
+// A zero-width joiner is only included in the sequence if it's followed by another emoji element,
+// which means trailing joiners are not a part of the sequence.
+func emojiSequenceLength(val string) int {
+	length := emojiElementLength(val)
+	if length == 0 {
+		return 0
+	}
+	for {
+		next, size := utf8.DecodeRuneInString(val[length:])
+		if next != zwj {
+			break
+		}
+		elementLength := emojiElementLength(val[length+size:])
+		if elementLength == 0 {
+			break
+		}
+		length += size + elementLength
+	}
+	return length
+}
+
+// nextEmoji finds the first emoji sequence in the given string.
+// The returned indexes are -1 if the string doesn't contain emojis.
+func nextEmoji(val string) (start, end int) {
+	for i := 0; i < len(val); {
+		// Fast path: the only ASCII characters that can start an emoji are keycap bases
+		if val[i] < utf8.RuneSelf && !isKeycapBase(rune(val[i])) {
+			i++
+			continue
+		}
+		if length := emojiSequenceLength(val[i:]); length > 0 {
+			return i, i + length
+		}
+		_, size := utf8.DecodeRuneInString(val[i:])
+		i += size
+	}
+	return -1, -1
+}
+
+// ContainsEmoji checks if the given string contains at least one emoji.
+//
+// The check stops at the first emoji and doesn't allocate, so it can be used to cheaply skip
+// emoji processing for plain text.
+//
+// Emojis without variation selectors (e.g. a plain U+263A) are also detected.
+// Digits, # and * are only considered emojis when they're followed by a keycap.
+func ContainsEmoji(val string) bool {
+	start, _ := nextEmoji(val)
+	return start >= 0
+}
+
+// IsSingleEmoji checks if the given string consists of exactly one emoji, e.g. for validating reactions.
+//
+// Skin tone modifier sequences, flags, keycaps and zero-width joiner sequences are all considered to be one emoji.
+// Variation selectors are allowed, but not required, so both fully-qualified and unqualified forms are accepted.
+// Any other characters, including surrounding whitespace, make the check fail.
+func IsSingleEmoji(val string) bool {
+	return len(val) > 0 && emojiSequenceLength(val) == len(val)
+}
+
+// IsZWJSequence checks if the given string is a single zero-width joiner sequence, i.e. a compound emoji
+// made of multiple emojis joined with U+200D (e.g. family and profession emojis).
+func IsZWJSequence(val string) bool {
+	return IsSingleEmoji(val) && strings.ContainsRune(val, zwj)
+}
+
+// SplitZWJ splits a zero-width joiner sequence into the component emojis.
+//
+// The components keep their own variation selectors and skin tone modifiers. If the components need to be
+// displayed separately (e.g. on a platform that doesn't support the compound emoji), use FullyQualify on each one.
+// Strings without zero-width joiners are returned as the only item, and empty strings return nil.
+func SplitZWJ(val string) []string {
+	if len(val) == 0 {
+		return nil
+	}
+	return strings.Split(val, string(zwj))
+}
+
+// Normalize converts all zero-width joiner sequences in the given string to their fully-qualified form
+// as listed in emoji-test.txt.
+//
+// Variation selectors in the wrong places (e.g. U+1F469 U+FE0F U+200D U+1F4BB, which some platforms produce) are
+// removed and missing ones are added. Sequences that aren't recommended for general interchange (RGI) are left as-is,
+// as are emojis outside zero-width joiner sequences.
+func Normalize(val string) string {
+	data := getData()
+	var buf strings.Builder
+	copied := 0
+	for i := 0; ; {
+		start, end := nextEmoji(val[i:])
+		if start < 0 {
+			break
+		}
+		start, end = i+start, i+end
+		i = end
+		emoji := val[start:end]
+		if !strings.ContainsRune(emoji, zwj) {
+			continue
+		}
+		rgi, ok := data.zwjSequences[RemoveAll(emoji)]
+		if !ok || rgi == emoji {
+			continue
+		}
+		buf.Grow(len(val))
+		buf.WriteString(val[copied:start])
+		buf.WriteString(rgi)
+		copied = end
+	}
+	if copied == 0 {
+		return val
+	}
+	buf.WriteString(val[copied:])
+	return buf.String()
+}
+
+// FirstEmoji finds the first emoji in the given string.
+//
+// Any non-emoji text before the first emoji is skipped, so "hello 👍 world" returns 👍 and " world".
+// Use IsSingleEmoji or check that the returned emoji is a prefix of the input if the emoji must be
+// at the start of the string.
+//
+// The emoji is returned as it appears in the input (i.e. it's not normalized). Zero-width joiner sequences,
+// flags, keycaps and skin tone modifier sequences are returned whole. If the string doesn't contain any emojis,
+// ok is false and both strings are empty.
+func FirstEmoji(val string) (emoji, rest string, ok bool) {
+	start, end := nextEmoji(val)
+	if start < 0 {
+		return "", "", false
+	}
+	return val[start:end], val[end:], true
+}
+
+// ExtractEmojis returns all emojis in the given string in their fully-qualified form.
+//
+// Zero-width joiner sequences, flags, keycaps and skin tone modifier sequences are returned as single items.
+func ExtractEmojis(val string) (emojis []string) {
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			return
+		}
+		emojis = append(emojis, FullyQualify(RemoveAll(val[start:end])))
+		val = val[end:]
+	}
+}
+
+// StripEmojis removes all emojis from the given string and returns the remaining text.
+//
+// Entire emoji sequences are removed, including any variation selectors, skin tone modifiers and zero-width joiners.
+func StripEmojis(val string) string {
+	return ReplaceEmojis(val, "")
+}
+
+// ReplaceEmojis replaces each emoji in the given string with the given replacement string,
+// e.g. for generating plain-text fallbacks or searchable text.
+//
+// Emojis are found the same way as in Split, so zero-width joiner sequences, flags, keycaps and skin tone modifier
+// sequences are replaced as a whole, including their variation selectors. Other text, including combining marks
+// and symbols that aren't emojis, is preserved as-is.
+func ReplaceEmojis(val, replacement string) string {
+	start, end := nextEmoji(val)
+	if start < 0 {
+		return val
+	}
+	var buf strings.Builder
+	buf.Grow(len(val))
+	for start >= 0 {
+		buf.WriteString(val[:start])
+		buf.WriteString(replacement)
+		val = val[end:]
+		start, end = nextEmoji(val)
+	}
+	buf.WriteString(val)
+	return buf.String()
+}
+
+// Count returns the number of emojis in the given string.
+//
+// Zero-width joiner sequences, flags, keycaps and skin tone modifier sequences are counted as one emoji.
+//
+// Malformed sequences are handled as follows:
+//   - A zero-width joiner that isn't followed by an emoji (e.g. at the end of the string) is ignored,
+//     so the emoji before it is counted normally.
+//   - Skin tone modifiers and regional indicators that aren't attached to anything are counted as separate emojis.
+//   - Digits, # and * without a keycap and variation selectors without an emoji are not counted.
+func Count(val string) (count int) {
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			return
+		}
+		count++
+		val = val[end:]
+	}
+}
+
+// Emojis returns an iterator over all emojis in the given string. The key is the byte offset of the emoji
+// in the original string and the value is the emoji itself as it appears in the string.
+//
+// Zero-width joiner sequences, flags, keycaps and skin tone modifier sequences are yielded as single emojis.
+// The yielded values are substrings of the input, so iterating doesn't allocate.
+func Emojis(val string) iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		offset := 0
+		for {
+			start, end := nextEmoji(val[offset:])
+			if start < 0 || !yield(offset+start, val[offset+start:offset+end]) {
+				return
+			}
+			offset += end
+		}
+	}
+}
+
+// CountEmojis counts the emojis in the given string both as individual codepoints and as visible emojis.
+//
+// The rune count includes every codepoint that is a part of an emoji, including zero-width joiners,
+// variation selectors and skin tone modifiers. The cluster count is the number of visible emojis, which is the same
+// as what Count returns. For example, a family emoji made of three people has 5 runes, but it's only one cluster.
+func CountEmojis(val string) (runes, clusters int) {
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			return
+		}
+		runes += utf8.RuneCountInString(val[start:end])
+		clusters++
+		val = val[end:]
+	}
+}
+
+// Segment is a part of a string returned by Split.
+type Segment struct {
+	// Text is the substring of the original string.
+	Text string
+	// IsEmoji is true if the segment is a single emoji and false if it's non-emoji text.
+	IsEmoji bool
+}
+
+// Split splits the given string into emoji and non-emoji segments.
+//
+// Each emoji (including zero-width joiner sequences, flags, keycaps and skin tone modifier sequences) is returned
+// as its own segment, while consecutive non-emoji text is combined into a single segment. Concatenating the text
+// of all the segments will always produce the original string.
+func Split(val string) (segments []Segment) {
+	for len(val) > 0 {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			start, end = len(val), len(val)
+		}
+		if start > 0 {
+			segments = append(segments, Segment{Text: val[:start]})
+		}
+		if end > start {
+			segments = append(segments, Segment{Text: val[start:end], IsEmoji: true})
+		}
+		val = val[end:]
+	}
+	return
+}
+
+// Sequences splits the given string into individual emoji sequences and runs of non-emoji text.
+//
+// This is equivalent to Split, but only returns the text of each segment.
+func Sequences(val string) []string {
+	segments := Split(val)
+	sequences := make([]string, len(segments))
+	for i, segment := range segments {
+		sequences[i] = segment.Text
+	}
+	return sequences
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SkinTone is one of the five Fitzpatrick skin tone modifiers defined in Unicode Technical Standard #51,
+// or ToneDefault for no modifier.
+type SkinTone int
+
+const (
+	// ToneDefault means no skin tone modifier, i.e. the default yellow skin tone.
+	ToneDefault SkinTone = iota
+	ToneLight
+	ToneMediumLight
+	ToneMedium
+	ToneMediumDark
+	ToneDark
+)
+
+const firstSkinTone = 0x1F3FB
+
+// Modifier returns the skin tone modifier character for the tone, or an empty string if the tone is invalid.
+func (tone SkinTone) Modifier() string {
+	if tone < ToneLight || tone > ToneDark {
+		return ""
+	}
+	return string(rune(firstSkinTone + int(tone-ToneLight)))
+}
+
+func (tone SkinTone) String() string {
+	switch tone {
+	case ToneDefault:
+		return "default"
+	case ToneLight:
+		return "light"
+	case ToneMediumLight:
+		return "medium-light"
+	case ToneMedium:
+		return "medium"
+	case ToneMediumDark:
+		return "medium-dark"
+	case ToneDark:
+		return "dark"
+	default:
+		return ""
+	}
+}
+
+// HasSkinToneSupport checks if the given string is a single emoji that can have a skin tone modifier.
+//
+// This uses the Emoji_Modifier_Base property from emoji-data.txt, so it's true for e.g. thumbs up (U+1F44D)
+// and zero-width joiner sequences containing people like astronauts (U+1F468 U+200D U+1F680), but false for objects,
+// flags and other symbols. Emojis that already have a skin tone modifier are also considered supported.
+func HasSkinToneSupport(emoji string) bool {
+	if !IsSingleEmoji(emoji) {
+		return false
+	}
+	data := getData()
+	for _, char := range emoji {
+		if _, ok := data.modifierBases[char]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSkinTone returns the skin tone of the given emoji, or ToneDefault if it doesn't have a skin tone modifier.
+//
+// For multi-person emojis where each person can have a different tone, this returns the tone of the first person.
+// Use GetSkinTones to get the tones of all people.
+func GetSkinTone(emoji string) SkinTone {
+	for _, char := range emoji {
+		if isSkinTone(char) {
+			return SkinTone(char-firstSkinTone) + ToneLight
+		}
+	}
+	return ToneDefault
+}
+
+// GetSkinTones returns all the skin tone modifiers in the given emoji in order.
+// The returned slice is empty if the emoji doesn't have any skin tone modifiers.
+func GetSkinTones(emoji string) (tones []SkinTone) {
+	for _, char := range emoji {
+		if isSkinTone(char) {
+			tones = append(tones, SkinTone(char-firstSkinTone)+ToneLight)
+		}
+	}
+	return
+}
+
+// SetSkinTone applies the given skin tone to all characters in the emoji that support skin tone modifiers.
+//
+// The modifier is placed right after each base character (i.e. before any zero-width joiners). Existing skin tone
+// modifiers are replaced, and emoji variation selectors are removed from the modified characters, as skin tone
+// modifiers take their place in the fully-qualified form. Emojis that don't support skin tones are returned unchanged.
+//
+// Setting ToneDefault removes existing skin tones like RemoveSkinTone. Invalid tones return the input unchanged.
+func SetSkinTone(emoji string, tone SkinTone) string {
+	if tone == ToneDefault {
+		return RemoveSkinTone(emoji)
+	}
+	modifier := tone.Modifier()
+	if modifier == "" {
+		return emoji
+	}
+	data := getData()
+	var buf strings.Builder
+	buf.Grow(len(emoji) + len(modifier))
+	for i := 0; i < len(emoji); {
+		char, size := utf8.DecodeRuneInString(emoji[i:])
+		buf.WriteString(emoji[i : i+size])
+		i += size
+		if _, ok := data.modifierBases[char]; !ok {
+			continue
+		}
+		for i < len(emoji) {
+			next, nextSize := utf8.DecodeRuneInString(emoji[i:])
+			if next != vs15 && next != vs16 && !isSkinTone(next) {
+				break
+			}
+			i += nextSize
+		}
+		buf.WriteString(modifier)
+	}
+	return buf.String()
+}
+
+func removeSkinToneModifiers(emoji string) string {
+	var buf strings.Builder
+	buf.Grow(len(emoji))
+	for i := 0; i < len(emoji); {
+		char, size := utf8.DecodeRuneInString(emoji[i:])
+		if !isSkinTone(char) {
+			buf.WriteString(emoji[i : i+size])
+		}
+		i += size
+	}
+	return buf.String()
+}
+
+// RemoveSkinTone removes all skin tone modifiers from the emojis in the given string.
+//
+// This can be used to normalize emojis to their skin-tone-neutral form, e.g. when counting reactions.
+// The output is always a valid emoji: emojis that need a variation selector without the modifier get one,
+// and multi-person emojis where each person has their own tone (e.g. U+1FAF1 U+1F3FB U+200D U+1FAF2 U+1F3FC)
+// are converted to the neutral form (U+1F91D).
+func RemoveSkinTone(val string) string {
+	if GetSkinTone(val) == ToneDefault {
+		return val
+	}
+	data := getData()
+	var buf strings.Builder
+	buf.Grow(len(val))
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			break
+		}
+		buf.WriteString(val[:start])
+		emoji := val[start:end]
+		if GetSkinTone(emoji) != ToneDefault {
+			emoji = removeSkinToneModifiers(emoji)
+			if neutral, ok := data.neutralForms[RemoveAll(emoji)]; ok {
+				emoji = neutral
+			}
+		}
+		buf.WriteString(emoji)
+		val = val[end:]
+	}
+	buf.WriteString(val)
+	return buf.String()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+type addTransformer struct {
+	transform.NopResetter
+}
+
+// AddTransformer returns a transformer that adds emoji variation selectors like Add.
+//
+// The transformer can be used with the functions in golang.org/x/text/transform to process streams of text
+// without loading the whole text into memory. The output is always the same as with Add.
+func AddTransformer() transform.Transformer {
+	return addTransformer{}
+}
+
+// NewAddReader wraps the given reader so that emoji variation selectors are added to the text like Add.
+//
+// Emoji sequences split across reads are buffered until the whole sequence is available, and any partial sequence
+// is flushed as-is when the underlying reader reaches EOF. This is a shorthand for using AddTransformer
+// with transform.NewReader.
+func NewAddReader(r io.Reader) io.Reader {
+	return transform.NewReader(r, AddTransformer())
+}
+
+func (addTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	data := getData()
+	val := bytesAsString(src)
+	for nSrc < len(val) {
+		if val[nSrc] < utf8.RuneSelf && !isKeycapBase(rune(val[nSrc])) {
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = val[nSrc]
+			nDst++
+			nSrc++
+			continue
+		} else if !atEOF && !utf8.FullRuneInString(val[nSrc:]) {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+		char, size := utf8.DecodeRuneInString(val[nSrc:])
+		charEnd := nSrc + size
+		if char == vs15 || char == vs16 {
+			charEnd = nSrc
+		}
+		selectorEnd, selectors := addSelectors(data, val, char, charEnd, defaultOptions, false, latestEmojiVersion)
+		// The output depends on the next character, so it must be fully in the buffer unless this is the end of the input.
+		// This also covers variation selectors that are split across buffers.
+		if !atEOF && (selectorEnd == len(val) || !utf8.FullRuneInString(val[selectorEnd:])) {
+			return nDst, nSrc, transform.ErrShortSrc
+		} else if nDst+charEnd-nSrc+len(selectors) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], val[nSrc:charEnd])
+		nDst += copy(dst[nDst:], selectors)
+		nSrc = selectorEnd
+	}
+	return nDst, nSrc, nil
+}
+
+type removeTransformer struct {
+	transform.NopResetter
+}
+
+// RemoveTransformer returns a transformer that removes emoji variation selectors like Remove.
+func RemoveTransformer() transform.Transformer {
+	return removeTransformer{}
+}
+
+func (removeTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	val := bytesAsString(src)
+	for nSrc < len(val) {
+		idx := strings.Index(val[nSrc:], VS16)
+		end := len(val)
+		if idx >= 0 {
+			end = nSrc + idx
+		} else if !atEOF {
+			// Don't output the start of a variation selector that may continue in the next buffer
+			for i := max(end-len(VS16)+1, nSrc); i < end; i++ {
+				if strings.HasPrefix(VS16, val[i:]) {
+					end = i
+					break
+				}
+			}
+		}
+		n := copy(dst[nDst:], val[nSrc:end])
+		nDst += n
+		nSrc += n
+		if nSrc < end {
+			return nDst, nSrc, transform.ErrShortDst
+		} else if idx < 0 {
+			break
+		}
+		nSrc += len(VS16)
+	}
+	if nSrc < len(val) {
+		err = transform.ErrShortSrc
+	}
+	return nDst, nSrc, err
+}
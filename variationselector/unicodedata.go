@@ -0,0 +1,430 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mau.fi/util/exsync"
+)
+
+//go:generate ./generate.sh
+
+//go:embed emojis-with-variations.json
+var emojisWithVariationsJSON []byte
+
+//go:embed fully-qualified-emojis.json
+var fullyQualifiedEmojisJSON []byte
+
+//go:embed emoji-versions.json
+var emojiVersionsJSON []byte
+
+//go:embed emoji-modifier-bases.json
+var emojiModifierBasesJSON []byte
+
+//go:embed emoji-names.json
+var emojiNamesJSON []byte
+
+// embeddedUnicodeVersion is the emoji version of the data files embedded in the package.
+// It must be kept in sync with the URLs in generate.sh.
+const embeddedUnicodeVersion = "15.0"
+
+// unicodeData contains all the lookup tables generated from the Unicode emoji data files.
+type unicodeData struct {
+	version string
+
+	// emojiRunes contains all characters that are a part of fully-qualified emojis, except sequence components.
+	emojiRunes map[rune]struct{}
+	// variationRunes contains all characters that have emoji and text variation sequences.
+	variationRunes map[rune]struct{}
+	// textDefaultRunes contains the characters that are displayed as text by default, i.e. ones that only have
+	// a fully-qualified form with an emoji variation selector.
+	textDefaultRunes map[rune]struct{}
+	// modifierBases contains all characters that have the Emoji_Modifier_Base property, as well as any other
+	// characters that are followed by a skin tone modifier in some fully-qualified emoji.
+	modifierBases map[rune]struct{}
+	// neutralForms maps emojis with skin tone modifiers and variation selectors removed to the fully-qualified
+	// skin-tone-neutral form. It's needed for multi-person emojis like U+1F46B, which don't have a neutral form
+	// with the same structure as the toned forms.
+	neutralForms map[string]string
+	// zwjSequences maps zero-width joiner sequences with variation selectors removed to the fully-qualified form.
+	zwjSequences map[string]string
+	// qualifiedForms maps emojis that have variation selectors in their fully-qualified forms to the fully-qualified
+	// form. Both the fully-qualified form itself and the form with variation selectors removed are included as keys.
+	qualifiedForms map[string]string
+	// fullyQualifiedEmojis contains all fully-qualified emojis in the original order.
+	fullyQualifiedEmojis []string
+	// qualifiers contains all fully-qualified emojis that have variation selectors, keyed by their first character.
+	// The lists are in the original order, which determines the priority when multiple emojis match.
+	qualifiers map[rune][]qualifiedForm
+
+	// versions contains the emoji version of each fully-qualified emoji.
+	versions map[string]EmojiVersion
+	// runeVersions contains the lowest version of the fully-qualified emojis starting with each character.
+	runeVersions map[rune]EmojiVersion
+
+	// names contains the CLDR short name of each fully-qualified emoji. It's lazy, as it's only needed for Name.
+	names *exsync.Lazy[map[string]string]
+	// emojisByName is the inverse of names with lowercased names as keys. It's lazy, as it's only needed for ByName.
+	emojisByName *exsync.Lazy[map[string]string]
+}
+
+var currentData atomic.Pointer[unicodeData]
+
+// embeddedData contains the parsed embedded data. It's set by loadEmbeddedData, so it must only be read after
+// calling embeddedInit.Do.
+var embeddedData *unicodeData
+
+var embeddedInit exsync.OnceWithError
+
+func loadEmbeddedData() error {
+	data, err := parseEmbeddedData()
+	if err != nil {
+		embeddedData = newUnicodeData("", nil, nil, nil, nil)
+		return fmt.Errorf("failed to parse embedded emoji data: %w", err)
+	}
+	embeddedData = data
+	return nil
+}
+
+// Init parses the emoji data embedded in the package.
+//
+// Data is initialized automatically when it's first needed, so calling this is not required, but it can be used
+// at startup to make sure the data is valid and to avoid the parsing delay on the first call to other functions.
+// If the data is invalid, the error is returned here and by InitError, and all other functions will act as if
+// there were no emojis (e.g. Add and FullyQualify will return the input unchanged).
+//
+// If LoadUnicodeData has already been called, the embedded data is parsed and validated, but not used.
+func Init() error {
+	err := embeddedInit.Do(loadEmbeddedData)
+	currentData.CompareAndSwap(nil, embeddedData)
+	return err
+}
+
+// InitError returns the error that occurred when initializing the embedded emoji data, if any.
+//
+// This will return nil if the data hasn't been initialized yet. Use Init to initialize it explicitly.
+func InitError() error {
+	return embeddedInit.Err()
+}
+
+func getData() *unicodeData {
+	data := currentData.Load()
+	if data == nil {
+		_ = Init()
+		data = currentData.Load()
+	}
+	return data
+}
+
+// parseRuneList parses an embedded JSON file containing an array of single-character strings.
+func parseRuneList(name string, data []byte) ([]rune, error) {
+	var chars []string
+	err := json.Unmarshal(data, &chars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	runes := make([]rune, len(chars))
+	for i, char := range chars {
+		var size int
+		runes[i], size = utf8.DecodeRuneInString(char)
+		if runes[i] == utf8.RuneError || size != len(char) {
+			return nil, fmt.Errorf("invalid entry %q in %s: expected a single character", char, name)
+		}
+	}
+	return runes, nil
+}
+
+func parseEmbeddedData() (*unicodeData, error) {
+	variationRunes, err := parseRuneList("emojis-with-variations.json", emojisWithVariationsJSON)
+	if err != nil {
+		return nil, err
+	}
+	modifierBases, err := parseRuneList("emoji-modifier-bases.json", emojiModifierBasesJSON)
+	if err != nil {
+		return nil, err
+	}
+	var fullyQualifiedEmojis []string
+	err = json.Unmarshal(fullyQualifiedEmojisJSON, &fullyQualifiedEmojis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fully-qualified-emojis.json: %w", err)
+	}
+	var emojisByVersion map[string][]string
+	err = json.Unmarshal(emojiVersionsJSON, &emojisByVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse emoji-versions.json: %w", err)
+	}
+	versions := make(map[string]EmojiVersion, len(fullyQualifiedEmojis))
+	for versionStr, emojis := range emojisByVersion {
+		version, err := parseEmojiVersion(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse emoji-versions.json: %w", err)
+		}
+		for _, emoji := range emojis {
+			versions[emoji] = version
+		}
+	}
+	data := newUnicodeData(embeddedUnicodeVersion, variationRunes, modifierBases, fullyQualifiedEmojis, versions)
+	data.setNames(parseEmbeddedNames)
+	return data, nil
+}
+
+// parseEmbeddedNames parses emoji-names.json. Errors are ignored, as the names are only used by Name and ByName,
+// which will act as if the names of all emojis are unknown.
+func parseEmbeddedNames() map[string]string {
+	var names map[string]string
+	_ = json.Unmarshal(emojiNamesJSON, &names)
+	return names
+}
+
+func newUnicodeData(
+	version string,
+	variationRunes, modifierBases []rune,
+	fullyQualifiedEmojis []string,
+	versions map[string]EmojiVersion,
+) *unicodeData {
+	data := &unicodeData{
+		version:              version,
+		fullyQualifiedEmojis: fullyQualifiedEmojis,
+		emojiRunes:           make(map[rune]struct{}),
+		variationRunes:       make(map[rune]struct{}, len(variationRunes)),
+		textDefaultRunes:     make(map[rune]struct{}),
+		modifierBases:        make(map[rune]struct{}),
+		neutralForms:         make(map[string]string),
+		zwjSequences:         make(map[string]string),
+		qualifiedForms:       make(map[string]string),
+		qualifiers:           make(map[rune][]qualifiedForm),
+		versions:             versions,
+		runeVersions:         make(map[rune]EmojiVersion),
+	}
+	// Every character in emoji-variation-sequences.txt has both a text and an emoji variation sequence,
+	// so the same set is used for adding both kinds of variation selectors.
+	for _, char := range variationRunes {
+		data.variationRunes[char] = struct{}{}
+	}
+	for _, char := range modifierBases {
+		data.modifierBases[char] = struct{}{}
+	}
+	// The emojis in emoji-test.txt are ordered so that emojis with skin tones come right after the neutral form
+	var lastNeutral string
+	for _, emoji := range fullyQualifiedEmojis {
+		if GetSkinTone(emoji) != ToneDefault {
+			data.neutralForms[RemoveAll(removeSkinToneModifiers(emoji))] = lastNeutral
+		} else {
+			lastNeutral = emoji
+		}
+		firstChar, size := utf8.DecodeRuneInString(emoji)
+		if emoji[size:] == VS16 {
+			data.textDefaultRunes[firstChar] = struct{}{}
+		}
+		if existing, ok := data.runeVersions[firstChar]; !ok || versions[emoji] < existing {
+			data.runeVersions[firstChar] = versions[emoji]
+		}
+		var prev rune
+		for _, char := range emoji {
+			if !isSequenceComponent(char) {
+				data.emojiRunes[char] = struct{}{}
+			}
+			if isSkinTone(char) && !isSkinTone(prev) {
+				data.modifierBases[prev] = struct{}{}
+			}
+			prev = char
+		}
+		if strings.ContainsRune(emoji, zwj) {
+			data.zwjSequences[RemoveAll(emoji)] = emoji
+		}
+		if strings.Contains(emoji, VS16) {
+			unqualified := strings.ReplaceAll(emoji, VS16, "")
+			data.qualifiedForms[unqualified] = emoji
+			data.qualifiedForms[emoji] = emoji
+			data.qualifiers[firstChar] = append(data.qualifiers[firstChar], qualifiedForm{
+				unqualified: unqualified,
+				qualified:   emoji,
+				version:     versions[emoji],
+			})
+		}
+	}
+	return data
+}
+
+// qualifiedForm is a fully-qualified emoji that has variation selectors, used by appendFullyQualify.
+type qualifiedForm struct {
+	unqualified string
+	qualified   string
+	version     EmojiVersion
+}
+
+// findQualifiedForm finds the first emoji in qualifiers whose unqualified form is a prefix of val, which must
+// start with the given character. Emojis newer than maxVersion are ignored.
+func (data *unicodeData) findQualifiedForm(val string, char rune, maxVersion EmojiVersion) (qualifiedForm, bool) {
+	for _, form := range data.qualifiers[char] {
+		if form.version <= maxVersion && strings.HasPrefix(val, form.unqualified) {
+			return form, true
+		}
+	}
+	return qualifiedForm{}, false
+}
+
+// isQualifiable checks if an emoji variation selector after the given character is a part of an emoji.
+func (data *unicodeData) isQualifiable(char rune) bool {
+	_, isVariation := data.variationRunes[char]
+	_, isEmoji := data.emojiRunes[char]
+	return isVariation || isEmoji || char == keycap
+}
+
+// UnicodeVersion returns the emoji version of the data set that is currently used, e.g. "15.0".
+// If the embedded data failed to initialize, this returns an empty string.
+func UnicodeVersion() string {
+	return getData().version
+}
+
+// AllEmojis returns all fully-qualified emojis in the data set that is currently used, including emojis with
+// skin tones and other sequences, in the order of emoji-test.txt (i.e. the same order as in emoji pickers).
+//
+// The returned slice is a copy, so it can be modified freely.
+func AllEmojis() []string {
+	return slices.Clone(getData().fullyQualifiedEmojis)
+}
+
+// LoadUnicodeData replaces the embedded emoji data with newer data files from Unicode.
+//
+// The readers must contain emoji-test.txt and emoji-variation-sequences.txt in the format published at
+// https://unicode.org/Public/emoji/. If either file can't be parsed, an error is returned and the current
+// data set is left unchanged. This is safe to call concurrently with all other functions in the package.
+//
+// The embedded Emoji_Modifier_Base data is reused, but characters that have skin tone modifiers in the new
+// emoji-test.txt are detected as modifier bases too.
+func LoadUnicodeData(emojiTest, variationSequences io.Reader) error {
+	version, fullyQualifiedEmojis, versions, names, err := parseEmojiTest(emojiTest)
+	if err != nil {
+		return fmt.Errorf("failed to parse emoji-test.txt: %w", err)
+	}
+	variationRunes, err := parseVariationSequences(variationSequences)
+	if err != nil {
+		return fmt.Errorf("failed to parse emoji-variation-sequences.txt: %w", err)
+	}
+	modifierBases, err := parseRuneList("emoji-modifier-bases.json", emojiModifierBasesJSON)
+	if err != nil {
+		return err
+	}
+	data := newUnicodeData(version, variationRunes, modifierBases, fullyQualifiedEmojis, versions)
+	data.setNames(func() map[string]string {
+		return names
+	})
+	currentData.Store(data)
+	return nil
+}
+
+func parseCodepoints(field string) (string, error) {
+	var buf strings.Builder
+	for _, hex := range strings.Fields(field) {
+		char, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid codepoint %q: %w", hex, err)
+		} else if char > unicode.MaxRune {
+			return "", fmt.Errorf("invalid codepoint %q: out of range", hex)
+		}
+		buf.WriteRune(rune(char))
+	}
+	if buf.Len() == 0 {
+		return "", errors.New("missing codepoints")
+	}
+	return buf.String(), nil
+}
+
+// parseDataLines calls the given function with the fields and the trailing comment of each data line
+// in a Unicode data file. Comment lines are passed to the comment function instead.
+func parseDataLines(reader io.Reader, comment func(string), fn func(fields []string, comment string) error) error {
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			comment(strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		line, lineComment, _ := strings.Cut(line, "#")
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i, field := range fields {
+			fields[i] = strings.TrimSpace(field)
+		}
+		if err := fn(fields, strings.TrimSpace(lineComment)); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseEmojiTest(reader io.Reader) (version string, fullyQualifiedEmojis []string, versions map[string]EmojiVersion, names map[string]string, err error) {
+	versions = make(map[string]EmojiVersion)
+	names = make(map[string]string)
+	err = parseDataLines(reader, func(comment string) {
+		if v, ok := strings.CutPrefix(comment, "Version:"); ok && version == "" {
+			version = strings.TrimSpace(v)
+		}
+	}, func(fields []string, comment string) error {
+		if len(fields) < 2 {
+			return errors.New("missing status field")
+		} else if fields[1] != "fully-qualified" {
+			return nil
+		}
+		emoji, err := parseCodepoints(fields[0])
+		if err != nil {
+			return err
+		}
+		fullyQualifiedEmojis = append(fullyQualifiedEmojis, emoji)
+		// The comment is in the format "<emoji> E<version> <name>"
+		commentFields := strings.Fields(comment)
+		for i, field := range commentFields {
+			if len(field) > 1 && field[0] == 'E' && field[1] >= '0' && field[1] <= '9' {
+				names[emoji] = strings.Join(commentFields[i+1:], " ")
+				versions[emoji], err = parseEmojiVersion(field[1:])
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && version == "" {
+		err = errors.New("version header not found")
+	} else if err == nil && len(fullyQualifiedEmojis) == 0 {
+		err = errors.New("no fully-qualified emojis found")
+	}
+	return
+}
+
+func parseVariationSequences(reader io.Reader) (variationRunes []rune, err error) {
+	err = parseDataLines(reader, func(string) {}, func(fields []string, _ string) error {
+		sequence, err := parseCodepoints(fields[0])
+		if err != nil {
+			return err
+		}
+		char, size := utf8.DecodeRuneInString(sequence)
+		if sequence[size:] == VS16 {
+			variationRunes = append(variationRunes, char)
+		}
+		return nil
+	})
+	if err == nil && len(variationRunes) == 0 {
+		err = errors.New("no emoji variation sequences found")
+	}
+	return
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/util/exsync"
+)
+
+const testEmojiTest = `# emoji-test.txt
+# Version: 16.0
+
+# group: Smileys & Emotion
+263A FE0F                                              ; fully-qualified     # E0.6 smiling face
+263A                                                   ; unqualified         # E0.6 smiling face
+1FAE9                                                  ; fully-qualified     # E16.0 face with bags under eyes
+
+# group: People & Body
+1F44D                                                  ; fully-qualified     # E0.6 thumbs up
+1F44D 1F3FD                                            ; fully-qualified     # E1.0 thumbs up: medium skin tone
+`
+
+const testVariationSequences = `# emoji-variation-sequences.txt
+263A FE0E ; text style;  # (1.1) WHITE SMILING FACE
+263A FE0F ; emoji style; # (1.1) WHITE SMILING FACE
+`
+
+func TestLoadUnicodeData(t *testing.T) {
+	defer currentData.Store(getData())
+	assert.Equal(t, "15.0", UnicodeVersion())
+	assert.False(t, IsEmoji(0x1FAE9))
+	assert.Equal(t, "\u2708\ufe0f", Add("\u2708"))
+
+	err := LoadUnicodeData(strings.NewReader(testEmojiTest), strings.NewReader(testVariationSequences))
+	require.NoError(t, err)
+	assert.Equal(t, "16.0", UnicodeVersion())
+	assert.True(t, IsEmoji(0x1FAE9))
+	assert.Equal(t, "\u2708", Add("\u2708"))
+	assert.Equal(t, "\u263a\ufe0f \U0001FAE9", Add("\u263a \U0001FAE9"))
+	assert.Equal(t, "\u263a\ufe0f", FullyQualify("\u263a"))
+	assert.True(t, IsFullyQualified("\u263a\ufe0f \U0001FAE9"))
+	assert.Equal(t, "\U0001f44d\U0001f3fb", SetSkinTone("\U0001f44d", ToneLight))
+	name, ok := Name("\U0001FAE9")
+	assert.True(t, ok)
+	assert.Equal(t, "face with bags under eyes", name)
+	name, _ = Name("\u263a")
+	assert.Equal(t, "smiling face", name)
+	_, ok = Name("\U0001f600")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"\u263a\ufe0f", "\U0001FAE9", "\U0001f44d", "\U0001f44d\U0001f3fd"}, AllEmojis())
+	emoji, ok := ByName("Face With Bags Under Eyes")
+	assert.True(t, ok)
+	assert.Equal(t, "\U0001FAE9", emoji)
+	_, ok = ByName("grinning face")
+	assert.False(t, ok)
+}
+
+func TestLoadUnicodeData_Invalid(t *testing.T) {
+	defer currentData.Store(getData())
+	for name, input := range map[string][2]string{
+		"InvalidCodepoint":      {strings.Replace(testEmojiTest, "1FAE9", "1FXE9", 1), testVariationSequences},
+		"CodepointOutOfRange":   {strings.Replace(testEmojiTest, "1FAE9", "1FFFFFF", 1), testVariationSequences},
+		"MissingStatus":         {testEmojiTest + "1F600\n", testVariationSequences},
+		"MissingVersion":        {strings.Replace(testEmojiTest, "# Version: 16.0", "", 1), testVariationSequences},
+		"NoEmojis":              {"# Version: 16.0\n", testVariationSequences},
+		"NoVariationSequences":  {testEmojiTest, "# empty\n"},
+		"InvalidVariationInput": {testEmojiTest, "263A FEXF ; emoji style; # (1.1) WHITE SMILING FACE\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := LoadUnicodeData(strings.NewReader(input[0]), strings.NewReader(input[1]))
+			assert.Error(t, err)
+			assert.Equal(t, "15.0", UnicodeVersion())
+		})
+	}
+}
+
+func TestLoadUnicodeData_Concurrent(t *testing.T) {
+	defer currentData.Store(getData())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Add("\u263a \U0001f44d")
+				FullyQualify("\u263a \U0001f44d")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, LoadUnicodeData(strings.NewReader(testEmojiTest), strings.NewReader(testVariationSequences)))
+	}
+	wg.Wait()
+}
+
+func TestLoadUnicodeData_Versions(t *testing.T) {
+	defer currentData.Store(getData())
+	require.NoError(t, LoadUnicodeData(strings.NewReader(testEmojiTest), strings.NewReader(testVariationSequences)))
+	assert.Equal(t, Emoji0_6, getData().versions["\u263a\ufe0f"])
+	assert.Equal(t, EmojiVersion(1600), getData().versions["\U0001FAE9"])
+	assert.Equal(t, "\u263a", AddUpToVersion("\u263a", 0))
+	assert.Equal(t, "\u263a\ufe0f", AddUpToVersion("\u263a", Emoji0_6))
+
+	err := LoadUnicodeData(strings.NewReader(strings.Replace(testEmojiTest, "E0.6 smiling", "E0x6 smiling", 1)), strings.NewReader(testVariationSequences))
+	assert.Error(t, err)
+}
+
+func resetInit() {
+	embeddedInit = exsync.OnceWithError{}
+	embeddedData = nil
+	currentData.Store(nil)
+}
+
+func TestInit(t *testing.T) {
+	defer currentData.Store(getData())
+	resetInit()
+	assert.NoError(t, InitError())
+	assert.NoError(t, Init())
+	assert.NoError(t, InitError())
+	assert.Equal(t, "15.0", UnicodeVersion())
+}
+
+func TestInit_Lazy(t *testing.T) {
+	defer currentData.Store(getData())
+	resetInit()
+	assert.Equal(t, "\u263a\ufe0f", Add("\u263a"))
+	assert.NoError(t, InitError())
+}
+
+func TestInit_LazyVersion(t *testing.T) {
+	defer currentData.Store(getData())
+	resetInit()
+	assert.Equal(t, "15.0", UnicodeVersion())
+}
+
+func TestInit_InvalidData(t *testing.T) {
+	defer currentData.Store(getData())
+	for name, corrupt := range map[string]*[]byte{
+		"EmojisWithVariations": &emojisWithVariationsJSON,
+		"FullyQualifiedEmojis": &fullyQualifiedEmojisJSON,
+		"EmojiVersions":        &emojiVersionsJSON,
+		"EmojiModifierBases":   &emojiModifierBasesJSON,
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := *corrupt
+			defer func() {
+				*corrupt = original
+				resetInit()
+			}()
+			*corrupt = original[:len(original)/2]
+			resetInit()
+			assert.Equal(t, "\u263a", Add("\u263a"))
+			assert.Equal(t, "\u263a", FullyQualify("\u263a"))
+			assert.False(t, IsEmoji('\u263a'))
+			assert.Error(t, InitError())
+			assert.Error(t, Init())
+			assert.Equal(t, "", UnicodeVersion())
+		})
+	}
+	t.Run("MultipleRunes", func(t *testing.T) {
+		original := emojisWithVariationsJSON
+		defer func() {
+			emojisWithVariationsJSON = original
+			resetInit()
+		}()
+		emojisWithVariationsJSON = []byte(`["#","*","ab"]`)
+		resetInit()
+		assert.ErrorContains(t, Init(), "expected a single character")
+		assert.Equal(t, "#\u20e3", Add("#\u20e3"))
+	})
+}
+
+func TestInit_ErrorIsUnwrappable(t *testing.T) {
+	defer currentData.Store(getData())
+	original := fullyQualifiedEmojisJSON
+	defer func() {
+		fullyQualifiedEmojisJSON = original
+		resetInit()
+	}()
+	fullyQualifiedEmojisJSON = []byte(`["\u263a\ufe0f",`)
+	resetInit()
+	var err error
+	require.NotPanics(t, func() {
+		err = Init()
+	})
+	var syntaxErr *json.SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.ErrorContains(t, err, "failed to parse embedded emoji data")
+	assert.Equal(t, err, InitError())
+}
+
+func TestInit_AfterLoadUnicodeData(t *testing.T) {
+	defer currentData.Store(getData())
+	resetInit()
+	require.NoError(t, LoadUnicodeData(strings.NewReader(testEmojiTest), strings.NewReader(testVariationSequences)))
+	assert.NoError(t, Init())
+	assert.Equal(t, "16.0", UnicodeVersion())
+}
+
+func TestEmbeddedNames(t *testing.T) {
+	var emojis []string
+	require.NoError(t, json.Unmarshal(fullyQualifiedEmojisJSON, &emojis))
+	names := parseEmbeddedNames()
+	assert.Len(t, names, len(emojis))
+	for _, emoji := range emojis {
+		name, ok := Name(emoji)
+		assert.True(t, ok, "Name(%+q)", emoji)
+		assert.NotEmpty(t, name, "Name(%+q)", emoji)
+		// Unqualified and minimally-qualified forms must have the same name
+		name2, _ := Name(RemoveAll(emoji))
+		assert.Equal(t, name, name2, "Name(%+q)", RemoveAll(emoji))
+		// Names must be unique, so that ByName is the inverse of Name
+		byName, ok := ByName(strings.ToUpper(name))
+		assert.True(t, ok, "ByName(%q)", name)
+		assert.Equal(t, emoji, byName, "ByName(%q)", name)
+	}
+}
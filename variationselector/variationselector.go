@@ -5,89 +5,508 @@
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
 // Package variationselector provides utility functions for adding and removing emoji variation selectors (16)
-// that matches the suggestions in the Matrix spec.
+// that matches the suggestions in the Matrix spec, as well as text variation selectors (15) for forcing text presentation.
 package variationselector
 
 import (
-	_ "embed"
-	"encoding/json"
+	"bytes"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
-//go:generate ./generate.sh
+var allVariationRemover = strings.NewReplacer(VS15, "", VS16, "")
 
-//go:embed emojis-with-variations.json
-var emojisWithVariationsJSON []byte
-
-//go:embed fully-qualified-variations.json
-var fullyQualifiedVariationsJSON []byte
-
-var variationReplacer, fullyQualifier *strings.Replacer
+// isSequenceComponent returns true for characters that appear in emoji sequences,
+// but aren't emojis by themselves (variation selectors, joiners, keycaps and tags).
+func isSequenceComponent(char rune) bool {
+	switch {
+	case char == vs16, char == zwj, char == keycap:
+		return true
+	case isKeycapBase(char):
+		return true
+	case isTagSpec(char), char == cancelTag:
+		return true
+	default:
+		return false
+	}
+}
 
-// The variation replacer will add incorrect variation selectors before skin tones, this removes those.
-var skinToneReplacer = strings.NewReplacer(
-	"\ufe0f\U0001F3FB", "\U0001F3FB",
-	"\ufe0f\U0001F3FC", "\U0001F3FC",
-	"\ufe0f\U0001F3FD", "\U0001F3FD",
-	"\ufe0f\U0001F3FE", "\U0001F3FE",
-	"\ufe0f\U0001F3FF", "\U0001F3FF",
+const (
+	// VS15 is the text variation selector (U+FE0E), which requests text presentation for the preceding character.
+	VS15 = "\ufe0e"
+	// VS16 is the emoji variation selector (U+FE0F), which requests emoji presentation for the preceding character.
+	VS16 = "\ufe0f"
 )
 
-func init() {
-	var emojisWithVariations []string
-	err := json.Unmarshal(emojisWithVariationsJSON, &emojisWithVariations)
-	if err != nil {
-		panic(err)
-	}
-	replaceInput := make([]string, 2*len(emojisWithVariations))
-	for i, emoji := range emojisWithVariations {
-		replaceInput[i*2] = emoji
-		replaceInput[(i*2)+1] = emoji + VS16
-	}
-	variationReplacer = strings.NewReplacer(replaceInput...)
-
-	var fullyQualifiedVariations []string
-	err = json.Unmarshal(fullyQualifiedVariationsJSON, &fullyQualifiedVariations)
-	if err != nil {
-		panic(err)
-	}
-	replaceInput = make([]string, 2*len(fullyQualifiedVariations))
-	for i, emoji := range fullyQualifiedVariations {
-		replaceInput[i*2] = strings.ReplaceAll(emoji, VS16, "")
-		replaceInput[(i*2)+1] = emoji
-	}
-	fullyQualifier = strings.NewReplacer(replaceInput...)
+// IsEmoji checks if the given rune is an emoji character.
+//
+// All characters that are a part of a fully-qualified emoji in emoji-test.txt are considered emojis,
+// which includes regional indicators (flag components) and skin tone modifiers. Digits, # and * are
+// not considered emojis, as they're only emojis when followed by a keycap.
+func IsEmoji(char rune) bool {
+	_, ok := getData().emojiRunes[char]
+	return ok
 }
 
-const VS16 = "\ufe0f"
-
 // Add adds emoji variation selectors to all emojis that have multiple forms in the given string.
 //
 // Variation selectors will be added to everything that is allowed to have both a text presentation and
 // an emoji presentation according to Unicode Technical Standard #51.
 // If you only want to add variation selectors necessary for fully-qualified forms, use FullyQualify instead.
 //
+// Digits, # and * only get a variation selector when they're followed by a keycap (U+20E3), in which case
+// the selector is placed between the character and the keycap.
+//
 // This method uses data from emoji-variation-sequences.txt in the official Unicode emoji data set.
 //
-// This will remove all variation selectors first to make sure it doesn't add duplicates.
+// This will remove all variation selectors (including text variation selectors) first to make sure it doesn't add duplicates.
 func Add(val string) string {
-	return skinToneReplacer.Replace(variationReplacer.Replace(Remove(val)))
+	return AddWithOptions(val, defaultOptions)
+}
+
+// AppendAdd appends the result of Add to dst and returns the extended buffer.
+//
+// This works like the Append functions in strconv, so callers can reuse buffers between calls.
+// If the string doesn't need any changes, it's appended as-is without any extra allocations.
+func AppendAdd(dst []byte, val string) []byte {
+	buf, changed := appendAdd(dst, getData(), val, defaultOptions, latestEmojiVersion)
+	if !changed {
+		return append(dst, val...)
+	}
+	return buf
+}
+
+func add(data *unicodeData, val string, opts Options, maxVersion EmojiVersion) string {
+	buf, changed := appendAdd(nil, data, val, opts, maxVersion)
+	if !changed {
+		return val
+	}
+	return string(buf)
+}
+
+// appendAdd appends val to dst with emoji variation selectors added. If val doesn't need any changes,
+// dst is returned unmodified and the second return value is false, so the caller can use val directly.
+func appendAdd(dst []byte, data *unicodeData, val string, opts Options, maxVersion EmojiVersion) ([]byte, bool) {
+	changed := false
+	copied := 0
+	inFlag := false
+	prev := utf8.RuneError
+	for i := 0; i < len(val); {
+		// Fast path: skip runs of ASCII characters that can't have variation selectors
+		if val[i] < utf8.RuneSelf && !isKeycapBase(rune(val[i])) {
+			prev = rune(val[i])
+			i++
+			continue
+		}
+		char, size := utf8.DecodeRuneInString(val[i:])
+		charEnd := i + size
+		if char == vs15 || char == vs16 {
+			// Variation selectors that don't follow a character are always removed
+			charEnd = i
+		} else {
+			inFlag = isFlag(char) || (inFlag && (char == zwj || prev == zwj))
+			prev = char
+		}
+		selectorEnd, selectors := addSelectors(data, val, char, charEnd, opts, inFlag, maxVersion)
+		if val[charEnd:selectorEnd] != selectors {
+			if !changed {
+				dst = slices.Grow(dst, len(val)+len(val)/4)
+				changed = true
+			}
+			dst = append(dst, val[copied:charEnd]...)
+			dst = append(dst, selectors...)
+			copied = selectorEnd
+		}
+		i = selectorEnd
+	}
+	if changed {
+		dst = append(dst, val[copied:]...)
+	}
+	return dst, changed
 }
 
-// Remove removes all emoji variation selectors in the given string.
+// addSelectors finds the variation selectors after the character that ends at val[charEnd] and decides which
+// selectors Add should output after the character instead. It returns the end index of the existing selectors and
+// the new selectors, which are the same as the existing ones if nothing needs to be changed.
+//
+// The decision depends on the character after the selectors, so it must be included in val if there is one.
+func addSelectors(data *unicodeData, val string, char rune, charEnd int, opts Options, inFlag bool, maxVersion EmojiVersion) (int, string) {
+	selectorEnd := charEnd
+	for strings.HasPrefix(val[selectorEnd:], VS15) || strings.HasPrefix(val[selectorEnd:], VS16) {
+		selectorEnd += len(VS16)
+	}
+	if _, ok := data.variationRunes[char]; !ok || data.runeVersions[char] > maxVersion {
+		return selectorEnd, ""
+	} else if !opts.includes(data, char, inFlag) {
+		// Characters excluded by the options are left as-is, including any existing variation selectors.
+		return selectorEnd, val[charEnd:selectorEnd]
+	}
+	next, _ := utf8.DecodeRuneInString(val[selectorEnd:])
+	if isKeycapBase(char) && next != keycap {
+		// Keycap bases are only emojis as a part of a keycap sequence, where the selector goes before the keycap.
+		return selectorEnd, ""
+	} else if isSkinTone(next) {
+		// Skin tone modifiers replace the variation selector, so don't add one if the next character is a modifier.
+		return selectorEnd, ""
+	}
+	return selectorEnd, VS16
+}
+
+// Remove removes all emoji variation selectors (VS16, U+FE0F) in the given string.
+//
+// Text variation selectors (VS15, U+FE0E) are left as-is, so e.g. a text presentation smiley stays as text.
+// Use RemoveAll to remove both kinds.
 func Remove(val string) string {
 	return strings.ReplaceAll(val, VS16, "")
 }
 
+// RemoveQualified removes emoji variation selectors that follow characters which have an emoji variation sequence,
+// as well as misplaced selectors after other emoji characters and keycaps.
+//
+// Unlike Remove, this doesn't touch variation selectors after other characters (e.g. ones that a user typed after
+// a CJK character), so it's safe to use on arbitrary text. For emojis, the result is the same as with Remove.
+func RemoveQualified(val string) string {
+	if !strings.Contains(val, VS16) {
+		return val
+	}
+	buf := appendRemoveQualified(make([]byte, 0, len(val)), getData(), val)
+	return bytesAsString(buf)
+}
+
+func appendRemoveQualified(dst []byte, data *unicodeData, val string) []byte {
+	prev := utf8.RuneError
+	for i := 0; i < len(val); {
+		char, size := utf8.DecodeRuneInString(val[i:])
+		if char != vs16 || !data.isQualifiable(prev) {
+			dst = append(dst, val[i:i+size]...)
+			prev = char
+		}
+		i += size
+	}
+	return dst
+}
+
+// AddTextPresentation adds text variation selectors to all emojis that have multiple forms in the given string.
+//
+// This is the opposite of Add: it forces text presentation for everything that is allowed to have both
+// a text presentation and an emoji presentation according to Unicode Technical Standard #51.
+// This is mostly useful when bridging to networks or clients that can only display plain text.
+//
+// Text variation selectors are not added to characters that are a part of a zero-width joiner sequence
+// or have a skin tone modifier, as those sequences can only be displayed as emojis. Digits, # and * only
+// get a variation selector when they're followed by a keycap.
+//
+// This will remove all variation selectors (both text and emoji) first to make sure it doesn't add duplicates,
+// which means the output of Add can be passed to this function and vice versa to toggle between the two styles.
+func AddTextPresentation(val string) string {
+	val = RemoveAll(val)
+	data := getData()
+	var buf strings.Builder
+	buf.Grow(len(val))
+	var prev rune
+	for i := 0; i < len(val); {
+		char, size := utf8.DecodeRuneInString(val[i:])
+		// Copy the original bytes rather than the decoded rune to keep invalid UTF-8 as-is
+		buf.WriteString(val[i : i+size])
+		i += size
+		if _, ok := data.variationRunes[char]; !ok || prev == zwj {
+			prev = char
+			continue
+		}
+		prev = char
+		next, _ := utf8.DecodeRuneInString(val[i:])
+		if next == zwj || isSkinTone(next) || (isKeycapBase(char) && next != keycap) {
+			continue
+		}
+		buf.WriteString(VS15)
+	}
+	return buf.String()
+}
+
+// RemoveTextPresentation removes all text variation selectors (VS15, U+FE0E) in the given string.
+// Emoji variation selectors are left as-is.
+func RemoveTextPresentation(val string) string {
+	return strings.ReplaceAll(val, VS15, "")
+}
+
+// TogglePresentation switches a single emoji between emoji and text presentation.
+//
+// If the emoji has an emoji variation selector, or it doesn't have a selector and is displayed as an emoji by default,
+// it's switched to text presentation with a text variation selector. Otherwise, it's switched to emoji presentation.
+// Keycap sequences (e.g. U+0031 U+20E3) are toggled the same way. Emojis that only have one presentation (e.g. ones without
+// variation sequences, skin tone or zero-width joiner sequences) and non-emoji strings are returned as-is.
+func TogglePresentation(emoji string) string {
+	char, size := utf8.DecodeRuneInString(emoji)
+	data := getData()
+	if _, ok := data.variationRunes[char]; !ok {
+		return emoji
+	}
+	selectorEnd := size
+	for strings.HasPrefix(emoji[selectorEnd:], VS15) || strings.HasPrefix(emoji[selectorEnd:], VS16) {
+		selectorEnd += len(VS16)
+	}
+	rest := emoji[selectorEnd:]
+	if (isKeycapBase(char) && rest != "\u20e3") || (!isKeycapBase(char) && rest != "") {
+		return emoji
+	}
+	var isEmojiPresentation bool
+	switch emoji[size:selectorEnd] {
+	case VS16:
+		isEmojiPresentation = true
+	case VS15:
+		isEmojiPresentation = false
+	default:
+		_, isTextDefault := data.textDefaultRunes[char]
+		// Keycap bases are digits and symbols, which are always text by default
+		isEmojiPresentation = !isTextDefault && !isKeycapBase(char)
+	}
+	if isEmojiPresentation {
+		return emoji[:size] + VS15 + rest
+	}
+	return emoji[:size] + VS16 + rest
+}
+
+// RemoveAll removes both emoji (VS16, U+FE0F) and text (VS15, U+FE0E) variation selectors in the given string.
+//
+// This is useful for normalizing emojis regardless of the requested presentation. Other variation selectors
+// (e.g. the ones used for CJK ideographs) are not removed.
+func RemoveAll(val string) string {
+	// strings.Replacer allocates even if there's nothing to replace, so check for the common case of no selectors first
+	if !strings.Contains(val, VS15) && !strings.Contains(val, VS16) {
+		return val
+	}
+	return allVariationRemover.Replace(val)
+}
+
+// NormalizeForComparison returns a form of the given string that is suitable for comparing emojis,
+// e.g. as a map key when deduplicating reactions.
+//
+// Currently, this removes all variation selectors (both text and emoji), so the output is not meant for display.
+func NormalizeForComparison(val string) string {
+	return RemoveAll(val)
+}
+
+// CanonicalOptions specifies optional normalization steps for CanonicalizeWithOptions.
+type CanonicalOptions struct {
+	// StripSkinTone removes skin tone modifiers, so e.g. U+1F44D and U+1F44D U+1F3FD get the same canonical form.
+	StripSkinTone bool
+}
+
+// Canonicalize converts the given string into a canonical form that is suitable as a map key for emojis,
+// e.g. when counting reactions. Strings that are the same emoji apart from variation selectors produce the same
+// canonical string, and the output is always valid to display.
+//
+// Specifically, the canonical form is created by:
+//
+//  1. removing all emoji and text variation selectors, so emoji and text presentations are treated as the same emoji,
+//  2. removing skin tone modifiers if enabled in the options (see RemoveSkinTone),
+//  3. converting emojis to the fully-qualified form (see FullyQualify), which also puts variation selectors and
+//     skin tone modifiers in the standard order within sequences (e.g. U+1F44D U+FE0F U+1F3FD becomes
+//     U+1F44D U+1F3FD).
+//
+// Non-emoji text is only affected by the first step. The output is idempotent, i.e. canonicalizing a canonical
+// string doesn't change it.
+func Canonicalize(val string) string {
+	return CanonicalizeWithOptions(val, CanonicalOptions{})
+}
+
+// CanonicalizeWithOptions converts the given string into a canonical form like Canonicalize,
+// with the optional normalization steps enabled in the given options.
+func CanonicalizeWithOptions(val string, opts CanonicalOptions) string {
+	val = RemoveAll(val)
+	if opts.StripSkinTone {
+		val = RemoveSkinTone(val)
+	}
+	return FullyQualify(val)
+}
+
+// Equal checks if the two strings are equal when ignoring all emoji and text variation selectors,
+// i.e. it's equivalent to `RemoveAll(a) == RemoveAll(b)`, but compares the strings in place without allocating.
+//
+// This is useful for comparing emojis from different sources, as the same emoji may or may not have
+// a variation selector depending on the client that sent it.
+func Equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var i, j int
+	for {
+		i = skipVariationSelectors(a, i)
+		j = skipVariationSelectors(b, j)
+		if i >= len(a) || j >= len(b) {
+			return i >= len(a) && j >= len(b)
+		} else if a[i] != b[j] {
+			return false
+		}
+		i++
+		j++
+	}
+}
+
+// skipVariationSelectors returns the index of the first byte at or after i that isn't a part of VS15 or VS16.
+//
+// The selectors are matched byte by byte: the first byte of their UTF-8 encoding can't be a continuation byte,
+// so a match is always at the start of a character.
+func skipVariationSelectors(val string, i int) int {
+	for i+len(VS16) <= len(val) && (val[i:i+len(VS16)] == VS16 || val[i:i+len(VS15)] == VS15) {
+		i += len(VS16)
+	}
+	return i
+}
+
+// EqualIgnoreVariation checks if the two strings are equal when ignoring variation selectors.
+// It's the same as Equal.
+func EqualIgnoreVariation(a, b string) bool {
+	return Equal(a, b)
+}
+
 // FullyQualify converts all emojis to their fully-qualified form by adding variation selectors where necessary.
 //
 // This will not add variation selectors to all possible emojis, only the ones that require a variation selector
 // to be "fully qualified" according to Unicode Technical Standard #51.
 // If you want to add variation selectors in all allowed cases, use Add instead.
 //
+// Existing emoji variation selectors are removed using RemoveQualified, so variation selectors after
+// non-emoji characters are preserved.
+//
 // This method uses data from emoji-test.txt in the official Unicode emoji data set.
 //
 // N.B. This method is not currently used by the Matrix spec, but it is included as bridging to other networks may need it.
 func FullyQualify(val string) string {
-	return fullyQualifier.Replace(Remove(val))
+	return fullyQualify(getData(), val, latestEmojiVersion)
+}
+
+// AppendFullyQualify appends the result of FullyQualify to dst and returns the extended buffer.
+//
+// This works like the Append functions in strconv, so callers can reuse buffers between calls.
+// If the string is already fully qualified, it's appended as-is without any extra allocations.
+func AppendFullyQualify(dst []byte, val string) []byte {
+	buf, changed := appendFullyQualify(dst, getData(), val, latestEmojiVersion)
+	if !changed {
+		return append(dst, val...)
+	}
+	return buf
+}
+
+// MinimallyQualify converts all emojis to their minimally-qualified form, where the first character of each emoji
+// has a variation selector if it needs one, but the rest of the sequence doesn't have any.
+//
+// For emojis made of a single character (optionally with a skin tone modifier) and keycap sequences, the
+// minimally-qualified form is the same as the fully-qualified one, e.g. the keycap U+0031 U+FE0F U+20E3 keeps its
+// variation selector. The forms only differ in zero-width joiner sequences with variation selectors after
+// the first element: the fully-qualified couple with heart is U+1F469 U+200D U+2764 U+FE0F U+200D U+1F468,
+// while the minimally-qualified form drops the selector after the heart. Sequences without variation selectors,
+// like the family emoji U+1F468 U+200D U+1F469 U+200D U+1F467, are returned as-is.
+//
+// The output matches the minimally-qualified forms in emoji-test.txt for emojis that have one,
+// and the fully-qualified form for everything else.
+func MinimallyQualify(val string) string {
+	val = FullyQualify(val)
+	if !strings.Contains(val, VS16) {
+		return val
+	}
+	var buf strings.Builder
+	buf.Grow(len(val))
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			buf.WriteString(val)
+			return buf.String()
+		}
+		buf.WriteString(val[:start])
+		buf.WriteString(minimallyQualifySequence(val[start:end]))
+		val = val[end:]
+	}
+}
+
+// minimallyQualifySequence removes variation selectors from a fully-qualified emoji except the one directly after
+// the first character.
+func minimallyQualifySequence(emoji string) string {
+	_, firstEnd := utf8.DecodeRuneInString(emoji)
+	if strings.HasPrefix(emoji[firstEnd:], VS16) {
+		firstEnd += len(VS16)
+	}
+	if !strings.Contains(emoji[firstEnd:], VS16) {
+		return emoji
+	}
+	return emoji[:firstEnd] + strings.ReplaceAll(emoji[firstEnd:], VS16, "")
+}
+
+func fullyQualify(data *unicodeData, val string, maxVersion EmojiVersion) string {
+	buf, changed := appendFullyQualify(nil, data, val, maxVersion)
+	if !changed {
+		return val
+	}
+	return string(buf)
+}
+
+// appendFullyQualify appends the fully-qualified form of val to dst. If val is already fully qualified,
+// dst is returned unmodified and the second return value is false, so the caller can use val directly.
+//
+// The conversion has three steps, which are all done inside dst:
+//  1. Existing emoji variation selectors are removed like in RemoveQualified.
+//  2. Unqualified emojis are replaced with their fully-qualified forms. When multiple emojis match at the same
+//     position, the one that comes first in emoji-test.txt is used.
+//  3. Variation selectors before skin tone modifiers are removed, as the modifier takes their place.
+func appendFullyQualify(dst []byte, data *unicodeData, val string, maxVersion EmojiVersion) ([]byte, bool) {
+	if maxVersion == latestEmojiVersion && isQualifiedText(data, val) {
+		return dst, false
+	}
+	start := len(dst)
+	dst = slices.Grow(dst, len(val)+len(val)/2)
+	src := val
+	if strings.Contains(val, VS16) {
+		dst = appendRemoveQualified(dst, data, val)
+		// The step 1 output is only read after this, new data is always appended after it.
+		src = bytesAsString(dst[start:])
+	}
+	qualifiedStart := len(dst)
+	copied := 0
+	for i := 0; i < len(src); {
+		// Fast path: the only ASCII characters that can start an emoji are keycap bases
+		if src[i] < utf8.RuneSelf && !isKeycapBase(rune(src[i])) {
+			i++
+			continue
+		}
+		char, size := utf8.DecodeRuneInString(src[i:])
+		if form, ok := data.findQualifiedForm(src[i:], char, maxVersion); ok {
+			dst = append(dst, src[copied:i]...)
+			dst = append(dst, form.qualified...)
+			i += len(form.unqualified)
+			copied = i
+		} else {
+			i += size
+		}
+	}
+	dst = append(dst, src[copied:]...)
+	if qualifiedStart != start {
+		dst = append(dst[:start], dst[qualifiedStart:]...)
+	}
+	out := start
+	for i := start; i < len(dst); i++ {
+		if dst[i] == vs16Bytes[0] && bytes.HasPrefix(dst[i:], vs16Bytes) {
+			if next, _ := utf8.DecodeRune(dst[i+len(VS16):]); isSkinTone(next) {
+				i += len(VS16) - 1
+				continue
+			}
+		}
+		dst[out] = dst[i]
+		out++
+	}
+	return dst[:out], true
+}
+
+// isQualifiedText checks if FullyQualify would return the given string unchanged, i.e. all emojis in it are
+// fully qualified and there are no emoji variation selectors outside emojis.
+func isQualifiedText(data *unicodeData, val string) bool {
+	for {
+		start, end := nextEmoji(val)
+		if start < 0 {
+			return !strings.Contains(val, VS16)
+		} else if strings.Contains(val[:start], VS16) || !isSequenceFullyQualified(data, val[start:end]) {
+			return false
+		}
+		val = val[end:]
+	}
 }
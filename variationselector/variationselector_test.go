@@ -8,10 +8,16 @@ package variationselector_test
 
 import (
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/transform"
 
 	"go.mau.fi/util/variationselector"
 )
@@ -35,6 +41,8 @@ func TestFullyQualify(t *testing.T) {
 	assert.Equal(t, "\u263a\ufe0f", variationselector.FullyQualify("\u263a"))
 	assert.Equal(t, "\U0001f3f3\ufe0f\u200D\U0001f308", variationselector.FullyQualify("\U0001f3f3\u200D\U0001f308"))
 	assert.Equal(t, "\U0001f3f3\ufe0f\u200D\U0001f308", variationselector.FullyQualify("\U0001f3f3\ufe0f\u200D\U0001f308"))
+	assert.Equal(t, "\U0001f590\U0001f3fb", variationselector.FullyQualify("\U0001f590\U0001f3fb"))
+	assert.Equal(t, "\U0001f590\U0001f3fb", variationselector.FullyQualify("\U0001f590\ufe0f\U0001f3fb"))
 }
 
 func TestRemove(t *testing.T) {
@@ -43,6 +51,81 @@ func TestRemove(t *testing.T) {
 	assert.Equal(t, "4\u20e3", variationselector.Remove("4\u20e3"))
 	assert.Equal(t, "4\u20e3", variationselector.Remove("4\ufe0f\u20e3"))
 	assert.Equal(t, "\U0001f914", variationselector.Remove("\U0001f914"))
+	assert.Equal(t, "\u263a\ufe0e", variationselector.Remove("\u263a\ufe0e"), "text variation selectors must be kept")
+}
+
+func TestTogglePresentation(t *testing.T) {
+	for input, expected := range map[string]string{
+		"\u263a":                       "\u263a\ufe0f",
+		"\u263a\ufe0f":                 "\u263a\ufe0e",
+		"\u263a\ufe0e":                 "\u263a\ufe0f",
+		"\u231a":                       "\u231a\ufe0e",
+		"\u231a\ufe0e":                 "\u231a\ufe0f",
+		"1\u20e3":                      "1\ufe0f\u20e3",
+		"1\ufe0f\u20e3":                "1\ufe0e\u20e3",
+		"1":                            "1",
+		"\U0001f914":                   "\U0001f914",
+		"\U0001f44d\U0001f3fd":         "\U0001f44d\U0001f3fd",
+		"\u2764\ufe0f\u200d\U0001f525": "\u2764\ufe0f\u200d\U0001f525",
+		"hello":                        "hello",
+		"":                             "",
+	} {
+		assert.Equal(t, expected, variationselector.TogglePresentation(input), "TogglePresentation(%+q)", input)
+	}
+	assert.Equal(t, "\u263a\ufe0f", variationselector.TogglePresentation(variationselector.TogglePresentation("\u263a\ufe0f")))
+}
+
+func TestAddTextPresentation(t *testing.T) {
+	assert.Equal(t, "\u263a\ufe0e", variationselector.AddTextPresentation("\u263a"))
+	assert.Equal(t, "\u263a\ufe0e", variationselector.AddTextPresentation("\u263a\ufe0f"))
+	assert.Equal(t, "\u263a\ufe0e", variationselector.AddTextPresentation("\u263a\ufe0e"))
+	assert.Equal(t, "\U0001f44d\ufe0e", variationselector.AddTextPresentation("\U0001f44d"))
+	assert.Equal(t, "\U0001f44d\U0001f3fd", variationselector.AddTextPresentation("\U0001f44d\U0001f3fd"))
+	assert.Equal(t, "\U0001f914", variationselector.AddTextPresentation("\U0001f914"))
+	assert.Equal(t, "\u2708\ufe0e", variationselector.AddTextPresentation("\u2708"))
+	assert.Equal(t, "\u2708\ufe0e", variationselector.AddTextPresentation("\u2708\ufe0f"))
+	assert.Equal(t, "\u261d\U0001f3fb", variationselector.AddTextPresentation("\u261d\ufe0f\U0001f3fb"))
+	assert.Equal(t, "4\ufe0e\u20e3", variationselector.AddTextPresentation("4\ufe0f\u20e3"))
+	assert.Equal(t, "2024", variationselector.AddTextPresentation("2024"))
+	// Text variation selectors must not be added inside zero-width joiner sequences
+	assert.Equal(t, "\U0001f3f3\u200d\U0001f308", variationselector.AddTextPresentation("\U0001f3f3\ufe0f\u200d\U0001f308"))
+	assert.Equal(t, "\U0001f468\u200d\u2764\u200d\U0001f468", variationselector.AddTextPresentation("\U0001f468\u200d\u2764\ufe0f\u200d\U0001f468"))
+	assert.Equal(t, "\U0001f3f3\u200d\u26a7", variationselector.AddTextPresentation("\U0001f3f3\ufe0f\u200d\u26a7\ufe0f"))
+}
+
+func TestAddTextPresentation_RoundTrip(t *testing.T) {
+	for _, input := range []string{"\u263a", "\u2708\ufe0f", "\U0001f44d", "4\ufe0f\u20e3"} {
+		assert.Equal(t, variationselector.Add(input), variationselector.Add(variationselector.AddTextPresentation(input)))
+	}
+	assert.Equal(t, "\u263a\ufe0f", variationselector.Add("\u263a\ufe0e"))
+}
+
+func TestRemoveTextPresentation(t *testing.T) {
+	assert.Equal(t, "\u263a", variationselector.RemoveTextPresentation("\u263a\ufe0e"))
+	assert.Equal(t, "\u263a\ufe0f", variationselector.RemoveTextPresentation("\u263a\ufe0f"))
+}
+
+func TestRemoveAll(t *testing.T) {
+	assert.Equal(t, "\u263a", variationselector.RemoveAll("\u263a\ufe0e"))
+	assert.Equal(t, "\u263a", variationselector.RemoveAll("\u263a\ufe0f"))
+	assert.Equal(t, "4\u20e3", variationselector.RemoveAll("4\ufe0f\u20e3"))
+	assert.Equal(t, "\U0001f914", variationselector.RemoveAll("\U0001f914"))
+	assert.Equal(t, "\u263a \u263a \u263a", variationselector.RemoveAll("\u263a\ufe0e \u263a\ufe0f \u263a"))
+	assert.Equal(t, "\u845b\U000e0100", variationselector.RemoveAll("\u845b\U000e0100"), "ideographic variation selectors must be kept")
+}
+
+func TestIsEmoji(t *testing.T) {
+	assert.True(t, variationselector.IsEmoji('\U0001f600'))
+	assert.True(t, variationselector.IsEmoji('\U0001f44d'))
+	assert.True(t, variationselector.IsEmoji('\u263a'))
+	assert.True(t, variationselector.IsEmoji('\U0001f1e8'))
+	assert.True(t, variationselector.IsEmoji('\U0001f3fd'))
+	assert.False(t, variationselector.IsEmoji('a'))
+	assert.False(t, variationselector.IsEmoji('4'))
+	assert.False(t, variationselector.IsEmoji('#'))
+	assert.False(t, variationselector.IsEmoji('\ufe0f'))
+	assert.False(t, variationselector.IsEmoji('\u200d'))
+	assert.False(t, variationselector.IsEmoji('\u20e3'))
 }
 
 func ExampleAdd() {
@@ -80,3 +163,932 @@ func ExampleRemove() {
 	// "\U0001f44d"
 	// "\U0001f44d"
 }
+
+func TestContainsEmoji(t *testing.T) {
+	assert.True(t, variationselector.ContainsEmoji("hello \U0001f44d"))
+	assert.True(t, variationselector.ContainsEmoji("\u263a"))
+	assert.True(t, variationselector.ContainsEmoji("flag: \U0001f1eb\U0001f1ee"))
+	assert.True(t, variationselector.ContainsEmoji("key 1\ufe0f\u20e3"))
+	assert.True(t, variationselector.ContainsEmoji("\U0001f44d\U0001f3fd"))
+	assert.True(t, variationselector.ContainsEmoji("\u263a\ufe0e"))
+	assert.True(t, variationselector.ContainsEmoji("\U0001f600 and then a lot of text after the emoji"))
+	assert.False(t, variationselector.ContainsEmoji(""))
+	assert.False(t, variationselector.ContainsEmoji("\u00e5\u00e4\u00f6 \u4e2d\u6587 \u00a0"))
+	assert.False(t, variationselector.ContainsEmoji("hello world 123 #*"))
+}
+
+func TestIsSingleEmoji(t *testing.T) {
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f44d"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f44d\ufe0f"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f44d\U0001f3fd"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f1eb\U0001f1ee"))
+	assert.True(t, variationselector.IsSingleEmoji("1\ufe0f\u20e3"))
+	assert.True(t, variationselector.IsSingleEmoji("1\u20e3"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f468\u200d\U0001f469\u200d\U0001f467"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f3f3\ufe0f\u200d\U0001f308"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f"))
+	assert.False(t, variationselector.IsSingleEmoji(""))
+	assert.False(t, variationselector.IsSingleEmoji("a"))
+	assert.False(t, variationselector.IsSingleEmoji("1"))
+	assert.False(t, variationselector.IsSingleEmoji("\U0001f44d\U0001f44d"))
+	assert.False(t, variationselector.IsSingleEmoji("\U0001f1eb\U0001f1ee\U0001f1eb\U0001f1ee"))
+	assert.False(t, variationselector.IsSingleEmoji("\U0001f44d\u200d"))
+}
+
+func TestIsSingleEmoji_Reactions(t *testing.T) {
+	assert.True(t, variationselector.IsSingleEmoji("\u2764"))
+	assert.True(t, variationselector.IsSingleEmoji("\u2764\ufe0f"))
+	assert.True(t, variationselector.IsSingleEmoji("\u2764\ufe0f\u200d\U0001f525"))
+	assert.True(t, variationselector.IsSingleEmoji("\u2764\u200d\U0001f525"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff"))
+	assert.False(t, variationselector.IsSingleEmoji(" \u2764\ufe0f"))
+	assert.False(t, variationselector.IsSingleEmoji("\u2764\ufe0f "))
+	assert.False(t, variationselector.IsSingleEmoji("\u2764\ufe0f\n"))
+	assert.False(t, variationselector.IsSingleEmoji("\u2764\ufe0fa"))
+	assert.False(t, variationselector.IsSingleEmoji("\u2764\ufe0f\ufe0f"))
+	assert.False(t, variationselector.IsSingleEmoji("\ufe0f"))
+	assert.False(t, variationselector.IsSingleEmoji("\U0001f3fd\U0001f3fd"))
+}
+
+func BenchmarkContainsEmoji(b *testing.B) {
+	b.Run("PlainText", func(b *testing.B) {
+		input := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 20)
+		for i := 0; i < b.N; i++ {
+			variationselector.ContainsEmoji(input)
+		}
+	})
+	b.Run("EmojiAtEnd", func(b *testing.B) {
+		input := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 20) + "\U0001f44d"
+		for i := 0; i < b.N; i++ {
+			variationselector.ContainsEmoji(input)
+		}
+	})
+}
+
+func TestQualificationLevel(t *testing.T) {
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel(""))
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel("hello"))
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel("\U0001f44d"))
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel("\u263a\ufe0f"))
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel("1\ufe0f\u20e3"))
+	assert.Equal(t, variationselector.FullyQualified, variationselector.QualificationLevel("\U0001f441\ufe0f\u200d\U0001f5e8\ufe0f"))
+	assert.Equal(t, variationselector.Unqualified, variationselector.QualificationLevel("\u263a"))
+	assert.Equal(t, variationselector.Unqualified, variationselector.QualificationLevel("1\u20e3"))
+	assert.Equal(t, variationselector.Unqualified, variationselector.QualificationLevel("\U0001f441\u200d\U0001f5e8\ufe0f"))
+	assert.Equal(t, variationselector.MinimallyQualified, variationselector.QualificationLevel("\U0001f441\ufe0f\u200d\U0001f5e8"))
+	assert.Equal(t, variationselector.MinimallyQualified, variationselector.QualificationLevel("\U0001f9d4\u200d\u2642"))
+	assert.Equal(t, variationselector.MinimallyQualified, variationselector.QualificationLevel("\U0001f44d\ufe0f"))
+	// The lowest level is returned for strings with multiple emojis
+	assert.Equal(t, variationselector.MinimallyQualified, variationselector.QualificationLevel("\U0001f44d \U0001f9d4\u200d\u2642 \u263a\ufe0f"))
+	assert.Equal(t, variationselector.Unqualified, variationselector.QualificationLevel("\U0001f9d4\u200d\u2642 \u263a"))
+}
+
+func TestQualificationLevel_EmojiTestStatuses(t *testing.T) {
+	// Examples of each status from emoji-test.txt
+	for emoji, expected := range map[string]variationselector.Level{
+		"\U0001f636\u200d\U0001f32b\ufe0f":           variationselector.FullyQualified,
+		"\U0001f636\u200d\U0001f32b":                 variationselector.MinimallyQualified,
+		"\U0001f3f3\ufe0f\u200d\u26a7\ufe0f":         variationselector.FullyQualified,
+		"\U0001f3f3\ufe0f\u200d\u26a7":               variationselector.MinimallyQualified,
+		"\U0001f3f3\u200d\u26a7\ufe0f":               variationselector.Unqualified,
+		"\U0001f3f3\u200d\u26a7":                     variationselector.Unqualified,
+		"\U0001f468\u200d\U0001f469\u200d\U0001f467": variationselector.FullyQualified,
+		"#\ufe0f\u20e3":                              variationselector.FullyQualified,
+		"#\u20e3":                                    variationselector.Unqualified,
+		"\U0001f3cc\U0001f3fb\u200d\u2642\ufe0f":     variationselector.FullyQualified,
+		"\U0001f3cc\U0001f3fb\u200d\u2642":           variationselector.MinimallyQualified,
+	} {
+		assert.Equal(t, expected, variationselector.QualificationLevel(emoji), "QualificationLevel(%+q)", emoji)
+	}
+}
+
+func TestIsFullyQualified(t *testing.T) {
+	assert.True(t, variationselector.IsFullyQualified("hi \u263a\ufe0f \U0001f3f3\ufe0f\u200d\U0001f308"))
+	assert.False(t, variationselector.IsFullyQualified("hi \u263a"))
+	assert.False(t, variationselector.IsFullyQualified("\U0001f3f3\u200d\U0001f308"))
+	assert.True(t, variationselector.IsFullyQualified(""))
+	assert.True(t, variationselector.IsFullyQualified("plain text 123"))
+	assert.True(t, variationselector.IsFullyQualified("\U0001f590\U0001f3fb 1\ufe0f\u20e3"))
+	assert.False(t, variationselector.IsFullyQualified("\U0001f590\ufe0f\U0001f3fb"))
+	assert.False(t, variationselector.IsFullyQualified("1\u20e3"))
+	assert.False(t, variationselector.IsFullyQualified("\U0001f44d\ufe0f"))
+	assert.False(t, variationselector.IsFullyQualified("\u263a\ufe0e"))
+	assert.False(t, variationselector.IsFullyQualified("\u2764\u200d\U0001f680"))
+}
+
+func TestExtractEmojis(t *testing.T) {
+	assert.Empty(t, variationselector.ExtractEmojis(""))
+	assert.Empty(t, variationselector.ExtractEmojis("no emojis here 123"))
+	assert.Equal(t, []string{"\U0001f44d", "\u263a\ufe0f"}, variationselector.ExtractEmojis("a \U0001f44d\ufe0f b \u263a c"))
+	assert.Equal(t, []string{"\U0001f44d", "\U0001f44d\U0001f3fd", "\U0001f1eb\U0001f1ee"}, variationselector.ExtractEmojis("\U0001f44d\U0001f44d\U0001f3fd\U0001f1eb\U0001f1ee"))
+	assert.Equal(t, []string{"\U0001f468\u200d\U0001f469\u200d\U0001f467", "1\ufe0f\u20e3"}, variationselector.ExtractEmojis("family: \U0001f468\u200d\U0001f469\u200d\U0001f467, key: 1\u20e3"))
+}
+
+func TestStripEmojis(t *testing.T) {
+	assert.Equal(t, "", variationselector.StripEmojis(""))
+	assert.Equal(t, "no emojis here 123", variationselector.StripEmojis("no emojis here 123"))
+	assert.Equal(t, "a  b  c", variationselector.StripEmojis("a \U0001f44d\ufe0f b \u263a c"))
+	assert.Equal(t, "", variationselector.StripEmojis("\U0001f44d\U0001f44d\U0001f3fd\U0001f1eb\U0001f1ee"))
+	assert.Equal(t, "family: , key: ", variationselector.StripEmojis("family: \U0001f468\u200d\U0001f469\u200d\U0001f467, key: 1\ufe0f\u20e3"))
+}
+
+func TestReplaceEmojis(t *testing.T) {
+	input := "ok \U0001f44d\U0001f3fd then \U0001f1eb\U0001f1f7!"
+	assert.Equal(t, "ok  then !", variationselector.ReplaceEmojis(input, ""))
+	assert.Equal(t, "ok [emoji] then [emoji]!", variationselector.ReplaceEmojis(input, "[emoji]"))
+	assert.Equal(t, "family: ?", variationselector.ReplaceEmojis("family: \U0001f468\u200d\U0001f469\u200d\U0001f467", "?"))
+	assert.Equal(t, "??", variationselector.ReplaceEmojis("1\ufe0f\u20e3\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", "?"))
+	assert.Equal(t, "cafe\u0301 \u2605 \u2192 ?", variationselector.ReplaceEmojis("cafe\u0301 \u2605 \u2192 \u263a\ufe0f", "?"))
+	assert.Equal(t, "no emojis 123", variationselector.ReplaceEmojis("no emojis 123", "?"))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 0, variationselector.Count(""))
+	assert.Equal(t, 0, variationselector.Count("hello 123"))
+	assert.Equal(t, 2, variationselector.Count("a\U0001f600b\U0001f468\u200d\U0001f469\u200d\U0001f467c"))
+	assert.Equal(t, 3, variationselector.Count("\U0001f44d\U0001f3fd1\ufe0f\u20e3\U0001f1eb\U0001f1ee"))
+	assert.Equal(t, 1, variationselector.Count("\U0001f44d\u200d"))
+	assert.Equal(t, 2, variationselector.Count("\U0001f44d\u200d\u200d\U0001f44d"))
+	assert.Equal(t, 1, variationselector.Count("\U0001f3fd"))
+	assert.Equal(t, 0, variationselector.Count("\ufe0f\u200d"))
+}
+
+func TestEqualIgnoreVariation(t *testing.T) {
+	assert.True(t, variationselector.EqualIgnoreVariation("\U0001f44d", "\U0001f44d\ufe0f"))
+	assert.True(t, variationselector.EqualIgnoreVariation("\u263a\ufe0e", "\u263a\ufe0f"))
+	assert.True(t, variationselector.EqualIgnoreVariation("4\ufe0f\u20e3", "4\u20e3"))
+	assert.False(t, variationselector.EqualIgnoreVariation("\U0001f44d", "\U0001f44d\U0001f3fd"))
+	assert.False(t, variationselector.EqualIgnoreVariation("\u263a", "\U0001f600"))
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, variationselector.Equal("", ""))
+	assert.True(t, variationselector.Equal("\ufe0f\ufe0e", ""))
+	assert.True(t, variationselector.Equal("a\ufe0f\u263a\ufe0e b", "a\u263a\ufe0f\ufe0f b\ufe0e"))
+	assert.True(t, variationselector.Equal("\U0001f441\ufe0f\u200d\U0001f5e8\ufe0f", "\U0001f441\u200d\U0001f5e8"))
+	assert.False(t, variationselector.Equal("\u263a\ufe0f", "\u263a\ufe0f "))
+	assert.False(t, variationselector.Equal("\u263a", ""))
+	assert.False(t, variationselector.Equal("\ufe0f", "\ufe00"))
+	assert.False(t, variationselector.Equal("\U0001f44d\ufe0f", "\U0001f44e\ufe0f"))
+	for _, pair := range [][2]string{
+		{"\U0001f44d\ufe0f\U0001f3fd", "\U0001f44d\U0001f3fd"},
+		{"\u263a\ufe0e \u263a\ufe0f", "\u263a \u263a"},
+		{"\U0001f44d\ufe0f", "\U0001f44e\ufe0f"},
+	} {
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.Equal(pair[0], pair[1])
+		}), "Equal(%+q, %+q)", pair[0], pair[1])
+	}
+}
+
+func FuzzEqual_CompareWithRemoveAll(f *testing.F) {
+	f.Add("\u263a\ufe0f", "\u263a")
+	f.Add("\ufe0f\ufe0e\ufe0f", "\xef\xb8")
+	f.Add("a\xef\ufe0f", "a\xef")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		expected := variationselector.RemoveAll(a) == variationselector.RemoveAll(b)
+		assert.Equal(t, expected, variationselector.Equal(a, b), "Equal(%+q, %+q)", a, b)
+	})
+}
+
+func TestNormalizeForComparison(t *testing.T) {
+	reactions := map[string]int{}
+	for _, reaction := range []string{"\U0001f44d", "\U0001f44d\ufe0f", "\u263a\ufe0f", "\u263a"} {
+		reactions[variationselector.NormalizeForComparison(reaction)]++
+	}
+	assert.Equal(t, map[string]int{"\U0001f44d": 2, "\u263a": 2}, reactions)
+}
+
+func TestKeycap(t *testing.T) {
+	for _, base := range "0123456789#*" {
+		emoji, ok := variationselector.Keycap(base)
+		assert.True(t, ok)
+		assert.Equal(t, string(base)+"\ufe0f\u20e3", emoji)
+		assert.Equal(t, emoji, variationselector.Add(emoji))
+		assert.Equal(t, emoji, variationselector.FullyQualify(emoji))
+		assert.True(t, variationselector.IsFullyQualified(emoji))
+		// The selector must go between the base and the keycap, not after the whole sequence
+		assert.Equal(t, emoji, variationselector.Add(string(base)+"\u20e3"))
+		assert.Equal(t, emoji, variationselector.FullyQualify(string(base)+"\u20e3"))
+		assert.Equal(t, emoji, variationselector.FullyQualify(string(base)+"\u20e3\ufe0f"))
+		for _, variant := range []string{emoji, string(base) + "\u20e3", string(base) + "\ufe0e\u20e3"} {
+			parsed, ok := variationselector.ParseKeycap(variant)
+			assert.True(t, ok, "ParseKeycap(%+q)", variant)
+			assert.Equal(t, base, parsed, "ParseKeycap(%+q)", variant)
+		}
+	}
+	for _, base := range []rune{'a', ' ', '\u20e3', '\U0001f44d'} {
+		_, ok := variationselector.Keycap(base)
+		assert.False(t, ok, "Keycap(%+q)", base)
+	}
+	for _, input := range []string{"", "1", "1\ufe0f", "\u20e3", "a\u20e3", "1\u20e31\u20e3", "1\ufe0f\u20e3\ufe0f", " 1\u20e3", "1\ufe0f\ufe0f\u20e3"} {
+		_, ok := variationselector.ParseKeycap(input)
+		assert.False(t, ok, "ParseKeycap(%+q)", input)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	for code, expected := range map[string]string{
+		"US": "\U0001f1fa\U0001f1f8",
+		"fi": "\U0001f1eb\U0001f1ee",
+		"Gb": "\U0001f1ec\U0001f1e7",
+		"AZ": "\U0001f1e6\U0001f1ff",
+	} {
+		flag, ok := variationselector.Flag(code)
+		assert.True(t, ok, "Flag(%q)", code)
+		assert.Equal(t, expected, flag, "Flag(%q)", code)
+		assert.Equal(t, 1, variationselector.Count(flag))
+		parsed, ok := variationselector.CountryCode(flag)
+		assert.True(t, ok, "CountryCode(%+q)", flag)
+		assert.Equal(t, strings.ToUpper(code), parsed, "CountryCode(%+q)", flag)
+	}
+	for _, code := range []string{"", "U", "USA", "U1", "@A", "[a", "`a", "{a", "\u00e4a"} {
+		_, ok := variationselector.Flag(code)
+		assert.False(t, ok, "Flag(%q)", code)
+	}
+	for _, flag := range []string{"", "US", "\U0001f1fa", "\U0001f1fa\U0001f1f8\U0001f1fa", "\U0001f1fa\U0001f1f8\ufe0f", "\U0001f1fa\U0001f1f8 ", "\U0001f1fa\U0001f3fb"} {
+		_, ok := variationselector.CountryCode(flag)
+		assert.False(t, ok, "CountryCode(%+q)", flag)
+	}
+}
+
+func TestSubdivisionFlag(t *testing.T) {
+	const england = "\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f"
+	for _, code := range []string{"gbeng", "GBENG", "gbEng"} {
+		flag, ok := variationselector.SubdivisionFlag(code)
+		assert.True(t, ok, "SubdivisionFlag(%q)", code)
+		assert.Equal(t, england, flag, "SubdivisionFlag(%q)", code)
+	}
+	flag, ok := variationselector.SubdivisionFlag("us1a")
+	assert.True(t, ok)
+	assert.Equal(t, "\U0001f3f4\U000e0075\U000e0073\U000e0031\U000e0061\U000e007f", flag)
+	for _, code := range []string{"", "gb", "g1eng", "1beng", "gbengxx", "gb-eng", "gb eng", "gb\u00e9n"} {
+		_, ok = variationselector.SubdivisionFlag(code)
+		assert.False(t, ok, "SubdivisionFlag(%q)", code)
+	}
+
+	code, ok := variationselector.SubdivisionCode(england)
+	assert.True(t, ok)
+	assert.Equal(t, "gbeng", code)
+	for _, input := range []string{"", "\U0001f3f4", "\U0001f3f4\U000e007f", england + " ", "\U0001f3f4\U000e0047\U000e0042\U000e0045\U000e004e\U000e0047\U000e007f", "\U0001f3f4\U000e0067\U000e0062\U000e002d\U000e0065\U000e007f"} {
+		_, ok = variationselector.SubdivisionCode(input)
+		assert.False(t, ok, "SubdivisionCode(%+q)", input)
+	}
+
+	text := "a" + england + "b\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f\U0001f3f4"
+	assert.Equal(t, 3, variationselector.Count(text))
+	assert.Equal(t, []string{england, "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", "\U0001f3f4"}, variationselector.ExtractEmojis(text))
+	// Tag sequences are fully qualified without any variation selectors, and none must be inserted inside them
+	assert.Equal(t, england, variationselector.Add(england))
+	assert.Equal(t, england, variationselector.FullyQualify(england))
+	assert.Equal(t, england, variationselector.FullyQualify("\U0001f3f4\ufe0f\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f"))
+	assert.True(t, variationselector.IsFullyQualified(england))
+}
+
+func TestCanonicalize(t *testing.T) {
+	for expected, inputs := range map[string][]string{
+		"\U0001f44d":                             {"\U0001f44d", "\U0001f44d\ufe0f", "\U0001f44d\ufe0e"},
+		"\U0001f44d\U0001f3fd":                   {"\U0001f44d\U0001f3fd", "\U0001f44d\ufe0f\U0001f3fd", "\U0001f44d\U0001f3fd\ufe0f"},
+		"\u263a\ufe0f":                           {"\u263a", "\u263a\ufe0f", "\u263a\ufe0e"},
+		"1\ufe0f\u20e3":                          {"1\u20e3", "1\ufe0f\u20e3", "1\ufe0e\u20e3"},
+		"\U0001f441\ufe0f\u200d\U0001f5e8\ufe0f": {"\U0001f441\u200d\U0001f5e8", "\U0001f441\ufe0f\u200d\U0001f5e8", "\U0001f441\u200d\U0001f5e8\ufe0f"},
+		"\U0001f3f3\ufe0f\u200d\U0001f308":       {"\U0001f3f3\u200d\U0001f308", "\U0001f3f3\ufe0f\u200d\U0001f308"},
+		"hello \u263a\ufe0f world":               {"hello \u263a world", "hello \u263a\ufe0e world"},
+		"\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466": {"\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"},
+	} {
+		for _, input := range inputs {
+			assert.Equal(t, expected, variationselector.Canonicalize(input), "Canonicalize(%+q)", input)
+		}
+	}
+}
+
+func TestCanonicalizeWithOptions_StripSkinTone(t *testing.T) {
+	opts := variationselector.CanonicalOptions{StripSkinTone: true}
+	reactions := map[string]int{}
+	for _, reaction := range []string{"\U0001f44d", "\U0001f44d\ufe0f", "\U0001f44d\U0001f3fd", "\U0001f44d\ufe0f\U0001f3ff", "\u261d\U0001f3fb", "\u261d"} {
+		reactions[variationselector.CanonicalizeWithOptions(reaction, opts)]++
+	}
+	assert.Equal(t, map[string]int{"\U0001f44d": 4, "\u261d\ufe0f": 2}, reactions)
+	assert.Equal(t, "\U0001f91d", variationselector.CanonicalizeWithOptions("\U0001faf1\U0001f3fb\u200d\U0001faf2\U0001f3fc", opts))
+}
+
+func TestSplit(t *testing.T) {
+	assert.Empty(t, variationselector.Split(""))
+	assert.Equal(t, []variationselector.Segment{{Text: "plain text"}}, variationselector.Split("plain text"))
+	assert.Equal(t, []variationselector.Segment{
+		{Text: "hi "},
+		{Text: "\U0001f44b\U0001f3fd", IsEmoji: true},
+		{Text: "\U0001f468\u200d\U0001f469\u200d\U0001f467", IsEmoji: true},
+		{Text: " and "},
+		{Text: "\u263a", IsEmoji: true},
+	}, variationselector.Split("hi \U0001f44b\U0001f3fd\U0001f468\u200d\U0001f469\u200d\U0001f467 and \u263a"))
+	for _, input := range []string{"a\U0001f600b", "1\ufe0f\u20e323 #", "\U0001f44d\u200d \xff"} {
+		var output strings.Builder
+		for _, segment := range variationselector.Split(input) {
+			output.WriteString(segment.Text)
+		}
+		assert.Equal(t, input, output.String())
+	}
+}
+
+func TestSequences(t *testing.T) {
+	assert.Equal(t, []string{"\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"}, variationselector.Sequences("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"))
+	assert.Equal(t, []string{"\U0001f1e8\U0001f1ff", "\U0001f1e9\U0001f1ea"}, variationselector.Sequences("\U0001f1e8\U0001f1ff\U0001f1e9\U0001f1ea"))
+	assert.Equal(t, []string{"1\ufe0f\u20e3"}, variationselector.Sequences("1\ufe0f\u20e3"))
+	assert.Equal(t, []string{"key ", "1\ufe0f\u20e3", " and ", "\U0001f44d\U0001f3fd", "!"}, variationselector.Sequences("key 1\ufe0f\u20e3 and \U0001f44d\U0001f3fd!"))
+	assert.True(t, variationselector.IsSingleEmoji("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"))
+	assert.False(t, variationselector.IsSingleEmoji("\U0001f1e8\U0001f1ff\U0001f1e9\U0001f1ea"))
+}
+
+func TestGetSkinTone(t *testing.T) {
+	assert.Equal(t, variationselector.ToneMedium, variationselector.GetSkinTone("\U0001f44d\U0001f3fd"))
+	assert.Equal(t, variationselector.ToneDark, variationselector.GetSkinTone("\U0001f9d1\U0001f3ff\u200d\U0001f680"))
+	assert.Equal(t, variationselector.ToneLight, variationselector.GetSkinTone("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff"))
+	assert.Equal(t, variationselector.ToneDefault, variationselector.GetSkinTone("\U0001f44d"))
+	assert.Equal(t, variationselector.ToneDefault, variationselector.GetSkinTone("hello"))
+	assert.Equal(t, variationselector.ToneDefault, variationselector.GetSkinTone(""))
+	assert.Equal(t, variationselector.ToneDefault, variationselector.GetSkinTone("\xff\xfe"))
+}
+
+func TestGetSkinTones(t *testing.T) {
+	assert.Equal(t, []variationselector.SkinTone{variationselector.ToneLight, variationselector.ToneDark}, variationselector.GetSkinTones("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff"))
+	assert.Equal(t, []variationselector.SkinTone{variationselector.ToneMedium}, variationselector.GetSkinTones("\U0001f44d\U0001f3fd"))
+	assert.Empty(t, variationselector.GetSkinTones("\U0001f44d"))
+	assert.Empty(t, variationselector.GetSkinTones("hello"))
+}
+
+func TestSetSkinTone(t *testing.T) {
+	assert.Equal(t, "\U0001f44d\U0001f3fb", variationselector.SetSkinTone("\U0001f44d", variationselector.ToneLight))
+	assert.Equal(t, "\U0001f44d\U0001f3ff", variationselector.SetSkinTone("\U0001f44d\U0001f3fd", variationselector.ToneDark))
+	assert.Equal(t, "\U0001f590\U0001f3fc", variationselector.SetSkinTone("\U0001f590\ufe0f", variationselector.ToneMediumLight))
+	assert.Equal(t, "\U0001f9d1\U0001f3fe\u200d\U0001f680", variationselector.SetSkinTone("\U0001f9d1\u200d\U0001f680", variationselector.ToneMediumDark))
+	assert.Equal(t, "\U0001f469\U0001f3fd\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3fd", variationselector.SetSkinTone("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff", variationselector.ToneMedium))
+	assert.Equal(t, "\U0001f697", variationselector.SetSkinTone("\U0001f697", variationselector.ToneDark))
+	assert.Equal(t, "\U0001f1e8\U0001f1ff", variationselector.SetSkinTone("\U0001f1e8\U0001f1ff", variationselector.ToneDark))
+	assert.Equal(t, "hello", variationselector.SetSkinTone("hello", variationselector.ToneDark))
+}
+
+func TestRemoveSkinTone(t *testing.T) {
+	assert.Equal(t, "\U0001f44d", variationselector.RemoveSkinTone("\U0001f44d\U0001f3fd"))
+	assert.Equal(t, "\U0001f9d1\u200d\U0001f680", variationselector.RemoveSkinTone("\U0001f9d1\U0001f3ff\u200d\U0001f680"))
+	assert.Equal(t, "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468", variationselector.RemoveSkinTone("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff"))
+	assert.Equal(t, "\U0001f697", variationselector.RemoveSkinTone("\U0001f697"))
+	for _, tone := range []variationselector.SkinTone{variationselector.ToneLight, variationselector.ToneDark} {
+		assert.Equal(t, "\U0001f44d", variationselector.RemoveSkinTone(variationselector.SetSkinTone("\U0001f44d", tone)))
+	}
+}
+
+func TestEmojis(t *testing.T) {
+	var offsets []int
+	var emojis []string
+	for offset, emoji := range variationselector.Emojis("hi \U0001f44d\U0001f3fd and \U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466! 1\ufe0f\u20e3\U0001f1e8\U0001f1ff") {
+		offsets = append(offsets, offset)
+		emojis = append(emojis, emoji)
+	}
+	assert.Equal(t, []int{3, 16, 43, 50}, offsets)
+	assert.Equal(t, []string{"\U0001f44d\U0001f3fd", "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466", "1\ufe0f\u20e3", "\U0001f1e8\U0001f1ff"}, emojis)
+
+	for range variationselector.Emojis("plain text") {
+		t.Fatal("unexpected emoji in plain text")
+	}
+	for _, emoji := range variationselector.Emojis("\U0001f44d\U0001f44e") {
+		assert.Equal(t, "\U0001f44d", emoji)
+		break
+	}
+}
+
+func TestEmojis_NoAllocs(t *testing.T) {
+	input := strings.Repeat("text \U0001f44d\U0001f3fd \U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466 ", 10)
+	allocs := testing.AllocsPerRun(10, func() {
+		for range variationselector.Emojis(input) {
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestAdd_Keycaps(t *testing.T) {
+	for _, base := range "#*0123456789" {
+		qualified := string(base) + "\ufe0f\u20e3"
+		for _, input := range []string{
+			string(base) + "\u20e3",
+			qualified,
+			string(base) + "\u20e3\ufe0f",
+			string(base) + "\ufe0e\u20e3",
+			string(base) + "\ufe0f\ufe0f\u20e3",
+			string(base) + "\u20e3\ufe0e\ufe0f",
+		} {
+			assert.Equal(t, qualified, variationselector.Add(input), "Add(%+q)", input)
+			assert.Equal(t, qualified, variationselector.Add(variationselector.Remove(input)), "Add(Remove(%+q))", input)
+		}
+		assert.Equal(t, string(base), variationselector.Add(string(base)))
+		assert.Equal(t, string(base), variationselector.Add(string(base)+"\ufe0f"))
+	}
+	assert.Equal(t, "call 555-1234 or press 1\ufe0f\u20e3", variationselector.Add("call 555-1234 or press 1\u20e3"))
+}
+
+func TestFirstEmoji(t *testing.T) {
+	emoji, rest, ok := variationselector.FirstEmoji("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466 Family")
+	assert.True(t, ok)
+	assert.Equal(t, "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466", emoji)
+	assert.Equal(t, " Family", rest)
+
+	emoji, rest, ok = variationselector.FirstEmoji("Room \U0001f44d\U0001f3fd\U0001f44e")
+	assert.True(t, ok)
+	assert.Equal(t, "\U0001f44d\U0001f3fd", emoji)
+	assert.Equal(t, "\U0001f44e", rest)
+
+	emoji, rest, ok = variationselector.FirstEmoji("\u263a\ufe0f")
+	assert.True(t, ok)
+	assert.Equal(t, "\u263a\ufe0f", emoji)
+	assert.Equal(t, "", rest)
+
+	emoji, rest, ok = variationselector.FirstEmoji("no emojis 123")
+	assert.False(t, ok)
+	assert.Equal(t, "", emoji)
+	assert.Equal(t, "", rest)
+}
+
+func TestEmojiVersion_String(t *testing.T) {
+	assert.Equal(t, "0.6", variationselector.Emoji0_6.String())
+	assert.Equal(t, "12.1", variationselector.Emoji12_1.String())
+	assert.Equal(t, "15.0", variationselector.Emoji15_0.String())
+	assert.Less(t, variationselector.Emoji5_0, variationselector.Emoji11_0)
+}
+
+func TestAddUpToVersion(t *testing.T) {
+	assert.Equal(t, "\u267e \u263a\ufe0f", variationselector.AddUpToVersion("\u267e \u263a", variationselector.Emoji5_0))
+	assert.Equal(t, "\u267e\ufe0f \u263a\ufe0f", variationselector.AddUpToVersion("\u267e \u263a", variationselector.Emoji11_0))
+	assert.Equal(t, "*\u20e3 #\ufe0f\u20e3", variationselector.AddUpToVersion("*\u20e3 #\u20e3", variationselector.Emoji1_0))
+	assert.Equal(t, "*\ufe0f\u20e3 #\ufe0f\u20e3", variationselector.AddUpToVersion("*\u20e3 #\u20e3", variationselector.Emoji2_0))
+	// Existing variation selectors are removed from newer emojis too
+	assert.Equal(t, "\u267e", variationselector.AddUpToVersion("\u267e\ufe0f", variationselector.Emoji5_0))
+	for _, input := range []string{"\u267e \u263a", "\U0001f44d\U0001f3fd", "\U0001f3f3\u200d\u26a7", "1\u20e3"} {
+		assert.Equal(t, variationselector.Add(input), variationselector.AddUpToVersion(input, variationselector.Emoji15_0))
+	}
+}
+
+func TestFullyQualifyUpToVersion(t *testing.T) {
+	assert.Equal(t, "\U0001f3f3\ufe0f\u200d\u26a7", variationselector.FullyQualifyUpToVersion("\U0001f3f3\u200d\u26a7", variationselector.Emoji12_0))
+	assert.Equal(t, "\U0001f3f3\ufe0f\u200d\u26a7\ufe0f", variationselector.FullyQualifyUpToVersion("\U0001f3f3\u200d\u26a7", variationselector.Emoji13_0))
+	assert.Equal(t, "\u267e \u263a\ufe0f", variationselector.FullyQualifyUpToVersion("\u267e \u263a", variationselector.Emoji5_0))
+	assert.Equal(t, "\u267e\ufe0f \u263a\ufe0f", variationselector.FullyQualifyUpToVersion("\u267e \u263a", variationselector.Emoji11_0))
+	for _, input := range []string{"\u267e \u263a", "\U0001f590\U0001f3fd", "\U0001f3f3\u200d\u26a7", "1\u20e3"} {
+		assert.Equal(t, variationselector.FullyQualify(input), variationselector.FullyQualifyUpToVersion(input, variationselector.Emoji15_0))
+	}
+}
+
+func TestRemoveQualified(t *testing.T) {
+	assert.Equal(t, "\U0001f44d", variationselector.RemoveQualified("\U0001f44d"))
+	assert.Equal(t, "\U0001f44d", variationselector.RemoveQualified("\U0001f44d\ufe0f"))
+	assert.Equal(t, "4\u20e3", variationselector.RemoveQualified("4\ufe0f\u20e3"))
+	assert.Equal(t, "\u263a \U0001f3f3\u200d\U0001f308", variationselector.RemoveQualified("\u263a\ufe0f \U0001f3f3\ufe0f\u200d\U0001f308"))
+	// Variation selectors after non-emoji characters must be left alone
+	assert.Equal(t, "\u845b\ufe0f", variationselector.RemoveQualified("\u845b\ufe0f"))
+	assert.Equal(t, "\u8279\ufe00 \u845b\U000e0100", variationselector.RemoveQualified("\u8279\ufe00 \u845b\U000e0100"))
+	assert.Equal(t, "a\ufe0f\u263a", variationselector.RemoveQualified("a\ufe0f\u263a\ufe0f"))
+}
+
+func TestFullyQualify_PreservesNonEmojiSelectors(t *testing.T) {
+	assert.Equal(t, "\u845b\ufe0f \u263a\ufe0f", variationselector.FullyQualify("\u845b\ufe0f \u263a"))
+}
+
+func TestMinimallyQualify(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":          "",
+		"no emojis": "no emojis",
+		// Single characters and keycaps are the same as the fully-qualified form
+		"\u263a":           "\u263a\ufe0f",
+		"\u263a\ufe0f":     "\u263a\ufe0f",
+		"1\u20e3":          "1\ufe0f\u20e3",
+		"\U0001f44d\ufe0f": "\U0001f44d",
+		// Selectors after the first element are removed
+		"\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468":         "\U0001f469\u200d\u2764\u200d\U0001f468",
+		"\U0001f469\u200d\u2764\u200d\U0001f468":               "\U0001f469\u200d\u2764\u200d\U0001f468",
+		"\U0001f441\u200d\U0001f5e8":                           "\U0001f441\ufe0f\u200d\U0001f5e8",
+		"\U0001f441\ufe0f\u200d\U0001f5e8\ufe0f":               "\U0001f441\ufe0f\u200d\U0001f5e8",
+		"\U0001f3cc\U0001f3fb\u200d\u2642\ufe0f":               "\U0001f3cc\U0001f3fb\u200d\u2642",
+		"\U0001f3f3\u200d\U0001f308":                           "\U0001f3f3\ufe0f\u200d\U0001f308",
+		"\U0001f468\u200d\U0001f469\u200d\U0001f467":           "\U0001f468\u200d\U0001f469\u200d\U0001f467",
+		"a \u2764\ufe0f\u200d\U0001f525 b \u263a \u845b\ufe0f": "a \u2764\ufe0f\u200d\U0001f525 b \u263a\ufe0f \u845b\ufe0f",
+	} {
+		output := variationselector.MinimallyQualify(input)
+		assert.Equal(t, expected, output, "MinimallyQualify(%+q)", input)
+		assert.GreaterOrEqual(t, variationselector.QualificationLevel(output), variationselector.MinimallyQualified, "QualificationLevel(%+q)", output)
+	}
+	assert.Equal(t, variationselector.MinimallyQualified, variationselector.QualificationLevel(variationselector.MinimallyQualify("\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468")))
+}
+
+func TestIsZWJSequence(t *testing.T) {
+	assert.True(t, variationselector.IsZWJSequence("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"))
+	assert.True(t, variationselector.IsZWJSequence("\U0001f9d1\U0001f3fd\u200d\U0001f680"))
+	assert.True(t, variationselector.IsZWJSequence("\U0001f3f3\ufe0f\u200d\U0001f308"))
+	assert.True(t, variationselector.IsZWJSequence("\U0001f3f3\u200d\U0001f308"))
+	assert.False(t, variationselector.IsZWJSequence("\U0001f44d\U0001f3fd"))
+	assert.False(t, variationselector.IsZWJSequence("\U0001f1e8\U0001f1ff"))
+	assert.False(t, variationselector.IsZWJSequence("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466 family"))
+	assert.False(t, variationselector.IsZWJSequence("\U0001f468\u200d"))
+	assert.False(t, variationselector.IsZWJSequence("a\u200db"))
+	assert.False(t, variationselector.IsZWJSequence(""))
+}
+
+func TestSplitZWJ(t *testing.T) {
+	assert.Equal(t, []string{"\U0001f468", "\U0001f469", "\U0001f467", "\U0001f466"}, variationselector.SplitZWJ("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"))
+	assert.Equal(t, []string{"\U0001f3f3\ufe0f", "\U0001f308"}, variationselector.SplitZWJ("\U0001f3f3\ufe0f\u200d\U0001f308"))
+	assert.Equal(t, []string{"\U0001f469\U0001f3fb", "\u2764\ufe0f", "\U0001f468\U0001f3ff"}, variationselector.SplitZWJ("\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff"))
+	assert.Equal(t, []string{"\U0001f44d\U0001f3fd"}, variationselector.SplitZWJ("\U0001f44d\U0001f3fd"))
+	assert.Nil(t, variationselector.SplitZWJ(""))
+
+	var parts []string
+	for _, part := range variationselector.SplitZWJ("\U0001f9d4\u200d\u2642") {
+		parts = append(parts, variationselector.FullyQualify(part))
+	}
+	assert.Equal(t, []string{"\U0001f9d4", "\u2642\ufe0f"}, parts)
+}
+
+func TestRemoveSkinTone_MultiPerson(t *testing.T) {
+	assert.Equal(t, "\U0001f91d", variationselector.RemoveSkinTone("\U0001faf1\U0001f3fb\u200d\U0001faf2\U0001f3fc"))
+	assert.Equal(t, "\U0001f91d", variationselector.RemoveSkinTone("\U0001f91d\U0001f3fd"))
+	assert.Equal(t, "\U0001f46b", variationselector.RemoveSkinTone("\U0001f469\U0001f3fb\u200d\U0001f91d\u200d\U0001f468\U0001f3fc"))
+	assert.Equal(t, "\U0001f46b", variationselector.RemoveSkinTone("\U0001f46b\U0001f3ff"))
+	assert.Equal(t, "\U0001f48f", variationselector.RemoveSkinTone("\U0001f9d1\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f48b\u200d\U0001f9d1\U0001f3fc"))
+	assert.Equal(t, "\U0001f9d1\u200d\U0001f91d\u200d\U0001f9d1", variationselector.RemoveSkinTone("\U0001f9d1\U0001f3fb\u200d\U0001f91d\u200d\U0001f9d1\U0001f3ff"))
+	assert.Equal(t, "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468", variationselector.RemoveSkinTone("\U0001f469\U0001f3fb\u200d\u2764\u200d\U0001f468\U0001f3ff"))
+}
+
+func TestRemoveSkinTone_Qualification(t *testing.T) {
+	assert.Equal(t, "\U0001f590\ufe0f", variationselector.RemoveSkinTone("\U0001f590\U0001f3fb"))
+	assert.Equal(t, "\U0001f575\ufe0f\u200d\u2642\ufe0f", variationselector.RemoveSkinTone("\U0001f575\U0001f3fb\u200d\u2642\ufe0f"))
+	assert.Equal(t, "hi \U0001f44d there \u270c\ufe0f!", variationselector.RemoveSkinTone("hi \U0001f44d\U0001f3fd there \u270c\U0001f3ff!"))
+	assert.Equal(t, variationselector.RemoveSkinTone("\U0001f44d\U0001f3fb"), variationselector.RemoveSkinTone("\U0001f44d\U0001f3fd"))
+	assert.True(t, variationselector.IsFullyQualified(variationselector.RemoveSkinTone("\U0001f9d1\U0001f3fe\u200d\U0001f9af \U0001f3cc\U0001f3fc\u200d\u2640\ufe0f \U0001f469\U0001f3ff\u200d\u2764\ufe0f\u200d\U0001f48b\u200d\U0001f469\U0001f3fb")))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "\U0001f469\u200d\U0001f4bb", variationselector.Normalize("\U0001f469\ufe0f\u200d\U0001f4bb"))
+	assert.Equal(t, "\U0001f469\u200d\U0001f4bb", variationselector.Normalize("\U0001f469\u200d\U0001f4bb\ufe0f"))
+	assert.Equal(t, "\U0001f3f3\ufe0f\u200d\U0001f308", variationselector.Normalize("\U0001f3f3\u200d\U0001f308"))
+	assert.Equal(t, "\U0001f3f3\ufe0f\u200d\U0001f308", variationselector.Normalize("\U0001f3f3\u200d\U0001f308\ufe0f"))
+	assert.Equal(t, "\U0001f9d1\U0001f3fd\u200d\U0001f680", variationselector.Normalize("\U0001f9d1\U0001f3fd\ufe0f\u200d\U0001f680"))
+	assert.Equal(t, "\u2764\ufe0f\u200d\U0001f525", variationselector.Normalize("\u2764\ufe0e\u200d\U0001f525"))
+	assert.Equal(t, "hi \U0001f469\u200d\U0001f4bb and \U0001f3f3\ufe0f\u200d\u26a7\ufe0f!", variationselector.Normalize("hi \U0001f469\ufe0f\u200d\U0001f4bb and \U0001f3f3\u200d\u26a7!"))
+	// Non-RGI sequences and emojis outside sequences are left alone
+	assert.Equal(t, "\U0001f44d\ufe0f\u200d\U0001f680", variationselector.Normalize("\U0001f44d\ufe0f\u200d\U0001f680"))
+	assert.Equal(t, "\u263a \U0001f44d\ufe0f", variationselector.Normalize("\u263a \U0001f44d\ufe0f"))
+	assert.Equal(t, "plain text", variationselector.Normalize("plain text"))
+}
+
+func TestNormalize_Idempotent(t *testing.T) {
+	for _, input := range []string{
+		"\U0001f469\ufe0f\u200d\U0001f4bb", "\U0001f3f3\u200d\U0001f308\ufe0f", "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466", "\U0001f469\U0001f3fb\u200d\u2764\u200d\U0001f48b\u200d\U0001f468\U0001f3ff", "\U0001f575\u200d\u2642", "\U0001f44d\ufe0f\u200d\U0001f680", "\u263a 1\u20e3 \U0001f1e8\U0001f1ff", "text \U0001f9d4\ufe0f\u200d\u2642\ufe0f text",
+	} {
+		normalized := variationselector.Normalize(input)
+		assert.Equal(t, normalized, variationselector.Normalize(normalized), "Normalize(Normalize(%+q))", input)
+	}
+}
+
+func TestCountEmojis(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		runes    int
+		clusters int
+	}{
+		{"", 0, 0},
+		{"no emojis 123", 0, 0},
+		{"\U0001f44d", 1, 1},
+		{"\u263a\ufe0f", 2, 1},
+		{"\U0001f44d\U0001f3fd", 2, 1},
+		{"\U0001f468\u200d\U0001f469\u200d\U0001f467", 5, 1},
+		{"\U0001f469\U0001f3fb\u200d\u2764\ufe0f\u200d\U0001f468\U0001f3ff", 8, 1},
+		{"\U0001f1e8\U0001f1ff\U0001f1e9\U0001f1ea", 4, 2},
+		{"1\ufe0f\u20e3", 3, 1},
+		{"#\u20e3", 2, 1},
+		{"\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", 7, 1},
+		{"hi \U0001f44d\U0001f3fd and \U0001f468\u200d\U0001f469\u200d\U0001f467 at 1\ufe0f\u20e3 in \U0001f1e8\U0001f1ff", 12, 4},
+	} {
+		runes, clusters := variationselector.CountEmojis(test.input)
+		assert.Equal(t, test.runes, runes, "runes in %+q", test.input)
+		assert.Equal(t, test.clusters, clusters, "clusters in %+q", test.input)
+		assert.Equal(t, variationselector.Count(test.input), clusters)
+	}
+}
+
+func TestSetSkinTone_Default(t *testing.T) {
+	assert.Equal(t, "\U0001f44d", variationselector.SetSkinTone("\U0001f44d\U0001f3fd", variationselector.ToneDefault))
+	assert.Equal(t, "\U0001f44d", variationselector.SetSkinTone("\U0001f44d", variationselector.ToneDefault))
+	assert.Equal(t, "\U0001f590\ufe0f", variationselector.SetSkinTone("\U0001f590\U0001f3ff", variationselector.ToneDefault))
+	assert.Equal(t, "\U0001f91d", variationselector.SetSkinTone("\U0001faf1\U0001f3fb\u200d\U0001faf2\U0001f3fc", variationselector.ToneDefault))
+	assert.Equal(t, "\U0001f697", variationselector.SetSkinTone("\U0001f697", variationselector.ToneDefault))
+	assert.Equal(t, "\U0001f44d\U0001f3fd", variationselector.SetSkinTone("\U0001f44d\U0001f3fd", variationselector.SkinTone(42)))
+
+	assert.Equal(t, variationselector.ToneDefault, variationselector.GetSkinTone("\U0001f44d"))
+	assert.Equal(t, "", variationselector.ToneDefault.Modifier())
+	assert.Equal(t, "default", variationselector.ToneDefault.String())
+}
+
+func TestSetSkinTone_Position(t *testing.T) {
+	assert.Equal(t, "\U0001f469\U0001f3fd\u200d\U0001f4bb", variationselector.SetSkinTone("\U0001f469\u200d\U0001f4bb", variationselector.ToneMedium))
+	assert.Equal(t, "\U0001f469\U0001f3fd\u200d\U0001f4bb", variationselector.SetSkinTone("\U0001f469\U0001f3fb\u200d\U0001f4bb", variationselector.ToneMedium))
+	assert.Equal(t, "\U0001f575\U0001f3fd\u200d\u2642\ufe0f", variationselector.SetSkinTone("\U0001f575\ufe0f\u200d\u2642\ufe0f", variationselector.ToneMedium))
+	assert.Equal(t, "\U0001f3c3\U0001f3fd\u200d\u27a1\ufe0f", variationselector.SetSkinTone("\U0001f3c3\u200d\u27a1\ufe0f", variationselector.ToneMedium))
+	for _, tone := range []variationselector.SkinTone{variationselector.ToneLight, variationselector.ToneMediumLight, variationselector.ToneMedium, variationselector.ToneMediumDark, variationselector.ToneDark} {
+		withTone := variationselector.SetSkinTone("\U0001f44d", tone)
+		assert.Equal(t, tone, variationselector.GetSkinTone(withTone))
+	}
+}
+
+func TestContainsEmoji_NoAllocs(t *testing.T) {
+	for _, input := range []string{
+		strings.Repeat("plain text 123 #*", 20),
+		strings.Repeat("\u00e4", 100) + "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+		"1\ufe0f\u20e3" + strings.Repeat("x", 100),
+	} {
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.ContainsEmoji(input)
+		}))
+	}
+}
+
+func TestCount_KeycapBases(t *testing.T) {
+	assert.Equal(t, 0, variationselector.Count("0123456789 #hashtag *bold*"))
+	assert.Equal(t, 0, variationselector.Count("1\ufe0f #\ufe0f *\ufe0e"))
+	assert.Equal(t, 3, variationselector.Count("1\ufe0f\u20e3#\u20e3*\ufe0f\u20e3"))
+	assert.Equal(t, 1, variationselector.Count("\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466"))
+	assert.Equal(t, 2, variationselector.Count("\U0001f1e8\U0001f1ff\U0001f1e9\U0001f1ea"))
+}
+
+func TestHasSkinToneSupport(t *testing.T) {
+	for _, emoji := range []string{"\U0001f44d", "\U0001f44d\U0001f3fd", "\U0001f468\u200d\U0001f680", "\U0001f9d1\U0001f3ff\u200d\U0001f680", "\u261d\ufe0f", "\u261d", "\U0001faf7", "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468", "\U0001f91d", "\U0001f575\ufe0f\u200d\u2640\ufe0f"} {
+		assert.True(t, variationselector.HasSkinToneSupport(emoji), "HasSkinToneSupport(%+q)", emoji)
+	}
+	for _, emoji := range []string{"\U0001f697", "\U0001f1e8\U0001f1ff", "\U0001f3f4\u200d\u2620\ufe0f", "\u2764\ufe0f", "1\ufe0f\u20e3", "\U0001f600", "\U0001f3fb", "", "hello", "\U0001f44d\U0001f44d", " \U0001f44d"} {
+		assert.False(t, variationselector.HasSkinToneSupport(emoji), "HasSkinToneSupport(%+q)", emoji)
+	}
+}
+
+func TestAppendAdd(t *testing.T) {
+	buf := []byte("prefix: ")
+	buf = variationselector.AppendAdd(buf, "\u263a \U0001f44d\U0001f3fd")
+	assert.Equal(t, "prefix: \u263a\ufe0f \U0001f44d\U0001f3fd", string(buf))
+	buf = variationselector.AppendAdd(buf[:0], "no emojis")
+	assert.Equal(t, "no emojis", string(buf))
+	assert.Equal(t, "1\ufe0f\u20e3", string(variationselector.AppendAdd(nil, "1\u20e3")))
+}
+
+func TestAppendFullyQualify(t *testing.T) {
+	buf := []byte("prefix: ")
+	buf = variationselector.AppendFullyQualify(buf, "\u263a \u2764\ufe0f \U0001f44d\ufe0f")
+	assert.Equal(t, "prefix: \u263a\ufe0f \u2764\ufe0f \U0001f44d", string(buf))
+	buf = variationselector.AppendFullyQualify(buf[:0], "no emojis")
+	assert.Equal(t, "no emojis", string(buf))
+}
+
+func TestAdd_NoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	for _, input := range []string{
+		"",
+		strings.Repeat("plain text 123 #*", 20),
+		"\u263a\ufe0f \U0001f44d\U0001f3fd 1\ufe0f\u20e3 \U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+	} {
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.Add(input)
+		}), "Add(%+q)", input)
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.AppendAdd(buf[:0], input)
+		}), "AppendAdd(%+q)", input)
+	}
+}
+
+func TestFullyQualify_NoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	for _, input := range []string{
+		"",
+		strings.Repeat("plain text 123 #*", 20),
+		"\u263a\ufe0f \U0001f44d\U0001f3fd 1\ufe0f\u20e3 \U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+	} {
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.FullyQualify(input)
+		}), "FullyQualify(%+q)", input)
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.AppendFullyQualify(buf[:0], input)
+		}), "AppendFullyQualify(%+q)", input)
+	}
+}
+
+func TestAppendFullyQualify_NoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	for _, input := range []string{
+		"\u263a \U0001f44d\ufe0f\U0001f3fd 1\u20e3 \U0001f3f3\u200d\U0001f308",
+		"\u263a\ufe0f\ufe0f \u2764",
+		strings.Repeat("plain text \u263a", 20),
+	} {
+		expected := variationselector.FullyQualify(input)
+		assert.NotEqual(t, input, expected)
+		assert.Equal(t, expected, string(variationselector.AppendFullyQualify(buf[:0], input)))
+		assert.Zero(t, testing.AllocsPerRun(10, func() {
+			variationselector.AppendFullyQualify(buf[:0], input)
+		}), "AppendFullyQualify(%+q)", input)
+	}
+}
+
+func TestRemove_NoAllocs(t *testing.T) {
+	for _, input := range []string{
+		"",
+		strings.Repeat("plain text 123 #*", 20),
+		"\u263a \U0001f44d\U0001f3fd 1\u20e3 \U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+	} {
+		for name, fn := range map[string]func(string) string{
+			"Remove":                 variationselector.Remove,
+			"RemoveAll":              variationselector.RemoveAll,
+			"RemoveQualified":        variationselector.RemoveQualified,
+			"RemoveTextPresentation": variationselector.RemoveTextPresentation,
+		} {
+			assert.Equal(t, input, fn(input), "%s(%+q)", name, input)
+			assert.Zero(t, testing.AllocsPerRun(10, func() {
+				fn(input)
+			}), "%s(%+q)", name, input)
+		}
+	}
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		variationselector.EqualIgnoreVariation("plain text", "plain text")
+	}))
+}
+
+func TestAddWithOptions(t *testing.T) {
+	all := variationselector.Options{Keycaps: true, Flags: true}
+	input := "1\u20e3 #\ufe0f\u20e3 \U0001f3f3 \U0001f3f3\u200d\U0001f308 \U0001f3f4\u200d\u2620 \u263a \u231a"
+	assert.Equal(t, variationselector.Add(input), variationselector.AddWithOptions(input, all))
+	assert.Equal(t, "1\ufe0f\u20e3 #\ufe0f\u20e3 \U0001f3f3\ufe0f \U0001f3f3\ufe0f\u200d\U0001f308 \U0001f3f4\u200d\u2620\ufe0f \u263a\ufe0f \u231a\ufe0f", variationselector.AddWithOptions(input, all))
+	assert.Equal(t, "1\u20e3 #\ufe0f\u20e3 \U0001f3f3 \U0001f3f3\u200d\U0001f308 \U0001f3f4\u200d\u2620 \u263a\ufe0f \u231a\ufe0f", variationselector.AddWithOptions(input, variationselector.Options{}))
+	assert.Equal(t, "1\u20e3 #\ufe0f\u20e3 \U0001f3f3\ufe0f \U0001f3f3\ufe0f\u200d\U0001f308 \U0001f3f4\u200d\u2620\ufe0f \u263a\ufe0f \u231a\ufe0f", variationselector.AddWithOptions(input, variationselector.Options{Flags: true}))
+	assert.Equal(t, "1\ufe0f\u20e3 #\ufe0f\u20e3 \U0001f3f3 \U0001f3f3\u200d\U0001f308 \U0001f3f4\u200d\u2620 \u263a\ufe0f \u231a\ufe0f", variationselector.AddWithOptions(input, variationselector.Options{Keycaps: true}))
+	assert.Equal(t, "1\ufe0f\u20e3 #\ufe0f\u20e3 \U0001f3f3 \U0001f3f3\u200d\U0001f308 \U0001f3f4\u200d\u2620 \u263a \u231a\ufe0f", variationselector.AddWithOptions(input, variationselector.Options{Keycaps: true, SkipTextDefault: true}))
+	assert.Equal(t, "\u263a\ufe0f \u263a\ufe0e \u231a\ufe0f", variationselector.AddWithOptions("\u263a\ufe0f \u263a\ufe0e \u231a", variationselector.Options{SkipTextDefault: true}))
+}
+
+func assertSameSlice(t *testing.T, expected, actual []byte) {
+	t.Helper()
+	assert.Equal(t, expected, actual)
+	assert.True(t, unsafe.SliceData(expected) == unsafe.SliceData(actual), "expected the input slice to be returned")
+}
+
+func TestAddBytes(t *testing.T) {
+	input := []byte("hello \u263a 1\u20e3")
+	assert.Equal(t, []byte("hello \u263a\ufe0f 1\ufe0f\u20e3"), variationselector.AddBytes(input))
+	assert.Equal(t, []byte("hello \u263a 1\u20e3"), input, "input must not be modified")
+	for _, unchanged := range [][]byte{[]byte("plain text"), []byte("\u263a\ufe0f \U0001f44d\U0001f3fd")} {
+		assertSameSlice(t, unchanged, variationselector.AddBytes(unchanged))
+	}
+	assert.Nil(t, variationselector.AddBytes(nil))
+}
+
+func TestRemoveBytes(t *testing.T) {
+	input := []byte("\u263a\ufe0f \u263a\ufe0e 1\ufe0f\u20e3")
+	assert.Equal(t, []byte("\u263a \u263a\ufe0e 1\u20e3"), variationselector.RemoveBytes(input))
+	assert.Equal(t, []byte("\u263a\ufe0f \u263a\ufe0e 1\ufe0f\u20e3"), input, "input must not be modified")
+	unchanged := []byte("\u263a \U0001f44d")
+	assertSameSlice(t, unchanged, variationselector.RemoveBytes(unchanged))
+}
+
+func TestFullyQualifyBytes(t *testing.T) {
+	input := []byte("\u263a \u231a\ufe0f")
+	assert.Equal(t, []byte("\u263a\ufe0f \u231a"), variationselector.FullyQualifyBytes(input))
+	assert.Equal(t, []byte("\u263a \u231a\ufe0f"), input, "input must not be modified")
+	unchanged := []byte("\u263a\ufe0f \u231a text")
+	assertSameSlice(t, unchanged, variationselector.FullyQualifyBytes(unchanged))
+}
+
+func TestBytes_InvalidUTF8(t *testing.T) {
+	for _, input := range []string{
+		"\u263a\xf0\x9f",
+		"\xe2\x98",
+		"\u263a\xef\xb8",
+		"\xff\u263a\xff",
+		"1\xef\xb8\u20e3",
+	} {
+		assert.Equal(t, variationselector.Add(input), string(variationselector.AddBytes([]byte(input))), "AddBytes(%+q)", input)
+		assert.Equal(t, variationselector.Remove(input), string(variationselector.RemoveBytes([]byte(input))), "RemoveBytes(%+q)", input)
+		assert.Equal(t, variationselector.FullyQualify(input), string(variationselector.FullyQualifyBytes([]byte(input))), "FullyQualifyBytes(%+q)", input)
+	}
+}
+
+func TestAddTransformer(t *testing.T) {
+	output, _, err := transform.String(variationselector.AddTransformer(), "hello \u263a 1\u20e3 \U0001f44d\ufe0f\U0001f3fd")
+	require.NoError(t, err)
+	assert.Equal(t, "hello \u263a\ufe0f 1\ufe0f\u20e3 \U0001f44d\U0001f3fd", output)
+
+	// The character after the keycap base decides whether a variation selector is needed, so it must be in the buffer
+	tr := variationselector.AddTransformer()
+	dst := make([]byte, 64)
+	nDst, nSrc, err := tr.Transform(dst, []byte("a 1"), false)
+	assert.ErrorIs(t, err, transform.ErrShortSrc)
+	assert.Equal(t, "a ", string(dst[:nDst]))
+	assert.Equal(t, 2, nSrc)
+	nDst, nSrc, err = tr.Transform(dst, []byte("\u263a\xef\xb8"), false)
+	assert.ErrorIs(t, err, transform.ErrShortSrc)
+	assert.Zero(t, nDst)
+	assert.Zero(t, nSrc)
+	nDst, nSrc, err = tr.Transform(dst, []byte("\u263a"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "\u263a\ufe0f", string(dst[:nDst]))
+	assert.Equal(t, len("\u263a"), nSrc)
+}
+
+func TestRemoveTransformer(t *testing.T) {
+	output, _, err := transform.String(variationselector.RemoveTransformer(), "hello \u263a\ufe0f 1\ufe0f\u20e3 \u263a\ufe0e")
+	require.NoError(t, err)
+	assert.Equal(t, "hello \u263a 1\u20e3 \u263a\ufe0e", output)
+
+	tr := variationselector.RemoveTransformer()
+	dst := make([]byte, 64)
+	nDst, nSrc, err := tr.Transform(dst, []byte("\u263a\xef\xb8"), false)
+	assert.ErrorIs(t, err, transform.ErrShortSrc)
+	assert.Equal(t, "\u263a", string(dst[:nDst]))
+	assert.Equal(t, len("\u263a"), nSrc)
+}
+
+func TestNewAddReader(t *testing.T) {
+	input := strings.Repeat("log line \u263a 1\u20e3 \U0001f44d\U0001f3fd \U0001f3f3\u200d\U0001f308\n", 500)
+	output, err := io.ReadAll(variationselector.NewAddReader(iotest.HalfReader(strings.NewReader(input))))
+	require.NoError(t, err)
+	assert.Equal(t, variationselector.Add(input), string(output))
+
+	// A keycap base at the end of the input doesn't get a variation selector
+	output, err = io.ReadAll(variationselector.NewAddReader(iotest.OneByteReader(strings.NewReader("\u263a 1"))))
+	require.NoError(t, err)
+	assert.Equal(t, "\u263a\ufe0f 1", string(output))
+
+	// Errors from the underlying reader are returned after flushing the data that was read before them
+	output, err = io.ReadAll(variationselector.NewAddReader(iotest.TimeoutReader(strings.NewReader("\u263a text"))))
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+	assert.Equal(t, "\u263a\ufe0f text", string(output))
+}
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"\U0001f600":                           "grinning face",
+		"\u263a\ufe0f":                         "smiling face",
+		"\u263a":                               "smiling face",
+		"\u263a\ufe0e":                         "smiling face",
+		"#\ufe0f\u20e3":                        "keycap: #",
+		"#\u20e3":                              "keycap: #",
+		"\U0001f44d\U0001f3fd":                 "thumbs up: medium skin tone",
+		"\U0001f468\U0001f3fe\u200d\U0001f680": "man astronaut: medium-dark skin tone",
+		"\U0001f3f3\u200d\U0001f308":           "rainbow flag",
+		"\U0001f1eb\U0001f1ee":                 "flag: Finland",
+		"\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f": "flag: England",
+	}
+	for input, expected := range tests {
+		name, ok := variationselector.Name(input)
+		assert.True(t, ok, "Name(%+q)", input)
+		assert.Equal(t, expected, name, "Name(%+q)", input)
+	}
+	for _, input := range []string{"", "a", "\U0001f600\U0001f600", "\U0001f600 ", "\U0001f3fd", "\U0001f1eb"} {
+		_, ok := variationselector.Name(input)
+		assert.False(t, ok, "Name(%+q)", input)
+	}
+}
+
+func TestByName(t *testing.T) {
+	tests := map[string]string{
+		"grinning face":                        "\U0001f600",
+		"Grinning Face":                        "\U0001f600",
+		"SMILING FACE":                         "\u263a\ufe0f",
+		"keycap: #":                            "#\ufe0f\u20e3",
+		"thumbs up: medium skin tone":          "\U0001f44d\U0001f3fd",
+		"man astronaut: medium-dark skin tone": "\U0001f468\U0001f3fe\u200d\U0001f680",
+		"flag: finland":                        "\U0001f1eb\U0001f1ee",
+		"pi\u00f1ata":                          "\U0001fa85",
+		"PI\u00d1ATA":                          "\U0001fa85",
+	}
+	for input, expected := range tests {
+		emoji, ok := variationselector.ByName(input)
+		assert.True(t, ok, "ByName(%q)", input)
+		assert.Equal(t, expected, emoji, "ByName(%q)", input)
+		name, _ := variationselector.Name(emoji)
+		assert.Equal(t, strings.ToLower(input), strings.ToLower(name))
+	}
+	for _, input := range []string{"", "grinning", "smile", "grinning face ", "thumbs up:medium skin tone", "\U0001f600"} {
+		_, ok := variationselector.ByName(input)
+		assert.False(t, ok, "ByName(%q)", input)
+	}
+}
+
+func TestAllEmojis(t *testing.T) {
+	emojis := variationselector.AllEmojis()
+	assert.Len(t, emojis, 3655)
+	assert.Equal(t, "\U0001f600", emojis[0])
+	assert.Contains(t, emojis, "\U0001f44d\U0001f3fd")
+	assert.Contains(t, emojis, "\u263a\ufe0f")
+	assert.NotContains(t, emojis, "\u263a")
+	seen := make(map[string]struct{}, len(emojis))
+	for _, emoji := range emojis {
+		assert.True(t, variationselector.IsFullyQualified(emoji), "IsFullyQualified(%+q)", emoji)
+		assert.True(t, variationselector.IsSingleEmoji(emoji), "IsSingleEmoji(%+q)", emoji)
+		assert.NotContains(t, seen, emoji)
+		seen[emoji] = struct{}{}
+	}
+
+	// Modifying the returned slice must not affect other calls
+	emojis[0] = "foo"
+	assert.Equal(t, "\U0001f600", variationselector.AllEmojis()[0])
+}
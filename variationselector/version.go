@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package variationselector
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EmojiVersion is an emoji version as defined in Unicode Technical Standard #51 (e.g. E0.6 or E15.0).
+//
+// Versions are comparable, so a higher value always means a newer version.
+type EmojiVersion int
+
+const (
+	Emoji0_6  EmojiVersion = 6
+	Emoji0_7  EmojiVersion = 7
+	Emoji1_0  EmojiVersion = 100
+	Emoji2_0  EmojiVersion = 200
+	Emoji3_0  EmojiVersion = 300
+	Emoji4_0  EmojiVersion = 400
+	Emoji5_0  EmojiVersion = 500
+	Emoji11_0 EmojiVersion = 1100
+	Emoji12_0 EmojiVersion = 1200
+	Emoji12_1 EmojiVersion = 1201
+	Emoji13_0 EmojiVersion = 1300
+	Emoji13_1 EmojiVersion = 1301
+	Emoji14_0 EmojiVersion = 1400
+	Emoji15_0 EmojiVersion = 1500
+	Emoji15_1 EmojiVersion = 1501
+)
+
+// latestEmojiVersion is used internally to disable version filtering.
+const latestEmojiVersion = EmojiVersion(math.MaxInt)
+
+func (version EmojiVersion) String() string {
+	return fmt.Sprintf("%d.%d", version/100, version%100)
+}
+
+func parseEmojiVersion(val string) (EmojiVersion, error) {
+	majorStr, minorStr, ok := strings.Cut(val, ".")
+	if !ok {
+		return 0, fmt.Errorf("invalid emoji version %q", val)
+	}
+	major, err := strconv.ParseUint(majorStr, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid emoji version %q: %w", val, err)
+	}
+	minor, err := strconv.ParseUint(minorStr, 10, 16)
+	if err != nil || minor > 99 {
+		return 0, fmt.Errorf("invalid emoji version %q", val)
+	}
+	return EmojiVersion(major*100 + minor), nil
+}
+
+// AddUpToVersion is equivalent to Add, except that variation selectors are only added to emojis that
+// were introduced in the given emoji version or earlier. Newer emojis are left without variation selectors,
+// which is useful when the recipient doesn't support them as emojis anyway.
+func AddUpToVersion(val string, maxVersion EmojiVersion) string {
+	return add(getData(), val, defaultOptions, maxVersion)
+}
+
+// FullyQualifyUpToVersion is equivalent to FullyQualify, except that only emojis introduced in the given
+// emoji version or earlier are converted to their fully-qualified form.
+//
+// Note that the components of newer zero-width joiner sequences may still be qualified individually,
+// e.g. the heart in a heart on fire emoji (E13.1) is qualified if the maximum version is E12.0.
+func FullyQualifyUpToVersion(val string, maxVersion EmojiVersion) string {
+	return fullyQualify(getData(), val, maxVersion)
+}